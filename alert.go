@@ -0,0 +1,125 @@
+package csb
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// AlertBelowThreshold rules raise an alert when a percentage falls below the threshold.
+	AlertBelowThreshold = "below_threshold"
+	// AlertDrop rules raise an alert when a percentage drops by more than threshold points
+	// from the previous period.
+	AlertDrop = "drop"
+)
+
+// AlertRule represents a rule evaluated against every new mark.
+//
+// The scope fields are optional, a nil scope field matches any value. A rule with all the
+// scope fields nil is evaluated against every mark.
+type AlertRule struct {
+	// ID of the rule.
+	ID int `json:"id"`
+
+	// Kind of the rule, either AlertBelowThreshold or AlertDrop.
+	Kind string `json:"kind"`
+	// Threshold is the minimum percentage for AlertBelowThreshold rules and the maximum
+	// amount of points a mark can drop for AlertDrop rules.
+	Threshold int `json:"threshold"`
+
+	// StudentID scopes the rule to a single student.
+	StudentID *int `json:"student_id"`
+	// Year scopes the rule to a year group: 11 -> Y11.
+	Year *int `json:"year"`
+	// SubjectID scopes the rule to a subject.
+	SubjectID *int `json:"subject_id"`
+
+	// Timestamp.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (r *AlertRule) Validate() error {
+	if r.Kind != AlertBelowThreshold && r.Kind != AlertDrop {
+		return Errorf(EINVALID, "validate: alert rule has invalid kind: %v", r.Kind)
+	}
+	if r.Threshold < 0 || r.Threshold > 100 {
+		return Errorf(EINVALID, "validate: threshold must be between 0 and 100 inclusive, but got: %v", r.Threshold)
+	}
+
+	return nil
+}
+
+// Alert represents a match of an alert rule on a mark.
+type Alert struct {
+	// ID of the alert.
+	ID int `json:"id"`
+
+	// Links to the rule which raised the alert.
+	RuleID int        `json:"rule_id"`
+	Rule   *AlertRule `json:"rule"`
+
+	// Links to the mark which broke the rule.
+	MarkID int   `json:"mark_id"`
+	Mark   *Mark `json:"mark"`
+
+	// StudentID links to the student who recieved the mark.
+	StudentID int `json:"pid"`
+	// Previous is the percentage from the previous period, only populated by AlertDrop rules.
+	Previous *int `json:"previous,omitempty"`
+
+	// Timestamp.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AlertService represents an alert service.
+//
+// Alerts are raised by the implementations whenever new marks are stored.
+type AlertService interface {
+	// FindAlertRuleByID returns the alert rule with id = id.
+	//
+	// returns ENOTFOUND if the rule doesnt exist.
+	FindAlertRuleByID(ctx context.Context, id int) (*AlertRule, error)
+
+	// FindAlertRules finds the alert rules with the appropiate filter.
+	FindAlertRules(ctx context.Context, filter AlertRuleFilter) ([]*AlertRule, error)
+
+	// CreateAlertRule creates a new alert rule, the rule is only evaluated against marks
+	// stored after its creation.
+	//
+	// returns EINVALID if the rule isnt valid.
+	CreateAlertRule(ctx context.Context, rule *AlertRule) error
+
+	// DeleteAlertRule permanently deletes the alert rule with id = id and all its alerts.
+	//
+	// returns ENOTFOUND if the rule doesnt exist.
+	DeleteAlertRule(ctx context.Context, id int) error
+
+	// FindAlerts finds the alerts with the appropiate filter, newest first.
+	FindAlerts(ctx context.Context, filter AlertFilter) ([]*Alert, error)
+}
+
+// AlertRuleFilter represents a filter to bulk get alert rules.
+type AlertRuleFilter struct {
+	// Kind filters on the rule kind.
+	Kind *string `json:"kind"`
+	// StudentID filters on the rule student scope.
+	StudentID *int `json:"student_id"`
+	// Year filters on the rule year group scope.
+	Year *int `json:"year"`
+	// SubjectID filters on the rule subject scope.
+	SubjectID *int `json:"subject_id"`
+}
+
+// AlertFilter represents a filter to bulk get alerts.
+type AlertFilter struct {
+	// RuleID filters on the rule which raised the alert.
+	RuleID *int `json:"rule_id"`
+	// PID filters on the student who raised the alert.
+	PID *int `json:"pid"`
+	// Year filters on the current year of the student who raised the alert.
+	Year *int `json:"year"`
+	// SubjectID filters on the subject of the mark which raised the alert.
+	SubjectID *int `json:"subject_id"`
+	// Since only lets through the alerts raised after the provided time.
+	Since *time.Time `json:"since"`
+}
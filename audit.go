@@ -0,0 +1,101 @@
+package csb
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// AuditCreate records the creation of a target.
+	AuditCreate = "create"
+	// AuditUpdate records the update of a target.
+	AuditUpdate = "update"
+	// AuditDelete records the deletion of a target.
+	AuditDelete = "delete"
+	// AuditAnonymize records the anonymization of a target.
+	AuditAnonymize = "anonymize"
+)
+
+const (
+	// AuditStudent targets are students, identified by their pupil id.
+	AuditStudent = "student"
+	// AuditMark targets are marks, identified by their id.
+	AuditMark = "mark"
+	// AuditImportBatch targets are import batches, identified by their id.
+	AuditImportBatch = "import_batch"
+)
+
+// SystemActor is the actor of the changes made without an actor in the context.
+const SystemActor = "system"
+
+// AuditEntry represents a change to the data, recorded in an append-only log.
+type AuditEntry struct {
+	// ID of the entry.
+	ID int `json:"id"`
+
+	// Actor is who made the change, the admin, an api client, a job or the system.
+	Actor string `json:"actor"`
+	// Operation is either AuditCreate, AuditUpdate, AuditDelete or AuditAnonymize.
+	Operation string `json:"operation"`
+	// Target is the kind of the changed record, TargetID is its id.
+	Target   string `json:"target"`
+	TargetID int    `json:"target_id"`
+	// Before and After summarize the target around the change, empty for creations and
+	// deletions respectively. The summaries of students leave out their names.
+	//
+	// Unlike the rest of the entry the summaries arent append-only, the erasure of a student
+	// clears the summaries about them, of the student and of their marks. The entry itself
+	// stays with empty summaries.
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+
+	// Timestamp of the change.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter represents a filter to bulk get audit entries.
+type AuditFilter struct {
+	// Actor filters on the actor of the change.
+	Actor *string `json:"actor"`
+	// Operation filters on the operation of the change.
+	Operation *string `json:"operation"`
+	// Target and TargetID filter on the changed record.
+	Target   *string `json:"target"`
+	TargetID *int    `json:"target_id"`
+	// Since and Until only let through the entries recorded in the time range, inclusive.
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+
+	// Limit caps the amount of entries, defaults to 50. Offset skips the first entries.
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// AuditService represents a service reading the audit log.
+//
+// The entries are written by the implementations of the other services along side the
+// changes they record.
+type AuditService interface {
+	// FindAuditEntries finds the audit entries with the appropiate filter, newest first.
+	//
+	// returns EINVALID if the limit or offset is negative.
+	FindAuditEntries(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+// actorKey is the context key of the actor.
+type actorKey struct{}
+
+// NewContextWithActor returns a copy of the context carrying the actor of the changes made
+// with it.
+func NewContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by the context, SystemActor if none.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
@@ -0,0 +1,33 @@
+package csb
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Backup represents a consistent copy of the database taken while it was serving requests.
+type Backup struct {
+	// Name of the backup, unique across the backups.
+	Name string `json:"name"`
+	// Size of the backup in bytes.
+	Size int64 `json:"size"`
+	// CreatedAt is the time the backup was taken.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupService represents a service taking and rotating online backups of the database.
+type BackupService interface {
+	// CreateBackup takes a backup of the database without blocking the other requests, the
+	// oldest backups are rotated out.
+	CreateBackup(ctx context.Context) (*Backup, error)
+
+	// FindBackups returns the kept backups, newest first.
+	FindBackups(ctx context.Context) ([]*Backup, error)
+
+	// OpenBackup opens the backup with name = name for reading, the caller must close the
+	// reader.
+	//
+	// returns ENOTFOUND if the backup doesnt exist.
+	OpenBackup(ctx context.Context, name string) (*Backup, io.ReadCloser, error)
+}
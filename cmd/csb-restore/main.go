@@ -0,0 +1,35 @@
+// Command csb-restore restores the sqlite database from a backup taken by the backup service.
+//
+// The server must be stopped while the database is restored:
+//
+//	csb-restore -backup backups/csb-20221014T120000.000Z.db -dsn csb.db -migrations file://db/migrations
+//
+// The schema version of the backup is checked against the migrations before the backup is
+// swapped in, the replaced database is kept next to it with a .pre-restore suffix.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Lambels/CSB-Open-API/sqlite"
+)
+
+func main() {
+	backup := flag.String("backup", "", "path of the backup to restore")
+	dsn := flag.String("dsn", "", "data source name of the restored database")
+	migrations := flag.String("migrations", "file://db/migrations", "path of the migrations folder")
+	flag.Parse()
+
+	if *backup == "" || *dsn == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := sqlite.Restore(*backup, *dsn, *migrations); err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("restored %v from %v\n", *dsn, *backup)
+}
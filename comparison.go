@@ -0,0 +1,134 @@
+package csb
+
+import "context"
+
+// HeadToHeadFilter represents a request to the CompareStudents service.
+type HeadToHeadFilter struct {
+	// PIDs of the compared students, at least 2.
+	PIDs []int `json:"pids"`
+	// From and To bound the compared period range.
+	From Period `json:"from"`
+	To   Period `json:"to"`
+	// Subjects scopes the compared subjects, all the subjects of the students if empty.
+	Subjects []Subject `json:"subjects"`
+}
+
+// HeadToHead represents a comparison of the marks of students. All the slices of percentages,
+// averages and differences are aligned with PIDs, nil values meaning the student has no mark.
+type HeadToHead struct {
+	PIDs []int `json:"pids"`
+	// Subjects are the compared subjects, ordered by name.
+	Subjects []*SubjectComparison `json:"subjects"`
+}
+
+// SubjectComparison represents the comparison of the students on a subject.
+type SubjectComparison struct {
+	Subject Subject `json:"subject"`
+	// Shared reports wether at least 2 of the students have marks on the subject, subjects
+	// which arent shared have no leaders.
+	Shared bool `json:"shared"`
+
+	// Averages are the average percentages of each student on the subject.
+	Averages []*float64 `json:"averages"`
+	// Leaders are the pids of the students with the highest average, more than one if tied.
+	Leaders []int `json:"leaders"`
+
+	// Periods are the compared periods in chronological order.
+	Periods []*PeriodComparison `json:"periods"`
+}
+
+// PeriodComparison represents the comparison of the students in a period of a subject.
+type PeriodComparison struct {
+	Period Period `json:"period"`
+
+	Percentages []*int `json:"percentages"`
+	// Differences are the percentages of each student minus the percentage of the first
+	// student.
+	Differences []*int `json:"differences"`
+	// Leaders are the pids of the students with the highest percentage, more than one if
+	// tied. Empty if less than 2 students have marks in the period.
+	Leaders []int `json:"leaders"`
+}
+
+// Continuity of a subject between two academic years.
+const (
+	// SubjectContinued marks subjects with marks in both academic years.
+	SubjectContinued = "continued"
+	// SubjectStarted marks subjects with marks only in the current academic year.
+	SubjectStarted = "started"
+	// SubjectDropped marks subjects with marks only in the previous academic year.
+	SubjectDropped = "dropped"
+)
+
+// YearOverYearFilter represents a request to the CompareYears service.
+type YearOverYearFilter struct {
+	PID int `json:"pid"`
+	// AcademicYear is the compared academic year.
+	AcademicYear int `json:"academic_year"`
+	// Previous is the academic year compared against, defaults to the academic year before.
+	Previous *int `json:"previous"`
+	// Term and Importance scope the compared periods, all the periods of the academic years
+	// if nil.
+	Term       *int    `json:"term"`
+	Importance *string `json:"importance"`
+	// Subjects scopes the compared subjects, all the subjects of the student if empty.
+	Subjects []Subject `json:"subjects"`
+}
+
+// YearOverYear represents the comparison of the marks of a student in an academic year with
+// their marks in a previous academic year, lined up by term and importance.
+type YearOverYear struct {
+	StudentID    int `json:"pid"`
+	AcademicYear int `json:"academic_year"`
+	Previous     int `json:"previous"`
+
+	// Subjects are the compared subjects, ordered by name.
+	Subjects []*SubjectMovement `json:"subjects"`
+	// BiggestImprovement and BiggestDecline are the continued subjects with the highest and
+	// lowest delta, nil if no subject improved or declined.
+	BiggestImprovement *SubjectMovement `json:"biggest_improvement"`
+	BiggestDecline     *SubjectMovement `json:"biggest_decline"`
+	// Overall is the average of the deltas of all the lined up marks, nil if no marks
+	// line up.
+	Overall *float64 `json:"overall"`
+}
+
+// SubjectMovement represents the movement of the marks of a student on a subject.
+type SubjectMovement struct {
+	Subject Subject `json:"subject"`
+	// Continuity is either continued, started or dropped.
+	Continuity string `json:"continuity"`
+	// Periods are the lined up periods in term and importance order.
+	Periods []*PeriodMovement `json:"periods"`
+	// Delta is the average of the deltas of the periods, nil if no periods line up.
+	Delta *float64 `json:"delta"`
+}
+
+// PeriodMovement represents the marks of a subject in the same term and importance of the
+// two academic years.
+type PeriodMovement struct {
+	Term       int    `json:"term"`
+	Importance string `json:"importance"`
+
+	Previous *int `json:"previous"`
+	Current  *int `json:"current"`
+	// Delta is Current minus Previous, nil if either is missing.
+	Delta *int `json:"delta"`
+}
+
+// ComparisonService represents a service comparing the marks of students.
+type ComparisonService interface {
+	// CompareStudents compares the marks of the students over the period range, aligned
+	// per subject and per period.
+	//
+	// returns ENOTFOUND if any of the students doesnt exist and EINVALID if less than 2
+	// students are compared.
+	CompareStudents(ctx context.Context, filter HeadToHeadFilter) (*HeadToHead, error)
+
+	// CompareYears compares the marks of a student in an academic year with their marks in
+	// a previous academic year.
+	//
+	// returns ENOTFOUND if the student doesnt exist and EINVALID if the previous academic
+	// year isnt before the academic year.
+	CompareYears(ctx context.Context, filter YearOverYearFilter) (*YearOverYear, error)
+}
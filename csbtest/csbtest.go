@@ -0,0 +1,69 @@
+// Package csbtest is a conformance test suite for the implementations of the csb services.
+//
+// A backend runs the suite from its own tests against a constructor of its services:
+//
+//	func TestConformance(t *testing.T) {
+//		csbtest.TestStudentService(t, newServices)
+//		csbtest.TestMarkService(t, newServices)
+//	}
+//
+// The students and marks are served by a fake engage source, the services are expected to
+// read them with the engage client and period service of the source.
+package csbtest
+
+import (
+	"testing"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+// Services are the services of a backend under test, sharing the same storage.
+type Services struct {
+	Students csb.StudentService
+	Marks    csb.MarkService
+}
+
+// Constructor creates the services of a backend over new empty storage, reading engage with
+// e.Client() and e.PeriodService(). Falling back on engage must be disabled, the storage is
+// released with t.Cleanup.
+type Constructor func(t *testing.T, e *Engage) Services
+
+// Subjects of the fake students, as engage reports them.
+var (
+	mathematics = csb.Subject{EngageCode: "CL1-103", Name: "Mathematics"}
+	physics     = csb.Subject{EngageCode: "CL1-125", Name: "Physics"}
+	chemistry   = csb.Subject{EngageCode: "CL1-108", Name: "Chemistry"}
+	english     = csb.Subject{EngageCode: "CL1-102", Name: "English"}
+)
+
+// newMark creates an engage mark in the full period.
+func newMark(academicYear, term int, importance string, subject csb.Subject, teacher string, percentage int) *csb.Mark {
+	return &csb.Mark{
+		Subject:    csb.Subject{Name: subject.Name},
+		Teacher:    teacher,
+		Percentage: percentage,
+		Period:     csb.Period{AcademicYear: academicYear, Term: &term, Importance: &importance},
+	}
+}
+
+// expectCode fails the test if the error code of err isnt code.
+func expectCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	if got := csb.ErrorCode(err); got != code {
+		t.Fatalf("expected error code %q, got: %q (%v)", code, got, err)
+	}
+}
+
+// expectNoError fails the test if err isnt nil.
+func expectNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func intPtr(v int) *int          { return &v }
+func boolPtr(v bool) *bool       { return &v }
+func stringPtr(v string) *string { return &v }
@@ -0,0 +1,427 @@
+package csbtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/engage"
+)
+
+// Student is a student served by the fake engage source.
+type Student struct {
+	PID  int
+	Name string
+	// CurrentYear is only rendered if the student has subjects or marks in the current
+	// academic year.
+	CurrentYear int
+	// Subjects are the reporting subjects of the student in each academic year.
+	Subjects map[int][]csb.Subject
+	// Marks are the marks of the student in the render, with the subject name, teacher,
+	// percentage and full period populated.
+	Marks []*csb.Mark
+}
+
+// academicYears returns the academic years the student has subjects or marks in, in order.
+func (s *Student) academicYears() []int {
+	seen := make(map[int]struct{})
+	for academicYear := range s.Subjects {
+		seen[academicYear] = struct{}{}
+	}
+	for _, mark := range s.Marks {
+		seen[mark.Period.AcademicYear] = struct{}{}
+	}
+
+	out := make([]int, 0, len(seen))
+	for academicYear := range seen {
+		out = append(out, academicYear)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// periods returns the full periods the student has marks in, in chronological order.
+func (s *Student) periods() []csb.Period {
+	seen := make(map[string]struct{})
+	out := make([]csb.Period, 0)
+	for _, mark := range s.Marks {
+		key := fmt.Sprintf("%v:%v:%v", mark.Period.AcademicYear, *mark.Period.Term, *mark.Period.Importance)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		out = append(out, mark.Period)
+	}
+	sort.Slice(out, func(i, j int) bool { return lessPeriod(out[i], out[j]) })
+	return out
+}
+
+// Engage is a fake engage source. It serves the engage endpoints used by the engage client
+// from the students it holds and implements the period service over their marks.
+//
+// The reporting periods of a student are the terms of the marks in each academic year, term
+// 1 if the student has no marks in the academic year.
+type Engage struct {
+	mu       sync.Mutex
+	students map[int]*Student
+}
+
+// NewEngage creates a new fake engage source without any students.
+func NewEngage() *Engage {
+	return &Engage{
+		students: make(map[int]*Student),
+	}
+}
+
+// SetStudent adds the student to engage, replacing any student with the same pid.
+func (e *Engage) SetStudent(student *Student) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.students[student.PID] = student
+}
+
+// RemoveStudent removes the student with pid = pid from engage.
+func (e *Engage) RemoveStudent(pid int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.students, pid)
+}
+
+// AddMark adds the mark to the render of the student with pid = pid.
+func (e *Engage) AddMark(pid int, mark *csb.Mark) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if student, ok := e.students[pid]; ok {
+		student.Marks = append(student.Marks, mark)
+	}
+}
+
+// Client returns an engage client sending its requests to the fake engage source.
+func (e *Engage) Client() *engage.Client {
+	return engage.NewClient(&http.Client{Transport: e}, "csbtest")
+}
+
+// engageTerm returns the engage reporting period of the academic year and term.
+func engageTerm(academicYear, term int) string {
+	return fmt.Sprintf("%v-T%v", academicYear, term)
+}
+
+// RoundTrip serves the engage request.
+func (e *Engage) RoundTrip(r *http.Request) (*http.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var req struct {
+		PupilIDs            string `json:"pupilIDs"`
+		AcademicYears       string `json:"academicYears"`
+		ReportingPeriods    string `json:"reportingPeriods"`
+		AcademicYear        string `json:"academicYear"`
+		ReportingPeriodList string `json:"reportingPeriodList"`
+		ColumnList          string `json:"columnList"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return respond(http.StatusBadRequest, map[string]string{"Message": err.Error()})
+	}
+
+	pid, _ := strconv.Atoi(req.PupilIDs)
+	student := e.students[pid]
+
+	data := make([]map[string]string, 0)
+	switch path.Base(r.URL.Path) {
+	case "GetMarksheetAcademicYears":
+		if student == nil {
+			break
+		}
+
+		for _, academicYear := range student.academicYears() {
+			data = append(data, map[string]string{"Text": fmt.Sprint(academicYear), "Value": fmt.Sprint(academicYear)})
+		}
+
+	case "GetReportingPeriods":
+		if student == nil {
+			break
+		}
+
+		for _, academicYear := range splitInts(req.AcademicYears) {
+			terms := make(map[int]struct{})
+			for _, period := range student.periods() {
+				if period.AcademicYear == academicYear {
+					terms[*period.Term] = struct{}{}
+				}
+			}
+			if len(terms) == 0 {
+				terms[1] = struct{}{}
+			}
+
+			for term := 1; term <= 4; term++ {
+				if _, ok := terms[term]; ok {
+					data = append(data, map[string]string{"Text": fmt.Sprintf("Term %v", term), "Value": engageTerm(academicYear, term)})
+				}
+			}
+		}
+
+	case "GetPupilMarksheetSubjects":
+		if student == nil {
+			break
+		}
+
+		seen := make(map[string]struct{})
+		for _, academicYear := range splitInts(req.AcademicYears) {
+			for _, subject := range student.Subjects[academicYear] {
+				if _, ok := seen[subject.EngageCode]; ok {
+					continue
+				}
+				seen[subject.EngageCode] = struct{}{}
+
+				data = append(data, map[string]string{"Text": subject.Name, "Value": subject.EngageCode})
+			}
+		}
+
+	case "RenderPupilMarksheet":
+		if student == nil {
+			return respond(http.StatusNotFound, map[string]string{"Message": "pupil not found"})
+		}
+
+		return respondRender(render(student, splitInts(req.AcademicYear), req.ReportingPeriodList, req.ColumnList))
+
+	default:
+		return respond(http.StatusNotFound, map[string]string{"Message": "unknown endpoint"})
+	}
+
+	return respond(http.StatusOK, map[string]interface{}{"d": data})
+}
+
+// render renders the name and current year of the student and the marks in the academic
+// years, the reporting periods and columns filter the marks only if any are requested.
+//
+// Each mark is rendered as: \t<percentage>, <subject>, <teacher><br>
+func render(student *Student, academicYears []int, reportingPeriods, columns string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<td><a>%v</a></td>", student.Name)
+	if containsInt(student.academicYears(), csb.CurrentAcademicYear) {
+		fmt.Fprintf(&b, "<td>Year %v</td>", student.CurrentYear)
+	}
+
+	b.WriteString("<td>")
+	for _, mark := range student.Marks {
+		if !containsInt(academicYears, mark.Period.AcademicYear) {
+			continue
+		}
+		if reportingPeriods != "" && !containsString(strings.Split(reportingPeriods, ","), engageTerm(mark.Period.AcademicYear, *mark.Period.Term)) {
+			continue
+		}
+		if columns != "" && !containsString(strings.Split(columns, "|||"), *mark.Period.Importance) {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\t%v, %v, %v<br>", mark.Percentage, mark.Subject.Name, mark.Teacher)
+	}
+	b.WriteString("</td>")
+
+	return b.String()
+}
+
+func respond(code int, v interface{}) (*http.Response, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode:    code,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(buf)),
+		ContentLength: int64(len(buf)),
+	}, nil
+}
+
+// respondRender responds with the render as the json string engage sends it in, the tags
+// arent escaped.
+func respondRender(html string) (*http.Response, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(map[string]string{"d": html}); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(&buf),
+		ContentLength: int64(buf.Len()),
+	}, nil
+}
+
+// PeriodService returns a period service over the marks of the students in engage.
+func (e *Engage) PeriodService() csb.PeriodService {
+	return &periodService{e: e}
+}
+
+var _ csb.PeriodService = (*periodService)(nil)
+
+// periodService builds the periods from the marks of the students in the fake engage source.
+type periodService struct {
+	e *Engage
+}
+
+func (s *periodService) studentPeriods(pid int) ([]csb.Period, error) {
+	s.e.mu.Lock()
+	defer s.e.mu.Unlock()
+
+	student, ok := s.e.students[pid]
+	if !ok {
+		return nil, csb.Errorf(csb.ENOTFOUND, "student not found")
+	}
+
+	return student.periods(), nil
+}
+
+// BuildPeriods returns the full periods of the student in the academic year and term, all
+// the terms if term is 0.
+func (s *periodService) BuildPeriods(ctx context.Context, pid int, academicYear int, term int) ([]csb.Period, error) {
+	periods, err := s.studentPeriods(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]csb.Period, 0)
+	for _, period := range periods {
+		if period.AcademicYear == academicYear && (term == 0 || *period.Term == term) {
+			out = append(out, period)
+		}
+	}
+
+	return out, nil
+}
+
+// Exists checks wether the student has marks in the populated fields of the period.
+func (s *periodService) Exists(ctx context.Context, pid int, period csb.Period) (bool, error) {
+	periods, err := s.studentPeriods(pid)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range periods {
+		if inPeriod(p, period) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// PeriodToEngageTerm returns the engage reporting period of the period.
+func (s *periodService) PeriodToEngageTerm(ctx context.Context, pid int, period csb.Period) (string, error) {
+	if period.Term == nil {
+		return "", csb.Errorf(csb.EINVALID, "period missing term")
+	}
+
+	return engageTerm(period.AcademicYear, *period.Term), nil
+}
+
+// PeriodRange returns the full periods of the student between the populated fields of from
+// and to.
+func (s *periodService) PeriodRange(ctx context.Context, pid int, from, to csb.Period) ([]csb.Period, error) {
+	if err := from.Validate(); err != nil {
+		return nil, err
+	}
+	if err := to.Validate(); err != nil {
+		return nil, err
+	}
+	if comparePeriod(to, from) < 0 {
+		return nil, csb.Errorf(csb.EINVALID, "to period is before from period")
+	}
+
+	periods, err := s.studentPeriods(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]csb.Period, 0)
+	for _, period := range periods {
+		if comparePeriod(period, from) >= 0 && comparePeriod(period, to) <= 0 {
+			out = append(out, period)
+		}
+	}
+
+	return out, nil
+}
+
+// comparePeriod compares the full period a to the populated fields of b, returning 0 if a
+// is in b.
+func comparePeriod(a, b csb.Period) int {
+	switch {
+	case a.AcademicYear != b.AcademicYear:
+		return a.AcademicYear - b.AcademicYear
+	case b.Term == nil || a.Term == nil:
+		return 0
+	case *a.Term != *b.Term:
+		return *a.Term - *b.Term
+	case b.Importance == nil || a.Importance == nil:
+		return 0
+	default:
+		return strings.Compare(*a.Importance, *b.Importance)
+	}
+}
+
+// inPeriod reports wether the full period a is in the populated fields of b.
+func inPeriod(a, b csb.Period) bool {
+	return comparePeriod(a, b) == 0
+}
+
+func lessPeriod(a, b csb.Period) bool {
+	if a.AcademicYear != b.AcademicYear {
+		return a.AcademicYear < b.AcademicYear
+	}
+	if *a.Term != *b.Term {
+		return *a.Term < *b.Term
+	}
+	return *a.Importance < *b.Importance
+}
+
+func splitInts(s string) []int {
+	out := make([]int, 0)
+	for _, v := range strings.Split(s, ",") {
+		if i, err := strconv.Atoi(v); err == nil {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package csbtest
+
+import (
+	"context"
+	"testing"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+// TestMarkService checks the mark service of the backend against the contract of
+// csb.MarkService. The marks are stored by refreshing them from engage. The subtests share
+// the services and run in order, the subtests changing the marks run last.
+func TestMarkService(t *testing.T, newServices Constructor) {
+	ctx := context.Background()
+	previous, current := csb.CurrentAcademicYear-1, csb.CurrentAcademicYear
+
+	e := NewEngage()
+	e.SetStudent(&Student{
+		PID:         2001,
+		Name:        "Ana Popescu",
+		CurrentYear: 11,
+		Subjects: map[int][]csb.Subject{
+			previous: {mathematics, physics},
+			current:  {mathematics, physics},
+		},
+		Marks: []*csb.Mark{
+			newMark(previous, 1, "Midterm", mathematics, "Mr Smith", 70),
+			newMark(previous, 1, "Midterm", physics, "Ms Jones", 55),
+			newMark(previous, 2, "Final", mathematics, "Mr Smith", 80),
+			newMark(current, 1, "Midterm", mathematics, "Mr Smith", 90),
+		},
+	})
+
+	services := newServices(t, e)
+	s := services.Marks
+	expectNoError(t, services.Students.RefreshStudents(ctx, csb.RefreshStudents{StartPID: 2001, N: 1}))
+	expectNoError(t, s.RefreshMarks(ctx, 2001, csb.Period{AcademicYear: previous}, csb.Period{AcademicYear: current}))
+
+	t.Run("FindMarksByPID", func(t *testing.T) {
+		marks, err := s.FindMarksByPID(ctx, 2001)
+		expectNoError(t, err)
+
+		if percentages := markPercentages(marks); !sameInts(percentages, 70, 55, 80, 90) {
+			t.Fatalf("unexpected marks: %v", percentages)
+		}
+		for _, mark := range marks {
+			if mark.StudentID != 2001 || mark.SubjectID == 0 || mark.Subject.EngageCode == "" || mark.TeacherID == 0 {
+				t.Fatalf("expected mark linked to its student, subject and teacher, got: %+v", mark)
+			}
+		}
+	})
+
+	t.Run("FindMarkByID", func(t *testing.T) {
+		marks, _, err := s.FindMarks(ctx, csb.MarksFilter{PID: intPtr(2001), Limit: 1})
+		expectNoError(t, err)
+		if len(marks) != 1 {
+			t.Fatalf("expected 1 mark, got: %v", len(marks))
+		}
+		id := marks[0].ID
+
+		mark, err := s.FindMarkByID(ctx, id, nil)
+		expectNoError(t, err)
+		if mark.ID != id || mark.Student != nil {
+			t.Fatalf("expected mark %v without student, got: %+v", id, mark)
+		}
+
+		mark, err = s.FindMarkByID(ctx, id, csb.Include{"student.subjects"})
+		expectNoError(t, err)
+		if mark.Student == nil || mark.Student.PID != 2001 || len(mark.Student.Subjects) != 2 {
+			t.Fatalf("expected mark with student and subjects, got: %+v", mark.Student)
+		}
+
+		_, err = s.FindMarkByID(ctx, id, csb.Include{"teacher"})
+		expectCode(t, err, csb.EINVALID)
+
+		_, err = s.FindMarkByID(ctx, -1, nil)
+		expectCode(t, err, csb.ENOTFOUND)
+	})
+
+	t.Run("FindMarks", func(t *testing.T) {
+		for _, tc := range []struct {
+			name        string
+			filter      csb.MarksFilter
+			percentages []int
+		}{
+			{"All", csb.MarksFilter{}, []int{70, 55, 80, 90}},
+			{"PID", csb.MarksFilter{PID: intPtr(2999)}, []int{}},
+			{"Teacher", csb.MarksFilter{Teacher: stringPtr("Ms Jones")}, []int{55}},
+			{"MinPercentage", csb.MarksFilter{MinPercentage: intPtr(75)}, []int{80, 90}},
+			{"MaxPercentage", csb.MarksFilter{MaxPercentage: intPtr(70)}, []int{70, 55}},
+			{"AcademicYear", csb.MarksFilter{Periods: []csb.Period{{AcademicYear: previous}}}, []int{70, 55, 80}},
+			{"Term", csb.MarksFilter{Periods: []csb.Period{{AcademicYear: previous, Term: intPtr(1)}}}, []int{70, 55}},
+			{"Periods", csb.MarksFilter{Periods: []csb.Period{{AcademicYear: previous, Term: intPtr(2)}, {AcademicYear: current}}}, []int{80, 90}},
+			{"Subject", csb.MarksFilter{Subjects: []csb.Subject{{Name: physics.Name}}}, []int{55}},
+			{"Subjects", csb.MarksFilter{Subjects: []csb.Subject{{EngageCode: physics.EngageCode}, {EngageCode: mathematics.EngageCode}}}, []int{70, 55, 80, 90}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				marks, page, err := s.FindMarks(ctx, tc.filter)
+				expectNoError(t, err)
+
+				if percentages := markPercentages(marks); !sameInts(percentages, tc.percentages...) {
+					t.Fatalf("expected marks %v, got: %v", tc.percentages, percentages)
+				}
+				if page.Total != len(tc.percentages) {
+					t.Fatalf("expected total %v, got: %v", len(tc.percentages), page.Total)
+				}
+			})
+		}
+	})
+
+	t.Run("FindMarksPage", func(t *testing.T) {
+		marks, page, err := s.FindMarks(ctx, csb.MarksFilter{Sort: "-percentage", Limit: 3})
+		expectNoError(t, err)
+		if percentages := markPercentages(marks); len(percentages) != 3 || percentages[0] != 90 || percentages[1] != 80 || percentages[2] != 70 {
+			t.Fatalf("expected marks ordered by percentage descending, got: %v", percentages)
+		}
+		if page.Total != 4 || page.NextCursor == "" {
+			t.Fatalf("unexpected first page: %+v", page)
+		}
+
+		marks, page, err = s.FindMarks(ctx, csb.MarksFilter{Sort: "-percentage", Limit: 3, Cursor: page.NextCursor})
+		expectNoError(t, err)
+		if percentages := markPercentages(marks); !sameInts(percentages, 55) || page.NextCursor != "" {
+			t.Fatalf("unexpected last page: %v %+v", percentages, page)
+		}
+
+		_, _, err = s.FindMarks(ctx, csb.MarksFilter{Sort: "teacher"})
+		expectCode(t, err, csb.EINVALID)
+
+		_, _, err = s.FindMarks(ctx, csb.MarksFilter{Include: csb.Include{"subjects"}})
+		expectCode(t, err, csb.EINVALID)
+	})
+
+	t.Run("FindMarksByPeriod", func(t *testing.T) {
+		marks, err := s.FindMarksByPeriod(ctx, 2001, csb.Period{AcademicYear: previous, Term: intPtr(1)})
+		expectNoError(t, err)
+		if percentages := markPercentages(marks); !sameInts(percentages, 70, 55) {
+			t.Fatalf("expected marks of term 1, got: %v", percentages)
+		}
+
+		marks, err = s.FindMarksByPeriod(ctx, 2001, csb.Period{AcademicYear: previous, Term: intPtr(2), Importance: stringPtr("Final")})
+		expectNoError(t, err)
+		if percentages := markPercentages(marks); !sameInts(percentages, 80) {
+			t.Fatalf("expected marks of the full period, got: %v", percentages)
+		}
+	})
+
+	t.Run("FindMarksByPeriodRange", func(t *testing.T) {
+		from, to := csb.Period{AcademicYear: previous}, csb.Period{AcademicYear: previous}
+
+		_, err := s.FindMarksByPeriodRange(ctx, from, to, csb.MarksFilter{})
+		expectCode(t, err, csb.EINVALID)
+
+		marks, err := s.FindMarksByPeriodRange(ctx, from, to, csb.MarksFilter{PID: intPtr(2001)})
+		expectNoError(t, err)
+		if percentages := markPercentages(marks); !sameInts(percentages, 70, 55, 80) {
+			t.Fatalf("expected marks of %v, got: %v", previous, percentages)
+		}
+
+		marks, err = s.FindMarksByPeriodRange(ctx, from, csb.Period{AcademicYear: current}, csb.MarksFilter{
+			PID:      intPtr(2001),
+			Subjects: []csb.Subject{{Name: mathematics.Name}},
+		})
+		expectNoError(t, err)
+		if percentages := markPercentages(marks); !sameInts(percentages, 70, 80, 90) {
+			t.Fatalf("expected mathematics marks, got: %v", percentages)
+		}
+	})
+
+	t.Run("RefreshMarks", func(t *testing.T) {
+		e.AddMark(2001, newMark(current, 1, "Midterm", physics, "Ms Jones", 60))
+		e.AddMark(2001, newMark(current, 2, "Midterm", physics, "Ms Jones", 65))
+
+		// refreshing twice only adds the new marks once.
+		for i := 0; i < 2; i++ {
+			expectNoError(t, s.RefreshMarks(ctx, 2001, csb.Period{AcademicYear: current}, csb.Period{AcademicYear: current}))
+		}
+
+		marks, err := s.FindMarksByPID(ctx, 2001)
+		expectNoError(t, err)
+		if percentages := markPercentages(marks); !sameInts(percentages, 70, 55, 80, 90, 60, 65) {
+			t.Fatalf("expected new marks, got: %v", percentages)
+		}
+
+		expectCode(t, s.RefreshMarks(ctx, 2999, csb.Period{AcademicYear: current}, csb.Period{AcademicYear: current}), csb.ENOTFOUND)
+	})
+
+	t.Run("DeleteMark", func(t *testing.T) {
+		marks, err := s.FindMarksByPID(ctx, 2001)
+		expectNoError(t, err)
+		if len(marks) == 0 {
+			t.Fatalf("expected marks to delete")
+		}
+		id := marks[0].ID
+
+		expectNoError(t, s.DeleteMark(ctx, id))
+		expectCode(t, s.DeleteMark(ctx, id), csb.ENOTFOUND)
+
+		_, err = s.FindMarkByID(ctx, id, nil)
+		expectCode(t, err, csb.ENOTFOUND)
+	})
+
+	t.Run("DeleteStudentMarks", func(t *testing.T) {
+		expectNoError(t, services.Students.DeleteStudent(ctx, 2001))
+
+		marks, _, err := s.FindMarks(ctx, csb.MarksFilter{PID: intPtr(2001)})
+		expectNoError(t, err)
+		if len(marks) != 0 {
+			t.Fatalf("expected the marks of the deleted student to be deleted, got: %v", markPercentages(marks))
+		}
+	})
+}
+
+func markPercentages(marks []*csb.Mark) []int {
+	out := make([]int, 0, len(marks))
+	for _, mark := range marks {
+		out = append(out, mark.Percentage)
+	}
+	return out
+}
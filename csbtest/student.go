@@ -0,0 +1,242 @@
+package csbtest
+
+import (
+	"context"
+	"testing"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+// TestStudentService checks the student service of the backend against the contract of
+// csb.StudentService. The subtests share the services and run in order, the subtests
+// changing the students run last.
+func TestStudentService(t *testing.T, newServices Constructor) {
+	ctx := context.Background()
+
+	e := NewEngage()
+	e.SetStudent(&Student{
+		PID:         1001,
+		Name:        "Ana Popescu",
+		CurrentYear: 11,
+		Subjects: map[int][]csb.Subject{
+			csb.CurrentAcademicYear - 1: {mathematics, physics},
+			csb.CurrentAcademicYear:     {mathematics, chemistry},
+		},
+	})
+	e.SetStudent(&Student{
+		PID:         1002,
+		Name:        "Mihai Ionescu",
+		CurrentYear: 12,
+		Subjects: map[int][]csb.Subject{
+			csb.CurrentAcademicYear: {english, mathematics},
+		},
+	})
+	// 1003 isnt in engage.
+	e.SetStudent(&Student{
+		PID:  1004,
+		Name: "Elena Marin",
+		Subjects: map[int][]csb.Subject{
+			csb.CurrentAcademicYear - 1: {english},
+		},
+	})
+
+	s := newServices(t, e).Students
+	expectNoError(t, s.RefreshStudents(ctx, csb.RefreshStudents{StartPID: 1001, N: 4}))
+
+	t.Run("FindStudentByPID", func(t *testing.T) {
+		student, err := s.FindStudentByPID(ctx, 1001, nil)
+		expectNoError(t, err)
+
+		if student.Name != "Ana Popescu" || student.CurrentYear != 11 || !student.AttendsSchool {
+			t.Fatalf("unexpected student: %+v", student)
+		}
+		if student.Subjects != nil || student.SubjectHistory != nil || student.Marks != nil {
+			t.Fatalf("expected no associations without include, got: %+v", student)
+		}
+
+		student, err = s.FindStudentByPID(ctx, 1004, nil)
+		expectNoError(t, err)
+		if student.AttendsSchool {
+			t.Fatalf("expected student not attending school, got: %+v", student)
+		}
+	})
+
+	t.Run("FindStudentByPIDSubjects", func(t *testing.T) {
+		student, err := s.FindStudentByPID(ctx, 1001, csb.Include{"subjects"})
+		expectNoError(t, err)
+
+		if codes := subjectCodes(student.Subjects); !sameStrings(codes, "CL1-103", "CL1-125", "CL1-108") {
+			t.Fatalf("unexpected subjects: %v", codes)
+		}
+		if len(student.SubjectHistory) != 2 {
+			t.Fatalf("expected 2 academic years of subjects, got: %+v", student.SubjectHistory)
+		}
+		for i, academicYear := range []int{csb.CurrentAcademicYear - 1, csb.CurrentAcademicYear} {
+			if student.SubjectHistory[i].AcademicYear != academicYear {
+				t.Fatalf("expected academic year %v, got: %v", academicYear, student.SubjectHistory[i].AcademicYear)
+			}
+		}
+		if codes := subjectCodes(student.SubjectHistory[1].Subjects); !sameStrings(codes, "CL1-103", "CL1-108") {
+			t.Fatalf("unexpected subjects in %v: %v", csb.CurrentAcademicYear, codes)
+		}
+	})
+
+	t.Run("FindStudentByPIDNotFound", func(t *testing.T) {
+		_, err := s.FindStudentByPID(ctx, 1003, nil)
+		expectCode(t, err, csb.ENOTFOUND)
+	})
+
+	t.Run("FindStudentByPIDInvalidInclude", func(t *testing.T) {
+		_, err := s.FindStudentByPID(ctx, 1001, csb.Include{"teachers"})
+		expectCode(t, err, csb.EINVALID)
+	})
+
+	t.Run("FindStudents", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			filter csb.StudentFilter
+			pids   []int
+		}{
+			{"All", csb.StudentFilter{}, []int{1001, 1002, 1004}},
+			{"PID", csb.StudentFilter{PID: intPtr(1002)}, []int{1002}},
+			{"Name", csb.StudentFilter{Name: stringPtr("Popescu")}, []int{1001}},
+			{"CurrentYear", csb.StudentFilter{CurrentYear: intPtr(12)}, []int{1002}},
+			{"AttendsSchool", csb.StudentFilter{AttendsSchool: boolPtr(false)}, []int{1004}},
+			{"Subject", csb.StudentFilter{Subjects: []csb.Subject{{EngageCode: mathematics.EngageCode}}}, []int{1001, 1002}},
+			{"Subjects", csb.StudentFilter{Subjects: []csb.Subject{{Name: mathematics.Name}, {Name: chemistry.Name}}}, []int{1001}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				students, page, err := s.FindStudents(ctx, tc.filter)
+				expectNoError(t, err)
+
+				if pids := studentPIDs(students); !sameInts(pids, tc.pids...) {
+					t.Fatalf("expected students %v, got: %v", tc.pids, pids)
+				}
+				if page.Total != len(tc.pids) {
+					t.Fatalf("expected total %v, got: %v", len(tc.pids), page.Total)
+				}
+			})
+		}
+	})
+
+	t.Run("FindStudentsPage", func(t *testing.T) {
+		students, page, err := s.FindStudents(ctx, csb.StudentFilter{Limit: 2})
+		expectNoError(t, err)
+		if pids := studentPIDs(students); !sameInts(pids, 1001, 1002) || page.Total != 3 || page.NextCursor == "" {
+			t.Fatalf("unexpected first page: %v %+v", pids, page)
+		}
+
+		students, page, err = s.FindStudents(ctx, csb.StudentFilter{Limit: 2, Cursor: page.NextCursor})
+		expectNoError(t, err)
+		if pids := studentPIDs(students); !sameInts(pids, 1004) || page.NextCursor != "" {
+			t.Fatalf("unexpected last page: %v %+v", pids, page)
+		}
+
+		students, _, err = s.FindStudents(ctx, csb.StudentFilter{Sort: "-name"})
+		expectNoError(t, err)
+		if pids := studentPIDs(students); len(pids) != 3 || pids[0] != 1002 || pids[1] != 1004 || pids[2] != 1001 {
+			t.Fatalf("expected students ordered by name descending, got: %v", pids)
+		}
+
+		_, _, err = s.FindStudents(ctx, csb.StudentFilter{Sort: "created_at"})
+		expectCode(t, err, csb.EINVALID)
+
+		_, _, err = s.FindStudents(ctx, csb.StudentFilter{Cursor: "not a cursor"})
+		expectCode(t, err, csb.EINVALID)
+	})
+
+	t.Run("RefreshStudentsUpdate", func(t *testing.T) {
+		e.SetStudent(&Student{
+			PID:         1002,
+			Name:        "Mihai Ionescu-Pop",
+			CurrentYear: 13,
+			Subjects: map[int][]csb.Subject{
+				csb.CurrentAcademicYear: {english, physics},
+			},
+		})
+		expectNoError(t, s.RefreshStudents(ctx, csb.RefreshStudents{StartPID: 1002, N: 1}))
+
+		student, err := s.FindStudentByPID(ctx, 1002, csb.Include{"subjects"})
+		expectNoError(t, err)
+		if student.Name != "Mihai Ionescu-Pop" || student.CurrentYear != 13 {
+			t.Fatalf("expected refreshed student, got: %+v", student)
+		}
+		if codes := subjectCodes(student.Subjects); !sameStrings(codes, "CL1-102", "CL1-125") {
+			t.Fatalf("expected refreshed subjects, got: %v", codes)
+		}
+	})
+
+	t.Run("RefreshStudentsPurge", func(t *testing.T) {
+		expectNoError(t, s.RefreshStudents(ctx, csb.RefreshStudents{StartPID: 1004, N: 1, Purge: true}))
+
+		students, _, err := s.FindStudents(ctx, csb.StudentFilter{PID: intPtr(1004)})
+		expectNoError(t, err)
+		if len(students) != 0 {
+			t.Fatalf("expected student not attending school to be purged, got: %v", studentPIDs(students))
+		}
+	})
+
+	t.Run("DeleteStudent", func(t *testing.T) {
+		expectNoError(t, s.DeleteStudent(ctx, 1001))
+		expectCode(t, s.DeleteStudent(ctx, 1001), csb.ENOTFOUND)
+		expectCode(t, s.DeleteStudent(ctx, 1003), csb.ENOTFOUND)
+
+		students, _, err := s.FindStudents(ctx, csb.StudentFilter{PID: intPtr(1001)})
+		expectNoError(t, err)
+		if len(students) != 0 {
+			t.Fatalf("expected deleted student, got: %v", studentPIDs(students))
+		}
+	})
+}
+
+func studentPIDs(students []*csb.Student) []int {
+	out := make([]int, 0, len(students))
+	for _, student := range students {
+		out = append(out, student.PID)
+	}
+	return out
+}
+
+func subjectCodes(subjects []csb.Subject) []string {
+	out := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		out = append(out, subject.EngageCode)
+	}
+	return out
+}
+
+// sameInts reports wether got holds the same values as want, in any order.
+func sameInts(got []int, want ...int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	count := make(map[int]int, len(want))
+	for _, v := range want {
+		count[v]++
+	}
+	for _, v := range got {
+		if count[v]--; count[v] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sameStrings reports wether got holds the same values as want, in any order.
+func sameStrings(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	count := make(map[string]int, len(want))
+	for _, v := range want {
+		count[v]++
+	}
+	for _, v := range got {
+		if count[v]--; count[v] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS alert_rules(
+    id INTEGER PRIMARY KEY,
+    kind TEXT NOT NULL,
+    threshold INTEGER NOT NULL,
+    student_id INTEGER, -- scopes, NULL matches anything.
+    year INTEGER,
+    subject_id INTEGER,
+    created_at DATE NOT NULL,
+
+    FOREIGN KEY (student_id)
+        REFERENCES students (pid)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+    FOREIGN KEY (subject_id)
+        REFERENCES subjects (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+
+    CHECK (kind IN ('below_threshold', 'drop'))
+    CHECK (threshold >= 0 AND threshold <= 100)
+);
+
+CREATE TABLE IF NOT EXISTS alerts(
+    id INTEGER PRIMARY KEY,
+    rule_id INTEGER NOT NULL,
+    mark_id INTEGER NOT NULL,
+    student_id INTEGER NOT NULL,
+    previous_percentage INTEGER,
+    created_at DATE NOT NULL,
+
+    UNIQUE(rule_id, mark_id), -- a rule matches a mark only once.
+
+    FOREIGN KEY (rule_id)
+        REFERENCES alert_rules (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+    FOREIGN KEY (mark_id)
+        REFERENCES marks (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+    FOREIGN KEY (student_id)
+        REFERENCES students (pid)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
\ No newline at end of file
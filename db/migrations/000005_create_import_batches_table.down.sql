@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS marks_import_batch_id;
+ALTER TABLE marks DROP COLUMN import_batch_id;
+DROP TABLE IF EXISTS import_batches;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS import_batches(
+    id INTEGER PRIMARY KEY,
+    row_count INTEGER NOT NULL,
+    created_at DATE NOT NULL,
+    rolled_back_at DATE
+);
+
+-- marks which didnt come from engage link to the batch they were imported with.
+ALTER TABLE marks ADD COLUMN import_batch_id INTEGER;
+CREATE INDEX IF NOT EXISTS marks_import_batch_id ON marks (import_batch_id);
\ No newline at end of file
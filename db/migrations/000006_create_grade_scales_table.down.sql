@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS grade_boundaries;
+DROP TABLE IF EXISTS grade_scales;
\ No newline at end of file
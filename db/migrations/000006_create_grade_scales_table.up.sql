@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS grade_scales(
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    subject_id INTEGER, -- scopes, NULL matches anything.
+    year INTEGER,
+    academic_year INTEGER,
+    created_at DATE NOT NULL,
+
+    FOREIGN KEY (subject_id)
+        REFERENCES subjects (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+-- a family has only one scale for each scope.
+CREATE UNIQUE INDEX IF NOT EXISTS grade_scales_scope ON grade_scales (
+    name,
+    IFNULL(subject_id, 0),
+    IFNULL(year, 0),
+    IFNULL(academic_year, 0)
+);
+
+CREATE TABLE IF NOT EXISTS grade_boundaries(
+    scale_id INTEGER NOT NULL,
+    grade TEXT NOT NULL,
+    min_percentage INTEGER NOT NULL,
+
+    UNIQUE(scale_id, grade),
+    UNIQUE(scale_id, min_percentage),
+
+    FOREIGN KEY (scale_id)
+        REFERENCES grade_scales (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+
+    CHECK (min_percentage >= 0 AND min_percentage <= 100)
+);
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS rank_subjects;
+DROP TABLE IF EXISTS ranks;
+DROP TABLE IF EXISTS weight_profile_subjects;
+DROP TABLE IF EXISTS weight_profile_importances;
+DROP TABLE IF EXISTS weight_profiles;
\ No newline at end of file
@@ -0,0 +1,80 @@
+CREATE TABLE IF NOT EXISTS weight_profiles(
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    created_at DATE NOT NULL,
+    -- deleted versions are archived, not removed, since the ranks scored with them keep
+    -- referencing them by name and version.
+    archived_at DATE,
+
+    UNIQUE(name, version) -- versions are never updated, only added.
+);
+
+CREATE TABLE IF NOT EXISTS weight_profile_importances(
+    profile_id INTEGER NOT NULL,
+    importance TEXT NOT NULL,
+    weight REAL NOT NULL,
+
+    UNIQUE(profile_id, importance),
+
+    FOREIGN KEY (profile_id)
+        REFERENCES weight_profiles (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+
+    CHECK (weight >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS weight_profile_subjects(
+    profile_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    weight REAL NOT NULL,
+
+    UNIQUE(profile_id, subject_id),
+
+    FOREIGN KEY (profile_id)
+        REFERENCES weight_profiles (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+    FOREIGN KEY (subject_id)
+        REFERENCES subjects (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+
+    CHECK (weight >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS ranks(
+    id INTEGER PRIMARY KEY,
+    student_id INTEGER NOT NULL,
+    score INTEGER NOT NULL,
+    weight_profile TEXT, -- name and version of the weight profile, NULL for plain averages.
+    weight_profile_version INTEGER,
+    academic_year INTEGER NOT NULL,
+    term INTEGER, -- the period of a rank doesnt have to be full.
+    importance TEXT,
+    generated_at DATE NOT NULL,
+
+    FOREIGN KEY (student_id)
+        REFERENCES students (pid)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS ranks_student_id ON ranks (student_id);
+
+CREATE TABLE IF NOT EXISTS rank_subjects(
+    rank_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+
+    UNIQUE(rank_id, subject_id),
+
+    FOREIGN KEY (rank_id)
+        REFERENCES ranks (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+    FOREIGN KEY (subject_id)
+        REFERENCES subjects (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS marks_teacher_id;
+ALTER TABLE marks DROP COLUMN teacher_id;
+DROP TABLE IF EXISTS teacher_aliases;
+DROP TABLE IF EXISTS teachers;
\ No newline at end of file
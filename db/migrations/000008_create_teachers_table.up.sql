@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS teachers(
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at DATE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS teacher_aliases(
+    teacher_id INTEGER NOT NULL,
+    alias TEXT NOT NULL COLLATE NOCASE,
+
+    UNIQUE(alias), -- a spelling belongs to one teacher only.
+
+    FOREIGN KEY (teacher_id)
+        REFERENCES teachers (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+-- marks link to the teacher of their spelling.
+ALTER TABLE marks ADD COLUMN teacher_id INTEGER;
+CREATE INDEX IF NOT EXISTS marks_teacher_id ON marks (teacher_id);
+
+-- backfill a teacher for each spelling of the existing marks, spellings only differing in
+-- case or outer spaces are the same teacher.
+INSERT INTO teachers (name, created_at)
+SELECT MIN(TRIM(teacher)), CURRENT_TIMESTAMP
+FROM marks
+GROUP BY TRIM(teacher) COLLATE NOCASE;
+
+INSERT INTO teacher_aliases (teacher_id, alias)
+SELECT id, name FROM teachers;
+
+UPDATE marks SET teacher_id = (
+    SELECT teacher_id FROM teacher_aliases WHERE alias = TRIM(marks.teacher)
+);
\ No newline at end of file
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS student_takes_once(
+    student_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+
+    UNIQUE(student_id, subject_id), -- one student takes a subject only once.
+
+    FOREIGN KEY (student_id)
+        REFERENCES students (pid)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+    FOREIGN KEY (subject_id)
+        REFERENCES subjects (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+INSERT INTO student_takes_once (student_id, subject_id)
+SELECT DISTINCT student_id, subject_id FROM student_takes;
+
+INSERT OR IGNORE INTO student_takes_once (student_id, subject_id)
+SELECT student_id, subject_id FROM student_takes_unplaced;
+
+DROP TABLE IF EXISTS student_takes_unplaced;
+
+DROP TABLE IF EXISTS student_takes;
+ALTER TABLE student_takes_once RENAME TO student_takes;
\ No newline at end of file
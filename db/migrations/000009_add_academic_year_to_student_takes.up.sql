@@ -0,0 +1,51 @@
+-- subject membership is kept per academic year, the unique constraint has to change so the
+-- table is rebuilt.
+CREATE TABLE IF NOT EXISTS student_takes_by_year(
+    student_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    academic_year INTEGER NOT NULL,
+
+    UNIQUE(student_id, subject_id, academic_year), -- one student takes a subject once a year.
+
+    FOREIGN KEY (student_id)
+        REFERENCES students (pid)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+    FOREIGN KEY (subject_id)
+        REFERENCES subjects (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+-- place the existing memberships in the academic years the student has marks in.
+INSERT INTO student_takes_by_year (student_id, subject_id, academic_year)
+SELECT DISTINCT student_takes.student_id, student_takes.subject_id, marks.academic_year
+FROM student_takes
+JOIN marks ON marks.student_id = student_takes.student_id AND marks.subject_id = student_takes.subject_id;
+
+-- memberships without marks are placed in the current academic year by DB.Open, which knows
+-- it. they wait here until then.
+CREATE TABLE IF NOT EXISTS student_takes_unplaced(
+    student_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+
+    FOREIGN KEY (student_id)
+        REFERENCES students (pid)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+    FOREIGN KEY (subject_id)
+        REFERENCES subjects (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+INSERT INTO student_takes_unplaced (student_id, subject_id)
+SELECT student_takes.student_id, student_takes.subject_id
+FROM student_takes
+WHERE NOT EXISTS (
+    SELECT 1 FROM marks
+    WHERE marks.student_id = student_takes.student_id AND marks.subject_id = student_takes.subject_id
+);
+
+DROP TABLE student_takes;
+ALTER TABLE student_takes_by_year RENAME TO student_takes;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS erasure_requests_pid;
+DROP TABLE IF EXISTS erasure_requests;
+ALTER TABLE students DROP COLUMN left_at;
\ No newline at end of file
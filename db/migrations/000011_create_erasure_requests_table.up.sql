@@ -0,0 +1,17 @@
+-- the time the students left the school, NULL while they attend it. the students who already
+-- left are backfilled with their last update.
+ALTER TABLE students ADD COLUMN left_at DATE;
+UPDATE students SET left_at = updated_at WHERE attends_school = 0;
+
+CREATE TABLE IF NOT EXISTS erasure_requests(
+    id INTEGER PRIMARY KEY,
+    pid INTEGER NOT NULL, -- no foreign key, the data of the student is gone.
+    action TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    removed TEXT NOT NULL, -- json object of the removed record counts by kind.
+    created_at DATE NOT NULL,
+
+    CHECK (action IN ('anonymize', 'delete'))
+);
+
+CREATE INDEX IF NOT EXISTS erasure_requests_pid ON erasure_requests (pid);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS audit_summaries_student_pid;
+DROP TABLE IF EXISTS audit_summaries;
+DROP TRIGGER IF EXISTS audit_log_no_delete;
+DROP TRIGGER IF EXISTS audit_log_no_update;
+DROP INDEX IF EXISTS audit_log_created_at;
+DROP INDEX IF EXISTS audit_log_actor;
+DROP INDEX IF EXISTS audit_log_target;
+DROP TABLE IF EXISTS audit_log;
\ No newline at end of file
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS audit_log(
+    id INTEGER PRIMARY KEY,
+    actor TEXT NOT NULL,
+    operation TEXT NOT NULL,
+    target TEXT NOT NULL,
+    target_id INTEGER NOT NULL, -- no foreign key, the entries outlive their targets.
+    created_at DATE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_target ON audit_log (target, target_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor);
+CREATE INDEX IF NOT EXISTS audit_log_created_at ON audit_log (created_at);
+
+-- the audit log is append-only.
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+-- the summaries of the audit entries are kept apart from the append-only log, tied to the
+-- student they are about so the erasure of the student can clear them. the log keeps who
+-- changed which record and when, the summaries of what changed go away with the student.
+CREATE TABLE IF NOT EXISTS audit_summaries(
+    entry_id INTEGER PRIMARY KEY,
+    student_pid INTEGER, -- no foreign key, the summaries outlive the deletions of the student.
+    before TEXT, -- json summaries of the target around the change.
+    after TEXT,
+
+    FOREIGN KEY (entry_id)
+        REFERENCES audit_log (id)
+            ON DELETE NO ACTION
+            ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS audit_summaries_student_pid ON audit_summaries (student_pid);
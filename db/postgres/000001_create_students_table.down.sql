@@ -0,0 +1 @@
+DROP TABLE IF EXISTS students;
\ No newline at end of file
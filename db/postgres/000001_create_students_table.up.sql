@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS students (
+    pid INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    current_year INTEGER,
+    attends_school BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS student_takes;
+DROP TABLE IF EXISTS subjects;
\ No newline at end of file
@@ -0,0 +1,61 @@
+CREATE TABLE IF NOT EXISTS subjects(
+    id SERIAL PRIMARY KEY,
+    engage_code TEXT NOT NULL,
+    name TEXT NOT NULL,
+
+    UNIQUE(engage_code),
+    UNIQUE(name)
+);
+
+-- many 2 many relationships:
+CREATE TABLE IF NOT EXISTS student_takes(
+    student_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    academic_year INTEGER NOT NULL,
+
+    UNIQUE(student_id, subject_id, academic_year), -- one student takes a subject once a year.
+
+    FOREIGN KEY (student_id)
+        REFERENCES students (pid)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION,
+    FOREIGN KEY (subject_id)
+        REFERENCES subjects (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+-- prepopulate the subjects known to engage.
+INSERT INTO subjects (engage_code, name)
+VALUES
+    ('CL1-106', 'Biology'),
+    ('CL1-108', 'Chemistry'),
+    ('CL1-120', 'Computer Science'),
+    ('CL1-112', 'Economics'),
+    ('CL1-102', 'English'),
+    ('CL1-138', 'English 1st language'),
+    ('CL1-114', 'French'),
+    ('CL1-154', 'FT/Assembly'),
+    ('CL1-116', 'Geography'),
+    ('CL1-119', 'History'),
+    ('CL1-103', 'Mathematics'),
+    ('CL1-122', 'Music'),
+    ('CL1-124', 'Physical Education'),
+    ('CL1-125', 'Physics'),
+    ('CL1-126', 'PSCHEE'),
+    ('CL1-128', 'Romanian'),
+    ('CL1-129', 'Science'),
+    ('CL1-155', 'Physical Education IG'),
+    ('CL1-107', 'Business'),
+    ('CL1-153', 'Combined Science'),
+    ('CL1-139', 'English 2nd language'),
+    ('CL1-113', 'IELTS'),
+    ('CL1-127', 'Psychology'),
+    ('CL1-132', 'Statistics'),
+    ('CL1-134', 'Travel & Tourism'),
+    ('CL1-115', 'Further Maths'),
+    ('CL1-158', 'Further Mechanics'),
+    ('CL1-159', 'Mechanics'),
+    ('CL1-121', 'Literature'),
+    ('CL1-117', 'Global Perspectives')
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS teacher_aliases;
+DROP TABLE IF EXISTS teachers;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS teachers(
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS teacher_aliases(
+    teacher_id INTEGER NOT NULL,
+    alias TEXT NOT NULL,
+
+    FOREIGN KEY (teacher_id)
+        REFERENCES teachers (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+-- a spelling belongs to one teacher only, regardless of case.
+CREATE UNIQUE INDEX IF NOT EXISTS teacher_aliases_alias ON teacher_aliases (LOWER(alias));
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS marks;
\ No newline at end of file
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS marks(
+    id SERIAL PRIMARY KEY,
+    student_id INTEGER NOT NULL,
+    subject_id INTEGER NOT NULL,
+    teacher TEXT NOT NULL,
+    teacher_id INTEGER,
+    percentage INTEGER NOT NULL,
+    academic_year INTEGER NOT NULL,
+    term INTEGER NOT NULL,
+    importance TEXT NOT NULL,
+    -- marks which didnt come from engage link to the batch they were imported with.
+    import_batch_id INTEGER,
+    created_at TIMESTAMPTZ NOT NULL,
+
+    FOREIGN KEY (student_id)
+        REFERENCES students (pid)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION,
+    FOREIGN KEY (subject_id)
+        REFERENCES subjects (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION,
+
+    CHECK (percentage >= 0 AND percentage <= 100)
+);
+
+CREATE INDEX IF NOT EXISTS marks_student_id ON marks (student_id);
+CREATE INDEX IF NOT EXISTS marks_teacher_id ON marks (teacher_id);
+CREATE INDEX IF NOT EXISTS marks_import_batch_id ON marks (import_batch_id);
\ No newline at end of file
@@ -0,0 +1,20 @@
+-- transactions of the work queue, shared by every instance of the app.
+CREATE TABLE IF NOT EXISTS transactions(
+    id BIGSERIAL PRIMARY KEY,
+    state INTEGER NOT NULL,
+    -- the type of the data, the instances only claim the transactions whose type they decode.
+    type TEXT NOT NULL,
+    data JSONB NOT NULL,
+    error TEXT,
+    -- the trace id and the actor of the request which published the transaction, carried by
+    -- the context of the transactions claimed by other instances.
+    trace_id TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    -- the processing transactions are leased to the claiming instance, which renews the lease
+    -- while it processes them. transactions with expired leases are claimed again.
+    lease_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS transactions_state ON transactions (state, id);
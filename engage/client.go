@@ -5,15 +5,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/trace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// DefaultURL is the url of the engage instance of the Cambridge School of Bucharest.
+const DefaultURL = "https://cambridgeschoolportal.engagehosted.com"
+
 var (
-	baseURL               = "https://cambridgeschoolportal.engagehosted.com/Services/ReportCommentServices.asmx/"
+	servicesPath          = "/Services/ReportCommentServices.asmx/"
 	academicYearsURL      = "GetMarksheetAcademicYears"
 	reportingPeriodsURL   = "GetReportingPeriods"
 	reportingSubjectsURL  = "GetPupilMarksheetSubjects"
@@ -21,21 +29,37 @@ var (
 	marksheetRenderURL    = "RenderPupilMarksheet"
 )
 
+// metrics of the requests to engage by endpoint and outcome, the outcome is either ok or the
+// error code of the request.
+var (
+	requestCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "csb_engage_requests_total",
+		Help: "Total number of engage requests by endpoint and outcome.",
+	}, []string{"endpoint", "outcome"})
+
+	requestSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "csb_engage_request_seconds",
+		Help: "Engage request latency in seconds by endpoint and outcome.",
+	}, []string{"endpoint", "outcome"})
+)
+
 // Client is a client used to interface with the engage api.
 type Client struct {
 	cc *http.Client
+	// baseURL of the report comment services of the engage instance.
+	baseURL string
 }
 
 // GetAcademicYears gets all the possible academic years for a PID.
 func (c *Client) GetAcademicYears(ctx context.Context, pid int) ([]int, error) {
-	resURL := baseURL + academicYearsURL
+	resURL := c.baseURL + academicYearsURL
 
 	res, err := c.post(ctx, resURL, engageContext{PupilIDs: fmt.Sprint(pid)})
 	if err != nil {
 		return nil, err
 	}
 
-	out := make([]int, len(res.D))
+	out := make([]int, 0, len(res.D))
 	for _, data := range res.D {
 		v, err := strconv.Atoi(data.Value)
 		if err != nil {
@@ -50,7 +74,7 @@ func (c *Client) GetAcademicYears(ctx context.Context, pid int) ([]int, error) {
 
 // GetReportingPeriods gets the reporting periods for a PID in a specific range of academic years.
 func (c *Client) GetReportingPeriods(ctx context.Context, pid int, academicYears []int) ([]string, error) {
-	resURL := baseURL + reportingPeriodsURL
+	resURL := c.baseURL + reportingPeriodsURL
 
 	res, err := c.post(ctx, resURL, engageContext{
 		PupilIDs:      fmt.Sprint(pid),
@@ -60,7 +84,7 @@ func (c *Client) GetReportingPeriods(ctx context.Context, pid int, academicYears
 		return nil, err
 	}
 
-	out := make([]string, len(res.D))
+	out := make([]string, 0, len(res.D))
 	for _, data := range res.D {
 		out = append(out, data.Value)
 	}
@@ -69,8 +93,9 @@ func (c *Client) GetReportingPeriods(ctx context.Context, pid int, academicYears
 }
 
 // GetReportingSubjects gets the reporting subjects for a PID in a specific range of academic years and reporting periods (terms).
+// The subjects are populated with their engage code and display name.
 func (c *Client) GetReportingSubjects(ctx context.Context, pid int, academicYears []int, reportingTerms []string) ([]csb.Subject, error) {
-	resURL := baseURL + reportingSubjectsURL
+	resURL := c.baseURL + reportingSubjectsURL
 
 	res, err := c.post(ctx, resURL, engageContext{
 		PupilIDs:         fmt.Sprint(pid),
@@ -81,9 +106,9 @@ func (c *Client) GetReportingSubjects(ctx context.Context, pid int, academicYear
 		return nil, err
 	}
 
-	out := make([]csb.Subject, len(res.D))
+	out := make([]csb.Subject, 0, len(res.D))
 	for _, data := range res.D {
-		out = append(out, csb.Subject{EngageCode: data.Value})
+		out = append(out, csb.Subject{EngageCode: data.Value, Name: data.Text})
 	}
 
 	return out, nil
@@ -92,7 +117,7 @@ func (c *Client) GetReportingSubjects(ctx context.Context, pid int, academicYear
 // GetColumnsForSubjects gets the "columns" for a pid in the specified academic years and periods range (terms) for the specified subjects.
 // A column refers to the type of exam.
 func (c *Client) GetColumnsForSubjects(ctx context.Context, pid int, academicYears []int, reportingTerms []string, subjects []csb.Subject) ([]string, error) {
-	resURL := baseURL + columnsForSubjectsURL
+	resURL := c.baseURL + columnsForSubjectsURL
 
 	res, err := c.post(ctx, resURL, engageContext{
 		PupilIDs:         fmt.Sprint(pid),
@@ -104,7 +129,7 @@ func (c *Client) GetColumnsForSubjects(ctx context.Context, pid int, academicYea
 		return nil, err
 	}
 
-	out := make([]string, len(res.D))
+	out := make([]string, 0, len(res.D))
 	for _, data := range res.D {
 		out = append(out, data.Value)
 	}
@@ -112,8 +137,10 @@ func (c *Client) GetColumnsForSubjects(ctx context.Context, pid int, academicYea
 	return out, nil
 }
 
-func (c *Client) GetMarksheetRender(ctx context.Context, pid int, academicYears []int, reportingTerms, reportingColumns []string, reportingSubjects []csb.Subject) ([]byte, error) {
-	resURL := baseURL + marksheetRenderURL
+func (c *Client) GetMarksheetRender(ctx context.Context, pid int, academicYears []int, reportingTerms, reportingColumns []string, reportingSubjects []csb.Subject) (_ []byte, err error) {
+	resURL := c.baseURL + marksheetRenderURL
+	ctx, done := startRequest(ctx, marksheetRenderURL)
+	defer func() { done(err) }()
 
 	body, err := json.Marshal(renderMarksheetRequest{
 		PupilIDs:                      fmt.Sprint(pid),
@@ -158,15 +185,147 @@ func (c *Client) GetMarksheetRender(ctx context.Context, pid int, academicYears
 		return nil, csb.Errorf(csb.ENOTFOUND, "couldnt find pupil")
 	}
 
-	buf := make([]byte, resp.ContentLength)
-	_, err = resp.Body.Read(buf)
-	return buf, err
+	return io.ReadAll(resp.Body)
+}
+
+// FindStudent builds the student with pid = pid from engage, with the reporting subjects of
+// the student in each academic year.
+//
+// returns ENOTFOUND if the student isnt found.
+func (c *Client) FindStudent(ctx context.Context, pid int) (*csb.Student, error) {
+	stud := new(csb.Student)
+	stud.PID = pid
+
+	academicYears, err := c.GetAcademicYears(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, academicYear := range academicYears {
+		if academicYear == csb.CurrentAcademicYear {
+			stud.AttendsSchool = true
+			break
+		}
+	}
+
+	if err := c.attachStudentSubjects(ctx, stud, academicYears); err != nil {
+		return nil, err
+	}
+
+	buf, err := c.GetMarksheetRender(ctx, pid, academicYears[len(academicYears)-1:], nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var x int
+	stud.Name, x, err = NameFromRender(buf)
+	if err != nil {
+		return nil, err
+	}
+	// FASTPATH: doesent attend school -> no need to get current year.
+	if !stud.AttendsSchool {
+		return stud, nil
+	}
+
+	// reslice the buffer since we know that the current year wont be before the name so we
+	// dont need to search that area.
+	buf = buf[x:]
+	stud.CurrentYear, _, err = CurrentYearFromRender(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return stud, nil
+}
+
+// attachStudentSubjects gathers the reporting subjects of the student in each academic year.
+// Academic years without reporting periods are skipped.
+func (c *Client) attachStudentSubjects(ctx context.Context, student *csb.Student, academicYears []int) error {
+	student.Subjects = make([]csb.Subject, 0)
+	student.SubjectHistory = make([]csb.SubjectsTaken, 0, len(academicYears))
+
+	seen := make(map[string]struct{})
+	for _, academicYear := range academicYears {
+		periods, err := c.GetReportingPeriods(ctx, student.PID, []int{academicYear})
+		if csb.ErrorCode(err) == csb.ENOTFOUND || len(periods) == 0 {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		subjects, err := c.GetReportingSubjects(ctx, student.PID, []int{academicYear}, periods)
+		if err != nil {
+			return err
+		}
+
+		student.SubjectHistory = append(student.SubjectHistory, csb.SubjectsTaken{
+			AcademicYear: academicYear,
+			Subjects:     subjects,
+		})
+		for _, subject := range subjects {
+			if _, ok := seen[subject.EngageCode]; ok {
+				continue
+			}
+			seen[subject.EngageCode] = struct{}{}
+
+			student.Subjects = append(student.Subjects, subject)
+		}
+	}
+
+	return nil
+}
+
+// FindMarks reads the marks of the student with pid = pid in the full period from the
+// marksheet render. engageTerm is the engage reporting period of the period.
+//
+// The marks are populated with the student id, the period, the subject name, the teacher and
+// the percentage.
+func (c *Client) FindMarks(ctx context.Context, pid int, period csb.Period, engageTerm string) ([]*csb.Mark, error) {
+	bufResp, err := c.GetMarksheetRender(
+		ctx,
+		pid,
+		[]int{period.AcademicYear},
+		[]string{engageTerm},
+		[]string{*period.Importance},
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*csb.Mark, 0)
+
+	// first itteration, do not accept any error.
+	mark, n, err := GetMarkFromRender(bufResp)
+	if err != nil {
+		return nil, err
+	}
+	mark.StudentID, mark.Period = pid, period
+	out = append(out, mark)
+	bufResp = bufResp[n:]
+
+	for {
+		mark, n, err := GetMarkFromRender(bufResp)
+		switch csb.ErrorCode(err) {
+		case "":
+			mark.StudentID, mark.Period = pid, period
+			out = append(out, mark)
+			bufResp = bufResp[n:]
+		case csb.ENOTFOUND: // sentinel error, treated as EOF.
+			return out, nil
+		default: // non EOF error not accepted.
+			return nil, err
+		}
+	}
 }
 
 // post sends a post request to url with the specified engage context. It checks for any errors during
 // the exchange process with engage. It returns an engage response which has
 // at least one piece of data inside.
 func (c *Client) post(ctx context.Context, url string, engCtx engageContext) (res *engageResponse, err error) {
+	ctx, done := startRequest(ctx, strings.TrimPrefix(url, c.baseURL))
+	defer func() { done(err) }()
+
 	body, err := json.Marshal(engCtx)
 	if err != nil {
 		return nil, err
@@ -201,16 +360,45 @@ func (c *Client) post(ctx context.Context, url string, engCtx engageContext) (re
 	return res, nil
 }
 
-// NewClient creates a new engage client with the provided token used for
-// authentification.
+// NewClient creates a new engage client of the default engage instance with the provided
+// token used for authentification.
 func NewClient(c *http.Client, token string) *Client {
+	return NewClientWithURL(c, DefaultURL, token)
+}
+
+// NewClientWithURL creates a new engage client of the engage instance at the provided url
+// with the provided token used for authentification.
+//
+// the transport of the http client is wrapped to authentificate the requests, so http
+// clients shouldnt be shared between engage clients.
+func NewClientWithURL(c *http.Client, url, token string) *Client {
 	c.Transport = &cookieHeaderTransport{
 		cookie: token,
 		d:      c.Transport,
 	}
 
 	return &Client{
-		cc: c,
+		cc:      c,
+		baseURL: strings.TrimSuffix(url, "/") + servicesPath,
+	}
+}
+
+// startRequest starts the span of the request to the endpoint, the returned func records the
+// request once it ended with err.
+func startRequest(ctx context.Context, endpoint string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := trace.Start(ctx, "engage."+endpoint)
+
+	return ctx, func(err error) {
+		outcome := "ok"
+		if err != nil {
+			outcome = csb.ErrorCode(err)
+		}
+
+		requestCount.WithLabelValues(endpoint, outcome).Inc()
+		requestSeconds.WithLabelValues(endpoint, outcome).Observe(time.Since(start).Seconds())
+		span.SetAttribute("outcome", outcome)
+		span.Finish(err)
 	}
 }
 
@@ -0,0 +1,33 @@
+package engage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetMarksheetRender(t *testing.T) {
+	t.Run("Chunked", func(t *testing.T) {
+		// the render is sent in chunks so the content length of the response is unknown.
+		chunks := []string{`{"d":"<td><a>Ana Popescu</a></td>`, `<td>\t85, Mathematics, Mr Smith<br></td>"}`}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, chunk := range chunks {
+				io.WriteString(w, chunk)
+				w.(http.Flusher).Flush()
+			}
+		}))
+		defer srv.Close()
+
+		c := NewClientWithURL(srv.Client(), srv.URL, "token")
+		render, err := c.GetMarksheetRender(context.Background(), 1, []int{2022}, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := chunks[0] + chunks[1]; string(render) != expected {
+			t.Fatalf("expected %q, got: %q", expected, render)
+		}
+	})
+}
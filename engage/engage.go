@@ -116,8 +116,8 @@ func NameFromRender(renderBuf []byte) (string, int, error) {
 	}
 
 	out := string(renderBuf[res[0]:res[1]])
-	out = strings.TrimLeft(out, "<a>")
-	out = strings.TrimRight(out, "</a>")
+	out = strings.TrimPrefix(out, "<a>")
+	out = strings.TrimSuffix(out, "</a>")
 
 	return out, res[1], nil
 }
@@ -133,7 +133,7 @@ func CurrentYearFromRender(renderBuf []byte) (int, int, error) {
 
 	lastX := len(res) - 1
 	out := string(renderBuf[res[lastX][0]:res[lastX][1]])
-	out = strings.TrimLeft(out, "Year ")
+	out = strings.TrimPrefix(out, "Year ")
 
 	year, err := strconv.Atoi(out)
 	if err != nil {
@@ -148,14 +148,14 @@ func CurrentYearFromRender(renderBuf []byte) (int, int, error) {
 func GetMarkFromRender(renderBuf []byte) (_ *csb.Mark, n int, _ error) {
 	mark := new(csb.Mark)
 
-	// get the percentage first.
+	// get the percentage first, the tab before the percentage is escaped in the render.
 	res := percentageRegexp.FindIndex(renderBuf)
 	if res == nil {
 		return nil, -1, csb.Errorf(csb.ENOTFOUND, "couldnt match any percentage from current render")
 	}
 
 	raw := string(renderBuf[res[0]:res[1]])
-	percentage, err := strconv.Atoi(strings.TrimPrefix(raw, "\t"))
+	percentage, err := strconv.Atoi(strings.TrimPrefix(raw, `\t`))
 	if err != nil {
 		return nil, -1, err
 	}
@@ -174,7 +174,7 @@ func GetMarkFromRender(renderBuf []byte) (_ *csb.Mark, n int, _ error) {
 
 	// get teacher name.
 	teacher, remX := getTeacherName(renderBuf[n:])
-	if remX == 1 {
+	if remX == -1 {
 		return nil, -1, errors.New("unexpected buffer index")
 	}
 	mark.Teacher = teacher
@@ -183,6 +183,8 @@ func GetMarkFromRender(renderBuf []byte) (_ *csb.Mark, n int, _ error) {
 	return mark, n, nil
 }
 
+// getSubjectName returns the subject name following the percentage: ", Subject, " and the
+// index of the comma after the name.
 func getSubjectName(buf []byte) (string, int) {
 	x := bytes.IndexByte(buf, ',')
 	if x == -1 {
@@ -194,19 +196,21 @@ func getSubjectName(buf []byte) (string, int) {
 		return "", -1
 	}
 
-	return string(buf[x+2 : y]), y
+	return string(buf[x+2 : x+2+y]), x + 2 + y
 }
 
+// getTeacherName returns the teacher name following the subject name: ", Teacher<" and the
+// index of the tag after the name.
 func getTeacherName(buf []byte) (string, int) {
 	x := bytes.IndexByte(buf, '<')
 	if x == -1 {
 		return "", -1
 	}
 
-	y := bytes.LastIndexByte(buf[:x+1], ',')
-	if y == -1 {
+	// the name can hold commas, everything after the comma of the subject is the name.
+	if !bytes.HasPrefix(buf[:x], []byte(", ")) {
 		return "", -1
 	}
 
-	return string(buf[y+2 : y]), y
+	return string(buf[2:x]), x
 }
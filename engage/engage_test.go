@@ -0,0 +1,62 @@
+package engage
+
+import (
+	"testing"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+// render is a marksheet render as engage sends it, with the tabs escaped.
+const render = `<td><a>Ana Popescu</a></td><td>Year 9</td><td>Year 10</td>` +
+	`<td>\t85, Mathematics, Mr Smith<br>\t7, English Language, Ms Al-Amin, Jr<br></td>`
+
+func TestNameFromRender(t *testing.T) {
+	name, _, err := NameFromRender([]byte(render))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the letters of the tags arent trimmed from the name.
+	if name != "Ana Popescu" {
+		t.Fatalf("expected Ana Popescu, got: %q", name)
+	}
+}
+
+func TestCurrentYearFromRender(t *testing.T) {
+	year, _, err := CurrentYearFromRender([]byte(render))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if year != 10 {
+		t.Fatalf("expected year 10, got: %v", year)
+	}
+}
+
+func TestGetMarkFromRender(t *testing.T) {
+	buf := []byte(render)
+
+	var marks []*csb.Mark
+	for {
+		mark, n, err := GetMarkFromRender(buf)
+		if csb.ErrorCode(err) == csb.ENOTFOUND {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		marks = append(marks, mark)
+		buf = buf[n:]
+	}
+
+	expected := []csb.Mark{
+		{Percentage: 85, Subject: csb.Subject{Name: "Mathematics"}, Teacher: "Mr Smith"},
+		{Percentage: 7, Subject: csb.Subject{Name: "English Language"}, Teacher: "Ms Al-Amin, Jr"},
+	}
+	if len(marks) != len(expected) {
+		t.Fatalf("expected %v marks, got: %v", len(expected), len(marks))
+	}
+	for i, mark := range marks {
+		if mark.Percentage != expected[i].Percentage || mark.Subject.Name != expected[i].Subject.Name || mark.Teacher != expected[i].Teacher {
+			t.Fatalf("mark %v: expected %+v, got: %+v", i, expected[i], *mark)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package csb
+
+import "context"
+
+const (
+	// ExportCSV exports records as comma separated values with a header row.
+	ExportCSV = "csv"
+	// ExportNDJSON exports records as newline delimited json objects.
+	ExportNDJSON = "ndjson"
+)
+
+// ExportService represents a service used to stream large sets of records.
+//
+// Unlike the find methods of the other services, the records are handed to fn one by one
+// as they are read from storage instead of being loaded in memory first.
+type ExportService interface {
+	// ExportStudents calls fn for each student matching the filter, the subjects of each
+	// student are populated.
+	//
+	// The export stops at the first error returned by fn.
+	ExportStudents(ctx context.Context, filter StudentFilter, fn func(*Student) error) error
+
+	// ExportMarks calls fn for each mark matching the filter, the subject of each mark is
+	// populated.
+	//
+	// The export stops at the first error returned by fn.
+	ExportMarks(ctx context.Context, filter MarksFilter, fn func(*Mark) error) error
+
+	// ExportStudentYears returns the current year group of the students attending the school
+	// by pid, without reading the rest of the students.
+	ExportStudentYears(ctx context.Context) (map[int]int, error)
+}
@@ -0,0 +1,65 @@
+package csb
+
+import "context"
+
+// Trends of a forecast.
+const (
+	// TrendAccelerating flags percentages rising faster than chance would explain.
+	TrendAccelerating = "accelerating"
+	// TrendDeclining flags percentages falling faster than chance would explain.
+	TrendDeclining = "declining"
+	// TrendStable flags percentages without a significant slope.
+	TrendStable = "stable"
+)
+
+// ForecastFilter represents a request to the ForecastStudent service.
+type ForecastFilter struct {
+	// PID of the forecasted student.
+	PID int `json:"pid"`
+	// Subjects scopes the forecasted subjects, all the subjects of the student if empty.
+	Subjects []Subject `json:"subjects"`
+	// From scopes the history the trends are fitted on, the whole history if nil.
+	From *Period `json:"from"`
+}
+
+// Forecast represents the projection of the marks of a student for the term after their
+// latest marks.
+type Forecast struct {
+	StudentID int `json:"pid"`
+	// Next is the projected period, only the academic year and term are populated.
+	Next Period `json:"next"`
+
+	// Overall is the trend of the average of each term.
+	Overall *Trend `json:"overall"`
+	// Subjects are the trends of each subject, ordered by subject name. Subjects with less
+	// than 3 terms of history have no trend.
+	Subjects []*Trend `json:"subjects"`
+}
+
+// Trend represents a linear trend fitted on the percentages of a student over the terms.
+type Trend struct {
+	// Subject of the trend, nil for the overall trend.
+	Subject *Subject `json:"subject,omitempty"`
+
+	// Points is the amount of terms the trend was fitted on.
+	Points int `json:"points"`
+	// Slope is the change in percentage points per term.
+	Slope float64 `json:"slope"`
+	// Projected is the percentage projected for the next term.
+	Projected float64 `json:"projected"`
+	// Lower and Upper bound the 95% prediction interval of the projection.
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	// Trend flags the direction of the trend: accelerating, declining or stable.
+	Trend string `json:"trend"`
+}
+
+// ForecastService represents a forecasting service.
+type ForecastService interface {
+	// ForecastStudent fits a trend per subject and overall on the marks of the student and
+	// projects them on the next term.
+	//
+	// returns ENOTFOUND if the student doesnt exist and EINVALID if the student has less
+	// than 3 terms of marks.
+	ForecastStudent(ctx context.Context, filter ForecastFilter) (*Forecast, error)
+}
@@ -0,0 +1,122 @@
+package csb
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// GradeScale maps percentages to grades such as IGCSE 9-1, A*-G or IB 1-7.
+//
+// Scales with the same name form a family, each scale of a family can be scoped to a subject,
+// year group and academic year. A mark is graded with the most specific scale of the family
+// in scope of the mark: a subject scope outweighs a year group scope which outweighs an
+// academic year scope.
+type GradeScale struct {
+	// ID of the scale.
+	ID int `json:"id"`
+	// Name of the scale family: "IGCSE 9-1".
+	Name string `json:"name"`
+
+	// SubjectID scopes the scale to a subject.
+	SubjectID *int `json:"subject_id"`
+	// Year scopes the scale to a year group, matched against the year group the student was in
+	// during the academic year of the mark.
+	Year *int `json:"year"`
+	// AcademicYear scopes the scale to the marks recieved in an academic year.
+	AcademicYear *int `json:"academic_year"`
+
+	// Boundaries of the scale, ordered from the highest grade to the lowest.
+	Boundaries []GradeBoundary `json:"boundaries"`
+
+	// Timestamp.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GradeBoundary represents the lowest percentage which gets a grade.
+type GradeBoundary struct {
+	Grade         string `json:"grade"`
+	MinPercentage int    `json:"min_percentage"`
+}
+
+func (s *GradeScale) Validate() error {
+	if s.Name == "" {
+		return Errorf(EINVALID, "validate: grade scale missing name field")
+	}
+	if len(s.Boundaries) == 0 {
+		return Errorf(EINVALID, "validate: grade scale has no boundaries")
+	}
+
+	grades := make(map[string]struct{}, len(s.Boundaries))
+	mins := make(map[int]struct{}, len(s.Boundaries))
+	for _, b := range s.Boundaries {
+		if b.Grade == "" {
+			return Errorf(EINVALID, "validate: grade boundary missing grade field")
+		}
+		if b.MinPercentage < 0 || b.MinPercentage > 100 {
+			return Errorf(EINVALID, "validate: grade boundary percentage must be between 0 and 100 inclusive, but got: %v", b.MinPercentage)
+		}
+		if _, ok := grades[b.Grade]; ok {
+			return Errorf(EINVALID, "validate: duplicate grade: %v", b.Grade)
+		}
+		if _, ok := mins[b.MinPercentage]; ok {
+			return Errorf(EINVALID, "validate: duplicate grade boundary: %v", b.MinPercentage)
+		}
+		grades[b.Grade], mins[b.MinPercentage] = struct{}{}, struct{}{}
+	}
+
+	return nil
+}
+
+// Sort orders the boundaries from the highest grade to the lowest.
+func (s *GradeScale) Sort() {
+	sort.Slice(s.Boundaries, func(i, j int) bool {
+		return s.Boundaries[i].MinPercentage > s.Boundaries[j].MinPercentage
+	})
+}
+
+// Grade returns the grade of the percentage on the scale, the boundaries must be sorted.
+//
+// If the percentage is under the lowest boundary an empty grade is returned.
+func (s *GradeScale) Grade(percentage int) string {
+	for _, b := range s.Boundaries {
+		if percentage >= b.MinPercentage {
+			return b.Grade
+		}
+	}
+	return ""
+}
+
+// GradeScaleFilter represents a filter to bulk get grade scales.
+type GradeScaleFilter struct {
+	// Name filters on the scale family.
+	Name *string `json:"name"`
+	// SubjectID filters on the scale subject scope.
+	SubjectID *int `json:"subject_id"`
+	// Year filters on the scale year group scope.
+	Year *int `json:"year"`
+	// AcademicYear filters on the scale academic year scope.
+	AcademicYear *int `json:"academic_year"`
+}
+
+// GradeService represents a grade scale service.
+type GradeService interface {
+	// FindGradeScaleByID returns the grade scale with id = id.
+	//
+	// returns ENOTFOUND if the scale doesnt exist.
+	FindGradeScaleByID(ctx context.Context, id int) (*GradeScale, error)
+
+	// FindGradeScales finds the grade scales with the appropiate filter.
+	FindGradeScales(ctx context.Context, filter GradeScaleFilter) ([]*GradeScale, error)
+
+	// CreateGradeScale creates a new grade scale.
+	//
+	// returns EINVALID if the scale isnt valid and ECONFLICT if the family already has a
+	// scale with the same scope.
+	CreateGradeScale(ctx context.Context, scale *GradeScale) error
+
+	// DeleteGradeScale permanently deletes the grade scale with id = id.
+	//
+	// returns ENOTFOUND if the scale doesnt exist.
+	DeleteGradeScale(ctx context.Context, id int) error
+}
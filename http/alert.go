@@ -0,0 +1,139 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerAlertRoutes registers all the routes of the alert service.
+func (s *Server) registerAlertRoutes(r chi.Router) {
+	r.Post("/", s.handleGetAlerts)
+
+	// alert rules CRUD methods.
+	r.Get("/rules", s.handleGetAlertRules)
+	r.Post("/rules", s.handleCreateAlertRule)
+	r.Get("/rules/{id}", s.handleGetAlertRule)
+	r.Delete("/rules/{id}", s.handleDeleteAlertRule)
+}
+
+// POST "/alerts"
+//
+// handleGetAlerts parses an alert filter from the request body and finds all the raised
+// alerts with the provided filter.
+func (s *Server) handleGetAlerts(w http.ResponseWriter, r *http.Request) {
+	var filter csb.AlertFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	alerts, err := s.AlertService.FindAlerts(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, alerts); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/alerts/rules"
+//
+// handleGetAlertRules finds all the alert rules, the rules can be filtered with the optional
+// kind, student_id, year and subject_id query parameters.
+func (s *Server) handleGetAlertRules(w http.ResponseWriter, r *http.Request) {
+	var filter csb.AlertRuleFilter
+	var err error
+	if v := r.URL.Query().Get("kind"); v != "" {
+		filter.Kind = &v
+	}
+	if filter.StudentID, err = queryInt(r, "student_id"); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	if filter.Year, err = queryInt(r, "year"); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	if filter.SubjectID, err = queryInt(r, "subject_id"); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	rules, err := s.AlertService.FindAlertRules(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, rules); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/alerts/rules"
+//
+// handleCreateAlertRule parses an alert rule from the request body and creates it. returns
+// the created rule with status 201.
+func (s *Server) handleCreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	var rule csb.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	if err := s.AlertService.CreateAlertRule(r.Context(), &rule); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := WriteJSON(w, rule); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/alerts/rules/{id}"
+//
+// handleGetAlertRule gets the alert rule with the provided id. returns 404 if the rule
+// isnt found.
+func (s *Server) handleGetAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	rule, err := s.AlertService.FindAlertRuleByID(r.Context(), id)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, rule); err != nil {
+		LogError(r, err)
+	}
+}
+
+// DELETE "/alerts/rules/{id}"
+//
+// handleDeleteAlertRule permanently deletes the alert rule with the provided id along side
+// its alerts. returns 404 if the rule isnt found and 204 if the delete is sucessful.
+func (s *Server) handleDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	if err := s.AlertService.DeleteAlertRule(r.Context(), id); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
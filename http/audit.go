@@ -0,0 +1,70 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerAuditRoutes registers all the routes of the audit service, only admins can reach
+// them.
+func (s *Server) registerAuditRoutes(r chi.Router) {
+	r.Use(s.requireAdminMiddleware)
+
+	r.Post("/", s.handleGetAuditEntries)
+}
+
+// actorMiddleware carries the actor of the request in its context so that the changes made
+// by the request are recorded against it. The admin token maps to the "admin" actor, other
+// bearer tokens to a "key:" actor named after a prefix of their hash so the tokens
+// arent stored in the audit log.
+func (s *Server) actorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var actor string
+		auth := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		switch {
+		case token != auth && matchToken(token, s.AdminToken):
+			actor = "admin"
+		case token != auth && token != "":
+			sum := sha256.Sum256([]byte(token))
+			actor = "key:" + hex.EncodeToString(sum[:6])
+		default:
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			actor = "anonymous@" + host
+		}
+
+		next.ServeHTTP(w, r.WithContext(csb.NewContextWithActor(r.Context(), actor)))
+	})
+}
+
+// POST "/audit"
+//
+// handleGetAuditEntries parses an audit filter from the request body and gets the matching
+// audit entries, newest first.
+func (s *Server) handleGetAuditEntries(w http.ResponseWriter, r *http.Request) {
+	var filter csb.AuditFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	entries, err := s.AuditService.FindAuditEntries(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, entries); err != nil {
+		LogError(r, err)
+	}
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerBackupRoutes registers all the routes of the backup service, only admins can reach
+// them.
+func (s *Server) registerBackupRoutes(r chi.Router) {
+	r.Use(s.requireAdminMiddleware)
+
+	r.Get("/", s.handleGetBackups)
+	r.Post("/", s.handleCreateBackup)
+	r.Get("/{name}", s.handleDownloadBackup)
+}
+
+// requireAdminMiddleware only lets through the requests with the admin token as their bearer
+// token. All the requests are rejected if the server has no admin token.
+func (s *Server) requireAdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if token == auth || !matchToken(token, s.AdminToken) {
+			SendErr(w, r, csb.Errorf(csb.EUNAUTHORIZED, "invalid admin token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GET "/backups"
+//
+// handleGetBackups gets the kept backups, newest first.
+func (s *Server) handleGetBackups(w http.ResponseWriter, r *http.Request) {
+	backups, err := s.BackupService.FindBackups(r.Context())
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, backups); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/backups"
+//
+// handleCreateBackup takes a backup of the database while it keeps serving requests.
+func (s *Server) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
+	backup, err := s.BackupService.CreateBackup(r.Context())
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := WriteJSON(w, backup); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/backups/{name}"
+//
+// handleDownloadBackup streams the backup with the provided name as an attachment. returns
+// 404 if the backup isnt found.
+func (s *Server) handleDownloadBackup(w http.ResponseWriter, r *http.Request) {
+	backup, rc, err := s.BackupService.OpenBackup(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", backup.Name))
+	w.Header().Set("Content-Length", strconv.FormatInt(backup.Size, 10))
+	if _, err := io.Copy(w, rc); err != nil {
+		LogError(r, err)
+	}
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerComparisonRoutes registers all the routes of the comparison service.
+func (s *Server) registerComparisonRoutes(r chi.Router) {
+	r.Post("/students", s.handleCompareStudents)
+	r.Post("/years", s.handleCompareYears)
+}
+
+// POST "/comparisons/students"
+//
+// handleCompareStudents parses a head to head filter from the request body and compares the
+// marks of the students, aligned per subject and per period.
+func (s *Server) handleCompareStudents(w http.ResponseWriter, r *http.Request) {
+	var filter csb.HeadToHeadFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	comparison, err := s.ComparisonService.CompareStudents(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, comparison); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/comparisons/years"
+//
+// handleCompareYears parses a year over year filter from the request body and compares the
+// marks of the student in the academic year with their marks in the previous academic year.
+func (s *Server) handleCompareYears(w http.ResponseWriter, r *http.Request) {
+	var filter csb.YearOverYearFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	comparison, err := s.ComparisonService.CompareYears(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, comparison); err != nil {
+		LogError(r, err)
+	}
+}
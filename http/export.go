@@ -0,0 +1,460 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// exportFlushRows is the amount of rows written between two flushes of the response.
+const exportFlushRows = 100
+
+// column flattens one field of a record of type T to a single value.
+type column[T any] func(T) any
+
+var (
+	studentColumns = map[string]column[*csb.Student]{
+		"pid":            func(s *csb.Student) any { return s.PID },
+		"name":           func(s *csb.Student) any { return s.Name },
+		"current_year":   func(s *csb.Student) any { return s.CurrentYear },
+		"attends_school": func(s *csb.Student) any { return s.AttendsSchool },
+		"subjects":       func(s *csb.Student) any { return joinSubjectNames(s.Subjects) },
+		"subject_codes":  func(s *csb.Student) any { return joinSubjectCodes(s.Subjects) },
+		"created_at":     func(s *csb.Student) any { return s.CreatedAt },
+		"updated_at":     func(s *csb.Student) any { return s.UpdatedAt },
+	}
+	defaultStudentColumns = []string{"pid", "name", "current_year", "attends_school", "subjects"}
+
+	markColumns = map[string]column[*csb.Mark]{
+		"id":            func(m *csb.Mark) any { return m.ID },
+		"pid":           func(m *csb.Mark) any { return m.StudentID },
+		"subject":       func(m *csb.Mark) any { return m.Subject.Name },
+		"subject_code":  func(m *csb.Mark) any { return m.Subject.EngageCode },
+		"teacher":       func(m *csb.Mark) any { return m.Teacher },
+		"teacher_id":    func(m *csb.Mark) any { return m.TeacherID },
+		"percentage":    func(m *csb.Mark) any { return m.Percentage },
+		"grade":         func(m *csb.Mark) any { return m.Grade },
+		"academic_year": func(m *csb.Mark) any { return m.Period.AcademicYear },
+		"term":          func(m *csb.Mark) any { return m.Period.Term },
+		"importance":    func(m *csb.Mark) any { return m.Period.Importance },
+		"created_at":    func(m *csb.Mark) any { return m.CreatedAt },
+	}
+	defaultMarkColumns = []string{"pid", "subject", "teacher", "percentage", "academic_year", "term", "importance"}
+
+	rankColumns = map[string]column[*csb.Rank]{
+		"id":                     func(r *csb.Rank) any { return r.ID },
+		"pid":                    func(r *csb.Rank) any { return r.StudentID },
+		"score":                  func(r *csb.Rank) any { return r.Score },
+		"weight_profile":         func(r *csb.Rank) any { return r.WeightProfile },
+		"weight_profile_version": func(r *csb.Rank) any { return r.WeightProfileVersion },
+		"grade":                  func(r *csb.Rank) any { return r.Grade },
+		"position":               func(r *csb.Rank) any { return r.Postion },
+		"subjects":               func(r *csb.Rank) any { return joinSubjectNames(r.Subjects) },
+		"subject_codes":          func(r *csb.Rank) any { return joinSubjectCodes(r.Subjects) },
+		"academic_year":          func(r *csb.Rank) any { return r.Period.AcademicYear },
+		"term":                   func(r *csb.Rank) any { return r.Period.Term },
+		"importance":             func(r *csb.Rank) any { return r.Period.Importance },
+		"generated_at":           func(r *csb.Rank) any { return r.GeneratedAt },
+	}
+	defaultRankColumns = []string{"position", "pid", "score", "subjects", "academic_year", "term", "importance"}
+
+	researchColumns = map[string]column[*csb.ResearchMark]{
+		"student":       func(m *csb.ResearchMark) any { return m.Student },
+		"year":          func(m *csb.ResearchMark) any { return m.Year },
+		"subject":       func(m *csb.ResearchMark) any { return m.Subject.Name },
+		"subject_code":  func(m *csb.ResearchMark) any { return m.Subject.EngageCode },
+		"teacher":       func(m *csb.ResearchMark) any { return m.Teacher },
+		"percentage":    func(m *csb.ResearchMark) any { return m.Percentage },
+		"academic_year": func(m *csb.ResearchMark) any { return m.Period.AcademicYear },
+		"term":          func(m *csb.ResearchMark) any { return m.Period.Term },
+		"importance":    func(m *csb.ResearchMark) any { return m.Period.Importance },
+	}
+	defaultResearchColumns = []string{"student", "year", "subject", "teacher", "percentage", "academic_year", "term", "importance"}
+)
+
+// registerExportRoutes registers all the export routes.
+//
+// Every export route takes the filter of the exported records in the request body and
+// the optional format (csv or ndjson, defaults to csv) and columns (comma separated)
+// query parameters.
+func (s *Server) registerExportRoutes(r chi.Router) {
+	r.Post("/students", s.handleExportStudents)
+	r.Post("/marks", s.handleExportMarks)
+	r.Post("/rankings", s.handleExportRankings)
+	r.Post("/research", s.handleExportResearch)
+}
+
+// POST "/exports/students"
+//
+// handleExportStudents parses a student filter from the request body and streams all the
+// students with the provided filter.
+func (s *Server) handleExportStudents(w http.ResponseWriter, r *http.Request) {
+	var filter csb.StudentFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	exp, err := newExporter(w, r, studentColumns, defaultStudentColumns)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	exp.Close(s.ExportService.ExportStudents(r.Context(), filter, exp.Write))
+}
+
+// POST "/exports/marks"
+//
+// handleExportMarks parses a marks filter from the request body and streams all the marks
+// with the provided filter.
+func (s *Server) handleExportMarks(w http.ResponseWriter, r *http.Request) {
+	var filter csb.MarksFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	exp, err := newExporter(w, r, markColumns, defaultMarkColumns)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	exp.Close(s.ExportService.ExportMarks(r.Context(), filter, exp.Write))
+}
+
+// POST "/exports/rankings"
+//
+// handleExportRankings parses a ranking filter from the request body, generates the
+// rankings report and streams it.
+//
+// Unlike the other exports the report isnt read from a cursor, the positions depend on the
+// scores of every student so the whole report is generated and held in memory before the
+// first rank is written.
+func (s *Server) handleExportRankings(w http.ResponseWriter, r *http.Request) {
+	var filter csb.RankingFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	exp, err := newExporter(w, r, rankColumns, defaultRankColumns)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	ranks, err := s.RankingService.GenerateRankingsReport(r.Context(), filter)
+	for i := 0; err == nil && i < len(ranks); i++ {
+		err = exp.Write(&ranks[i])
+	}
+	exp.Close(err)
+}
+
+// POST "/exports/research"
+//
+// handleExportResearch parses a research export from the request body and streams the marks
+// matching its filter without names, the students and teachers are replaced by pseudonyms
+// keyed with the pseudonym key of the server. returns 501 if the server has no pseudonym key.
+//
+// The marks are read twice if the small groups are coarsened, once to count the groups and
+// once to stream them.
+func (s *Server) handleExportResearch(w http.ResponseWriter, r *http.Request) {
+	if s.PseudonymKey == "" {
+		SendErr(w, r, csb.Errorf(csb.ENOTIMPLEMENTED, "research exports need a pseudonym key"))
+		return
+	}
+
+	var export csb.ResearchExport
+	if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+	if err := export.Validate(); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	exp, err := newExporter(w, r, researchColumns, defaultResearchColumns)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	years, err := s.ExportService.ExportStudentYears(r.Context())
+	dataset := csb.NewResearchDataset(export, csb.NewPseudonymizer(s.PseudonymKey), years)
+	if err == nil && dataset.Coarsens() {
+		err = s.ExportService.ExportMarks(r.Context(), export.Filter, dataset.Count)
+	}
+	if err == nil {
+		err = s.ExportService.ExportMarks(r.Context(), export.Filter, func(mark *csb.Mark) error {
+			if m := dataset.Convert(mark); m != nil {
+				return exp.Write(m)
+			}
+			return nil
+		})
+	}
+	exp.Close(err)
+}
+
+// exporter writes the selected columns of records of type T to the response as they come.
+//
+// The response headers are only written with the first row, so errors hit before any row
+// was written can still be sent as normal json errors.
+type exporter[T any] struct {
+	w http.ResponseWriter
+	r *http.Request
+
+	format  string
+	names   []string
+	columns []column[T]
+
+	rw      rowWriter
+	written int
+}
+
+// newExporter creates a new exporter from the format and columns query parameters.
+//
+// returns EINVALID if the format or any of the columns is unknown.
+func newExporter[T any](w http.ResponseWriter, r *http.Request, all map[string]column[T], defaults []string) (*exporter[T], error) {
+	e := &exporter[T]{
+		w:      w,
+		r:      r,
+		format: r.URL.Query().Get("format"),
+		names:  defaults,
+	}
+
+	switch e.format {
+	case "":
+		e.format = csb.ExportCSV
+	case csb.ExportCSV, csb.ExportNDJSON:
+	default:
+		return nil, csb.Errorf(csb.EINVALID, "unknown export format: %v", e.format)
+	}
+
+	if v := r.URL.Query().Get("columns"); v != "" {
+		e.names = strings.Split(v, ",")
+	}
+	for _, name := range e.names {
+		col, ok := all[name]
+		if !ok {
+			return nil, csb.Errorf(csb.EINVALID, "unknown export column: %v", name)
+		}
+		e.columns = append(e.columns, col)
+	}
+
+	return e, nil
+}
+
+// Write flattens v and writes it as a row.
+func (e *exporter[T]) Write(v T) error {
+	if e.rw == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	values := make([]any, len(e.columns))
+	for i, col := range e.columns {
+		values[i] = col(v)
+	}
+	if err := e.rw.WriteRow(values); err != nil {
+		return err
+	}
+
+	e.written++
+	if e.written%exportFlushRows == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+// Close ends the export with the error which stopped it.
+//
+// If no rows were written yet the error is sent back, else the error is only logged since
+// the status code is already on the wire.
+func (e *exporter[T]) Close(err error) {
+	if err != nil {
+		if e.rw == nil {
+			SendErr(e.w, e.r, err)
+			return
+		}
+
+		LogError(e.r, err)
+		return
+	}
+
+	// write the headers even if the export is empty.
+	if e.rw == nil {
+		if err := e.start(); err != nil {
+			LogError(e.r, err)
+			return
+		}
+	}
+
+	if err := e.flush(); err != nil {
+		LogError(e.r, err)
+	}
+}
+
+func (e *exporter[T]) start() error {
+	switch e.format {
+	case csb.ExportCSV:
+		e.w.Header().Set("Content-Type", "text/csv")
+		e.rw = &csvRowWriter{w: csv.NewWriter(e.w)}
+	case csb.ExportNDJSON:
+		e.w.Header().Set("Content-Type", "application/x-ndjson")
+		e.rw = &ndjsonRowWriter{w: e.w, names: e.names}
+	}
+
+	return e.rw.WriteHeader(e.names)
+}
+
+func (e *exporter[T]) flush() error {
+	if err := e.rw.Flush(); err != nil {
+		return err
+	}
+
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// rowWriter writes flattened rows in a specific format.
+type rowWriter interface {
+	WriteHeader(names []string) error
+	WriteRow(values []any) error
+	Flush() error
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvRowWriter) WriteHeader(names []string) error {
+	return c.w.Write(names)
+}
+
+func (c *csvRowWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatCell(v)
+	}
+
+	return c.w.Write(record)
+}
+
+func (c *csvRowWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonRowWriter struct {
+	w     io.Writer
+	names []string
+	buf   bytes.Buffer
+}
+
+// WriteHeader is a no-op, every ndjson object carries its keys.
+func (n *ndjsonRowWriter) WriteHeader(names []string) error {
+	return nil
+}
+
+// WriteRow writes the values as a json object keeping the order of the columns.
+func (n *ndjsonRowWriter) WriteRow(values []any) error {
+	n.buf.Reset()
+	n.buf.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			n.buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(n.names[i])
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		n.buf.Write(key)
+		n.buf.WriteByte(':')
+		n.buf.Write(val)
+	}
+	n.buf.WriteString("}\n")
+
+	_, err := n.w.Write(n.buf.Bytes())
+	return err
+}
+
+func (n *ndjsonRowWriter) Flush() error {
+	return nil
+}
+
+// formatCell formats a flattened value as a csv cell, nil values are left empty.
+func formatCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return escapeFormula(v)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case *int:
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return escapeFormula(*v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeFormula prefixes text starting like a formula with a quote so spreadsheets open the cell
+// as text, the names and subjects come from engage and cant be trusted. Leading tabs and
+// carriage returns are escaped too since spreadsheets skip them before reading a formula.
+func escapeFormula(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func joinSubjectNames(subjects []csb.Subject) string {
+	names := make([]string, len(subjects))
+	for i, v := range subjects {
+		names[i] = v.Name
+	}
+
+	return strings.Join(names, ";")
+}
+
+func joinSubjectCodes(subjects []csb.Subject) string {
+	codes := make([]string, len(subjects))
+	for i, v := range subjects {
+		codes[i] = v.EngageCode
+	}
+
+	return strings.Join(codes, ";")
+}
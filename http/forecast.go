@@ -0,0 +1,36 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerForecastRoutes registers all the routes of the forecast service.
+func (s *Server) registerForecastRoutes(r chi.Router) {
+	r.Post("/", s.handleForecastStudent)
+}
+
+// POST "/forecasts"
+//
+// handleForecastStudent parses a forecast filter from the request body and projects the
+// marks of the student on the next term, per subject and overall.
+func (s *Server) handleForecastStudent(w http.ResponseWriter, r *http.Request) {
+	var filter csb.ForecastFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	forecast, err := s.ForecastService.ForecastStudent(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, forecast); err != nil {
+		LogError(r, err)
+	}
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerGradeRoutes registers all the routes of the grade service.
+func (s *Server) registerGradeRoutes(r chi.Router) {
+	// grade scales CRUD methods.
+	r.Get("/", s.handleGetGradeScales)
+	r.Post("/", s.handleCreateGradeScale)
+	r.Get("/{id}", s.handleGetGradeScale)
+	r.Delete("/{id}", s.handleDeleteGradeScale)
+}
+
+// GET "/grades"
+//
+// handleGetGradeScales finds all the grade scales, the scales can be filtered with the
+// optional name, subject_id, year and academic_year query parameters.
+func (s *Server) handleGetGradeScales(w http.ResponseWriter, r *http.Request) {
+	var filter csb.GradeScaleFilter
+	var err error
+	if v := r.URL.Query().Get("name"); v != "" {
+		filter.Name = &v
+	}
+	if filter.SubjectID, err = queryInt(r, "subject_id"); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	if filter.Year, err = queryInt(r, "year"); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	if filter.AcademicYear, err = queryInt(r, "academic_year"); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	scales, err := s.GradeService.FindGradeScales(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, scales); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/grades"
+//
+// handleCreateGradeScale parses a grade scale from the request body and creates it. returns
+// 409 if the scale family already has a scale with the same scope and the created scale with
+// status 201 otherwise.
+func (s *Server) handleCreateGradeScale(w http.ResponseWriter, r *http.Request) {
+	var scale csb.GradeScale
+	if err := json.NewDecoder(r.Body).Decode(&scale); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	if err := s.GradeService.CreateGradeScale(r.Context(), &scale); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := WriteJSON(w, scale); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/grades/{id}"
+//
+// handleGetGradeScale gets the grade scale with the provided id. returns 404 if the scale
+// isnt found.
+func (s *Server) handleGetGradeScale(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	scale, err := s.GradeService.FindGradeScaleByID(r.Context(), id)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, scale); err != nil {
+		LogError(r, err)
+	}
+}
+
+// DELETE "/grades/{id}"
+//
+// handleDeleteGradeScale permanently deletes the grade scale with the provided id. returns
+// 404 if the scale isnt found and 204 if the delete is sucessful.
+func (s *Server) handleDeleteGradeScale(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	if err := s.GradeService.DeleteGradeScale(r.Context(), id); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,11 +3,15 @@ package http
 import (
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/trace"
 )
 
 const (
@@ -18,6 +22,12 @@ const (
 	websocketWriteTimeout    = 5 * time.Second
 )
 
+// requestIDHeader carries the trace id of the request, the request ids sent by the clients
+// are kept if they match requestIDRegexp.
+const requestIDHeader = "X-Request-ID"
+
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
 // errResponse represents the strucuture of an error sent over http.
 type errResponse struct {
 	Status int    `json:"status"`
@@ -37,11 +47,110 @@ func SendErr(w http.ResponseWriter, r *http.Request, err error) {
 	WriteJSON(w, errResponse{Status: status, Trace: message})
 }
 
+// LogError logs the error of the request as a json log line with the trace id of the request.
 func LogError(r *http.Request, err error) {
-	log.Printf("[HTTP] error: %s %s: %s\n", r.URL.Path, r.Method, err)
+	trace.LogError(r.Context(), "http request failed", err, trace.Fields{
+		"component": "http",
+		"method":    r.Method,
+		"path":      r.URL.Path,
+	})
 }
 
 func WriteJSON(w io.Writer, data interface{}) error {
 	enc := json.NewEncoder(w)
 	return enc.Encode(data)
 }
+
+// queryInt parses the optional integer query parameter with the provided name.
+//
+// returns EINVALID if the parameter isnt an integer.
+func queryInt(r *http.Request, name string) (*int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, csb.Errorf(csb.EINVALID, "invalid %v format", name)
+	}
+	return &v, nil
+}
+
+// queryList parses the optional comma separated query parameter with the provided name.
+func queryList(r *http.Request, name string) []string {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil
+	}
+
+	return strings.Split(raw, ",")
+}
+
+// selectFields returns the JSON objects of the values with only the provided fields, the
+// values are returned as they are if no fields are provided.
+//
+// returns EINVALID if a field isnt a JSON field of T.
+func selectFields[T any](values []T, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return values, nil
+	}
+
+	out := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		selected, err := selectFieldsOf(v, fields)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, selected)
+	}
+
+	return out, nil
+}
+
+// selectFieldsOf returns the JSON object of the value with only the provided fields, the
+// value is returned as it is if no fields are provided.
+//
+// returns EINVALID if a field isnt a JSON field of T.
+func selectFieldsOf[T any](v T, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	valid := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			valid[name] = struct{}{}
+		}
+	}
+	for _, field := range fields {
+		if _, ok := valid[field]; !ok {
+			return nil, csb.Errorf(csb.EINVALID, "invalid field: %v", field)
+		}
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &all); err != nil {
+		return nil, err
+	}
+
+	// empty associations are omitted from the encoding.
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if raw, ok := all[field]; ok {
+			selected[field] = raw
+		}
+	}
+
+	return selected, nil
+}
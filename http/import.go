@@ -0,0 +1,220 @@
+package http
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// importColumns are the columns required in the header of a marks import csv.
+var importColumns = []string{"pid", "subject", "teacher", "percentage", "academic_year", "term", "importance"}
+
+// registerImportRoutes registers all the routes of the import service.
+func (s *Server) registerImportRoutes(r chi.Router) {
+	r.Post("/marks", s.handleImportMarks)
+
+	// batch methods.
+	r.Get("/", s.handleGetImportBatches)
+	r.Get("/{id}", s.handleGetImportBatch)
+	r.Delete("/{id}", s.handleRollbackImportBatch)
+}
+
+// POST "/imports/marks"
+//
+// handleImportMarks parses a csv of marks from the request body and imports them. The csv
+// must have a header with the columns: pid, subject, teacher, percentage, academic_year, term
+// and importance. The subject column takes either an engage code or a subject name.
+//
+// If the dry_run query parameter is true the rows are only validated.
+//
+// It returns the import report, with status 201 if the marks were imported and status 400
+// if any row is invalid.
+func (s *Server) handleImportMarks(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	rows, rowErrs, err := decodeMarkImportCSV(r.Body)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	// rows which couldnt be parsed are still reported along side the validation errors
+	// of the other rows, but nothing is imported.
+	report, err := s.ImportService.ImportMarks(r.Context(), csb.MarkImport{
+		Rows:   rows,
+		DryRun: dryRun || len(rowErrs) > 0,
+	})
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	report.DryRun = dryRun
+	report.Errors = append(report.Errors, rowErrs...)
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+
+	switch {
+	case len(report.Errors) > 0:
+		w.WriteHeader(http.StatusBadRequest)
+	case report.Batch != nil:
+		w.WriteHeader(http.StatusCreated)
+	}
+	if err := WriteJSON(w, report); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/imports"
+//
+// handleGetImportBatches gets all the import batches.
+func (s *Server) handleGetImportBatches(w http.ResponseWriter, r *http.Request) {
+	batches, err := s.ImportService.FindImportBatches(r.Context())
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, batches); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/imports/{id}"
+//
+// handleGetImportBatch gets the import batch with the provided id. returns 404 if the batch
+// isnt found.
+func (s *Server) handleGetImportBatch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	batch, err := s.ImportService.FindImportBatchByID(r.Context(), id)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, batch); err != nil {
+		LogError(r, err)
+	}
+}
+
+// DELETE "/imports/{id}"
+//
+// handleRollbackImportBatch rolls back the import batch with the provided id, deleting all
+// the marks imported with it. returns 404 if the batch isnt found, 409 if it was already
+// rolled back and 204 if the rollback is sucessful.
+func (s *Server) handleRollbackImportBatch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	if err := s.ImportService.RollbackImportBatch(r.Context(), id); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeMarkImportCSV decodes the rows of a marks import csv. Rows which cant be parsed are
+// returned as row errors.
+//
+// returns EINVALID if the header is missing any of the import columns.
+func decodeMarkImportCSV(r io.Reader) ([]csb.MarkImportRow, []csb.ImportRowError, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, csb.Errorf(csb.EINVALID, "decode: empty csv")
+	} else if err != nil {
+		return nil, nil, csb.Errorf(csb.EINVALID, "decode: invalid csv header")
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range importColumns {
+		if _, ok := index[name]; !ok {
+			return nil, nil, csb.Errorf(csb.EINVALID, "decode: csv header missing column: %v", name)
+		}
+	}
+
+	rows := make([]csb.MarkImportRow, 0)
+	rowErrs := make([]csb.ImportRowError, 0)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrs = append(rowErrs, csb.ImportRowError{Line: parseErr.StartLine, Message: parseErr.Err.Error()})
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		row, err := decodeMarkImportRecord(index, record)
+		if err != nil {
+			rowErrs = append(rowErrs, csb.ImportRowError{Line: line, Message: err.Error()})
+			continue
+		}
+		row.Line = line
+
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrs, nil
+}
+
+func decodeMarkImportRecord(index map[string]int, record []string) (row csb.MarkImportRow, err error) {
+	field := func(name string) string {
+		return strings.TrimSpace(record[index[name]])
+	}
+	integer := func(name string) (int, error) {
+		v, err := strconv.Atoi(field(name))
+		if err != nil {
+			return 0, fmt.Errorf("invalid %v: %q", name, field(name))
+		}
+		return v, nil
+	}
+
+	if row.PID, err = integer("pid"); err != nil {
+		return row, err
+	}
+	if row.Percentage, err = integer("percentage"); err != nil {
+		return row, err
+	}
+	if row.Period.AcademicYear, err = integer("academic_year"); err != nil {
+		return row, err
+	}
+
+	term, err := integer("term")
+	if err != nil {
+		return row, err
+	}
+	importance := field("importance")
+	if importance == "" {
+		return row, errors.New("missing importance")
+	}
+	row.Period.Term, row.Period.Importance = &term, &importance
+
+	row.Subject = field("subject")
+	row.Teacher = field("teacher")
+	return row, nil
+}
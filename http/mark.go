@@ -0,0 +1,103 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerMarkRoutes registers all the routes of the mark service.
+func (s *Server) registerMarkRoutes(r chi.Router) {
+	r.Post("/", s.handleGetMarks)
+	r.Get("/{id}", s.handleGetMark)
+	r.Delete("/{id}", s.handleDeleteMark)
+}
+
+// findMarksResponse represents a page of marks sent over http.
+type findMarksResponse struct {
+	Marks interface{} `json:"marks"`
+	csb.Page
+}
+
+// POST "/marks"
+//
+// handleGetMarks parses a marks filter from the request body and finds a page of the marks
+// with the provided filter, along side the total count and the next cursor.
+//
+// The optional include query parameter adds to the associations of the filter and the
+// optional fields query parameter selects the fields of each mark.
+func (s *Server) handleGetMarks(w http.ResponseWriter, r *http.Request) {
+	var filter csb.MarksFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+	filter.Include = append(filter.Include, queryList(r, "include")...)
+
+	marks, page, err := s.MarkService.FindMarks(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	selected, err := selectFields(marks, queryList(r, "fields"))
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, findMarksResponse{Marks: selected, Page: page}); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/marks/{id}"
+//
+// handleGetMark gets the mark with the provided id. returns 404 if the mark isnt found.
+//
+// The associations are loaded with the optional include query parameter and the fields of
+// the mark are selected with the optional fields query parameter.
+func (s *Server) handleGetMark(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	mark, err := s.MarkService.FindMarkByID(r.Context(), id, queryList(r, "include"))
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	selected, err := selectFieldsOf(mark, queryList(r, "fields"))
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, selected); err != nil {
+		LogError(r, err)
+	}
+}
+
+// DELETE "/marks/{id}"
+//
+// handleDeleteMark permanently deletes the mark with the provided id. returns 404 if the
+// mark isnt found and 204 if the deletion is sucessful.
+func (s *Server) handleDeleteMark(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	if err := s.MarkService.DeleteMark(r.Context(), id); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerPeriodRoutes registers all the routes of the period service.
+func (s *Server) registerPeriodRoutes(r chi.Router) {
+	r.Post("/build", s.handleBuildPeriods)
+	r.Post("/exists", s.handlePeriodExists)
+	r.Post("/range", s.handlePeriodRange)
+}
+
+// buildPeriodsRequest represents the body of a request to build periods.
+type buildPeriodsRequest struct {
+	PID          int `json:"pid"`
+	AcademicYear int `json:"academic_year"`
+	Term         int `json:"term"`
+}
+
+// periodExistsRequest represents the body of a request to check a period.
+type periodExistsRequest struct {
+	PID    int        `json:"pid"`
+	Period csb.Period `json:"period"`
+}
+
+// periodRangeRequest represents the body of a request to generate a range of periods.
+type periodRangeRequest struct {
+	PID  int        `json:"pid"`
+	From csb.Period `json:"from"`
+	To   csb.Period `json:"to"`
+}
+
+// POST "/periods/build"
+//
+// handleBuildPeriods parses the academic year, optional term and optional pid from the request
+// body and builds the underlying periods.
+func (s *Server) handleBuildPeriods(w http.ResponseWriter, r *http.Request) {
+	var req buildPeriodsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	periods, err := s.PeriodService.BuildPeriods(r.Context(), req.PID, req.AcademicYear, req.Term)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, periods); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/periods/exists"
+//
+// handlePeriodExists parses a period and optional pid from the request body and checks wether
+// the period exists.
+func (s *Server) handlePeriodExists(w http.ResponseWriter, r *http.Request) {
+	var req periodExistsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	exists, err := s.PeriodService.Exists(r.Context(), req.PID, req.Period)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, map[string]bool{"exists": exists}); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/periods/range"
+//
+// handlePeriodRange parses the bounds and optional pid from the request body and generates the
+// range of periods [from, to].
+func (s *Server) handlePeriodRange(w http.ResponseWriter, r *http.Request) {
+	var req periodRangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	periods, err := s.PeriodService.PeriodRange(r.Context(), req.PID, req.From, req.To)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, periods); err != nil {
+		LogError(r, err)
+	}
+}
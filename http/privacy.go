@@ -0,0 +1,111 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerPrivacyRoutes registers all the routes of the privacy service, only admins can reach
+// them.
+func (s *Server) registerPrivacyRoutes(r chi.Router) {
+	r.Use(s.requireAdminMiddleware)
+
+	r.Post("/retention", s.handleApplyRetention)
+	r.Get("/students/{pid}", s.handleExportStudentData)
+	r.Get("/erasures", s.handleGetErasureRequests)
+	r.Post("/erasures", s.handleCreateErasureRequest)
+}
+
+// POST "/privacy/retention"
+//
+// handleApplyRetention parses a retention policy from the request body and applies it to the
+// students who left the school.
+func (s *Server) handleApplyRetention(w http.ResponseWriter, r *http.Request) {
+	var policy csb.RetentionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	report, err := s.PrivacyService.ApplyRetention(r.Context(), policy)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, report); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/privacy/students/{pid}"
+//
+// handleExportStudentData exports all the data held on the student as an attachment, for
+// subject access requests. returns 404 if the student isnt found.
+func (s *Server) handleExportStudentData(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(chi.URLParam(r, "pid"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid pid format"))
+		return
+	}
+
+	data, err := s.PrivacyService.ExportStudentData(r.Context(), pid)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"student-%v.json\"", pid))
+	if err := WriteJSON(w, data); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/privacy/erasures"
+//
+// handleGetErasureRequests gets the recorded erasures, newest first. The erasures can be
+// filtered with the optional pid query parameter.
+func (s *Server) handleGetErasureRequests(w http.ResponseWriter, r *http.Request) {
+	pid, err := queryInt(r, "pid")
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	reqs, err := s.PrivacyService.FindErasureRequests(r.Context(), pid)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, reqs); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/privacy/erasures"
+//
+// handleCreateErasureRequest parses an erasure request from the request body and erases all
+// the data held on the student. returns 404 if the student isnt found.
+func (s *Server) handleCreateErasureRequest(w http.ResponseWriter, r *http.Request) {
+	var req csb.ErasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	if err := s.PrivacyService.CreateErasureRequest(r.Context(), &req); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := WriteJSON(w, req); err != nil {
+		LogError(r, err)
+	}
+}
@@ -0,0 +1,216 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerRankingRoutes registers all the routes of the ranking and weight services.
+func (s *Server) registerRankingRoutes(r chi.Router) {
+	r.Post("/", s.handleGenerateRankingsReport)
+	r.Post("/evolution", s.handleViewEvolution)
+
+	// backup ranks methods.
+	r.Post("/backups", s.handleCreateBackupRank)
+	r.Delete("/backups/{id}", s.handleDeleteRank)
+
+	// weight profiles methods.
+	r.Get("/weights", s.handleGetWeightProfiles)
+	r.Post("/weights", s.handleCreateWeightProfile)
+	r.Get("/weights/{name}", s.handleGetWeightProfile)
+	r.Delete("/weights/{name}", s.handleDeleteWeightProfile)
+}
+
+// POST "/rankings"
+//
+// handleGenerateRankingsReport parses a ranking filter from the request body and ranks all
+// the students on it.
+func (s *Server) handleGenerateRankingsReport(w http.ResponseWriter, r *http.Request) {
+	var filter csb.RankingFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	ranks, err := s.RankingService.GenerateRankingsReport(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, ranks); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/rankings/evolution"
+//
+// handleViewEvolution parses a student pid, period, subjects and offset from the request body
+// and returns the backup ranks of the student on them, oldest first.
+func (s *Server) handleViewEvolution(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PID      int           `json:"pid"`
+		Offset   int           `json:"offset"`
+		Period   csb.Period    `json:"period"`
+		Subjects []csb.Subject `json:"subjects"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	ranks, err := s.RankingService.ViewEvolution(r.Context(), req.PID, req.Offset, req.Period, req.Subjects)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, ranks); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/rankings/backups"
+//
+// handleCreateBackupRank parses a student pid, period, subjects and optional weight profile
+// from the request body and stores the rank of the student on them. returns the rank with
+// status 201.
+func (s *Server) handleCreateBackupRank(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PID           int                   `json:"pid"`
+		Period        csb.Period            `json:"period"`
+		Subjects      []csb.Subject         `json:"subjects"`
+		WeightProfile *csb.WeightProfileRef `json:"weight_profile"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	rank, err := s.RankingService.CreateBackupRank(r.Context(), req.PID, req.Period, req.Subjects, req.WeightProfile)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := WriteJSON(w, rank); err != nil {
+		LogError(r, err)
+	}
+}
+
+// DELETE "/rankings/backups/{id}"
+//
+// handleDeleteRank permanently deletes the backup rank with the provided id. returns 404 if
+// the rank isnt found and 204 if the delete is sucessful.
+func (s *Server) handleDeleteRank(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	if err := s.RankingService.DeleteRank(r.Context(), id); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET "/rankings/weights"
+//
+// handleGetWeightProfiles finds all the weight profiles, the profiles can be filtered with
+// the optional name query parameter and narrowed to their latest versions with the latest
+// query parameter. The archived versions are let through with the archived query parameter.
+func (s *Server) handleGetWeightProfiles(w http.ResponseWriter, r *http.Request) {
+	var filter csb.WeightProfileFilter
+	if v := r.URL.Query().Get("name"); v != "" {
+		filter.Name = &v
+	}
+	filter.Latest, _ = strconv.ParseBool(r.URL.Query().Get("latest"))
+	filter.Archived, _ = strconv.ParseBool(r.URL.Query().Get("archived"))
+
+	profiles, err := s.WeightService.FindWeightProfiles(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, profiles); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/rankings/weights"
+//
+// handleCreateWeightProfile parses a weight profile from the request body and creates it as
+// the next version of the profiles with the same name. returns the created profile with
+// status 201.
+func (s *Server) handleCreateWeightProfile(w http.ResponseWriter, r *http.Request) {
+	var profile csb.WeightProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	if err := s.WeightService.CreateWeightProfile(r.Context(), &profile); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := WriteJSON(w, profile); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/rankings/weights/{name}"
+//
+// handleGetWeightProfile gets the weight profile with the provided name, at the version
+// query parameter or its latest version. returns 404 if the profile isnt found.
+func (s *Server) handleGetWeightProfile(w http.ResponseWriter, r *http.Request) {
+	version, err := queryInt(r, "version")
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	profile, err := s.WeightService.FindWeightProfile(r.Context(), csb.WeightProfileRef{
+		Name:    chi.URLParam(r, "name"),
+		Version: version,
+	})
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, profile); err != nil {
+		LogError(r, err)
+	}
+}
+
+// DELETE "/rankings/weights/{name}"
+//
+// handleDeleteWeightProfile archives the weight profile with the provided name, at the version
+// query parameter or all its versions. returns 404 if the profile isnt found and 204 if the
+// delete is sucessful.
+func (s *Server) handleDeleteWeightProfile(w http.ResponseWriter, r *http.Request) {
+	version, err := queryInt(r, "version")
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := s.WeightService.DeleteWeightProfile(r.Context(), csb.WeightProfileRef{
+		Name:    chi.URLParam(r, "name"),
+		Version: version,
+	}); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"strings"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerSchoolRoutes registers the routes listing the schools and the routes of the other
+// schools. Listing the schools is limited to admins, the routes of a school can only be
+// reached with the token of the school or the admin token.
+func (s *Server) registerSchoolRoutes(r chi.Router) {
+	r.With(s.requireAdminMiddleware).Get("/", s.handleGetSchools)
+	r.Mount("/{school}", http.HandlerFunc(s.serveSchool))
+}
+
+// GET "/schools"
+//
+// handleGetSchools gets the other schools, sorted by id.
+func (s *Server) handleGetSchools(w http.ResponseWriter, r *http.Request) {
+	schools := make([]csb.School, 0, len(s.Schools))
+	for _, school := range s.Schools {
+		schools = append(schools, school.School)
+	}
+	sort.Slice(schools, func(i, j int) bool { return schools[i].ID < schools[j].ID })
+
+	if err := WriteJSON(w, schools); err != nil {
+		LogError(r, err)
+	}
+}
+
+// "/schools/{school}/*"
+//
+// serveSchool passes the request to the server of the school. returns 404 if the school
+// isnt found and 401 if the request has neither the token of the school nor the admin token.
+func (s *Server) serveSchool(w http.ResponseWriter, r *http.Request) {
+	school, ok := s.Schools[chi.URLParam(r, "school")]
+	if !ok {
+		SendErr(w, r, csb.Errorf(csb.ENOTFOUND, "school not found"))
+		return
+	}
+
+	if !hasToken(r, school.SchoolToken, s.AdminToken) {
+		SendErr(w, r, csb.Errorf(csb.EUNAUTHORIZED, "invalid school token"))
+		return
+	}
+
+	school.router.ServeHTTP(w, r)
+}
+
+// requireSchoolTokenMiddleware lets through the requests with the token of the school of the
+// server or the admin token. returns 401 otherwise. the routes stay open if neither token is
+// set so single school deployments keep working without tokens.
+func (s *Server) requireSchoolTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (s.SchoolToken != "" || s.AdminToken != "") && !hasToken(r, s.SchoolToken, s.AdminToken) {
+			SendErr(w, r, csb.Errorf(csb.EUNAUTHORIZED, "invalid school token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasToken reports wether the request has a bearer token matching any of the expected tokens.
+func hasToken(r *http.Request, expected ...string) bool {
+	auth := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if token == auth {
+		return false
+	}
+
+	for _, e := range expected {
+		if matchToken(token, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchToken reports wether the token matches the expected token in constant time, empty
+// expected tokens dont match any token.
+func matchToken(token, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
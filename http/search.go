@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerSearchRoutes registers all the routes of the search service.
+func (s *Server) registerSearchRoutes(r chi.Router) {
+	r.Get("/students", s.handleSearchStudents)
+}
+
+// GET "/search/students"
+//
+// handleSearchStudents searches the students by name with the q query parameter, returning
+// the matches ordered by relevance. The search can be narrowed with the optional
+// attends_school and limit query parameters.
+func (s *Server) handleSearchStudents(w http.ResponseWriter, r *http.Request) {
+	search := csb.StudentSearch{Query: r.URL.Query().Get("q")}
+	if v := r.URL.Query().Get("attends_school"); v != "" {
+		attends, err := strconv.ParseBool(v)
+		if err != nil {
+			SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid attends_school format"))
+			return
+		}
+		search.AttendsSchool = &attends
+	}
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	} else if limit != nil {
+		search.Limit = *limit
+	}
+
+	matches, err := s.SearchService.SearchStudents(r.Context(), search)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, matches); err != nil {
+		LogError(r, err)
+	}
+}
@@ -2,19 +2,42 @@ package http
 
 import (
 	"context"
-	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	csb "github.com/Lambels/CSB-Open-API"
 	"github.com/Lambels/CSB-Open-API/engage"
+	"github.com/Lambels/CSB-Open-API/trace"
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics of the requests by route and status, the routes of the other schools are under
+// /schools/{school}.
+var (
+	requestCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "csb_http_requests_total",
+		Help: "Total number of HTTP requests by route and status.",
+	}, []string{"route", "status"})
+
+	requestSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "csb_http_request_seconds",
+		Help: "HTTP request latency in seconds by route and status.",
+	}, []string{"route", "status"})
+
+	websocketSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "csb_http_websocket_subscribers",
+		Help: "Number of websocket connections subscribed to a transaction.",
+	})
 )
 
 // Server represents an http server which exposes the injected services over http.
@@ -31,13 +54,47 @@ type Server struct {
 	FrontendURL string
 	// The engage Token. This field is validated on each request and on startup.
 	Token string
+	// The admin token, sent as a bearer token to reach the admin routes. The admin routes
+	// are disabled if empty.
+	AdminToken string
+	// The key of the pseudonyms of the research exports, changing the key changes all the
+	// pseudonyms. The research exports are disabled if empty.
+	PseudonymKey string
+
+	// The school of the server, empty for the default school.
+	School csb.School
+	// The bearer token of the routes of the school, the routes of the school can only be
+	// reached with the admin token if empty. The routes of the school are open if both the
+	// school token and the admin token are empty.
+	SchoolToken string
+	// The pid of a student used to validate the engage token, defaults to
+	// csb.PatrickArvatuPID.
+	ValidationPID int
+	// Schools are the servers of the other schools by id, reached under /schools/{id}. The
+	// servers of the schools share the admin token of the server of the default school.
+	Schools map[string]*Server
 
 	// Services exposed via http.
-	WorkQueue      csb.WorkQueue
-	MarkService    csb.MarkService
-	StudentService csb.StudentService
-	PeriodService  csb.PeriodService
-	EngageClient   *engage.Client
+	WorkQueue         csb.WorkQueue
+	MarkService       csb.MarkService
+	StudentService    csb.StudentService
+	PeriodService     csb.PeriodService
+	RankingService    csb.RankingService
+	AlertService      csb.AlertService
+	ExportService     csb.ExportService
+	ImportService     csb.ImportService
+	StatisticsService csb.StatisticsService
+	GradeService      csb.GradeService
+	WeightService     csb.WeightService
+	ForecastService   csb.ForecastService
+	ComparisonService csb.ComparisonService
+	TeacherService    csb.TeacherService
+	SubjectService    csb.SubjectService
+	SearchService     csb.SearchService
+	BackupService     csb.BackupService
+	PrivacyService    csb.PrivacyService
+	AuditService      csb.AuditService
+	EngageClient      *engage.Client
 
 	// keep track of transaction contexts.
 	transactionMu      sync.Mutex
@@ -46,20 +103,15 @@ type Server struct {
 	closed atomic.Bool
 }
 
-// NewServer creates a new server instance.
+// NewServer creates a new server instance of the default school.
 func NewServer() *Server {
-	s := &Server{
-		server: &http.Server{},
-		router: chi.NewRouter(),
-		upgrader: &websocket.Upgrader{
-			HandshakeTimeout: 3 * time.Second,
-			CheckOrigin:      func(r *http.Request) bool { return true },
-		},
-	}
+	s := newServer()
 
 	// common middleware.
+	s.router.Use(traceMiddleware)
 	s.router.Use(chimw.Logger)
-	s.router.Use(s.validateTokenMiddleware)
+	s.router.Use(trackMetricsMiddleware)
+	s.router.Use(s.actorMiddleware)
 	s.router.Use(chimw.SetHeader("Content-Type", "application/json"))
 	s.router.Use(cors.Handler(
 		cors.Options{
@@ -69,33 +121,141 @@ func NewServer() *Server {
 		},
 	))
 
+	// prometheus metrics of the server, the work queues and the storage. The scrapes dont
+	// reach engage.
+	s.router.Handle("/metrics", promhttp.Handler())
+
+	s.router.Group(func(r chi.Router) {
+		// the routes of the default school need its token, the engage token of the other
+		// schools is validated by their servers.
+		r.Use(s.requireSchoolTokenMiddleware)
+		r.Use(s.validateTokenMiddleware)
+
+		s.registerRoutes(r)
+	})
+	// routes for listing the schools and reaching the routes of the other schools.
+	s.router.Route("/schools", func(r chi.Router) {
+		s.registerSchoolRoutes(r)
+	})
+
+	s.server.Handler = s.router
+	return s
+}
+
+// NewSchoolServer creates a new server instance of the school, it has to be added to the
+// Schools of the server of the default school which serves it.
+func NewSchoolServer(school csb.School) *Server {
+	s := newServer()
+	s.School = school
+
+	// the common middleware is run by the server of the default school.
+	s.router.Use(s.validateTokenMiddleware)
+
+	s.registerRoutes(s.router)
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		server: &http.Server{},
+		router: chi.NewRouter(),
+		upgrader: &websocket.Upgrader{
+			HandshakeTimeout: 3 * time.Second,
+			CheckOrigin:      func(r *http.Request) bool { return true },
+		},
+		cancelTransactions: make(map[int64]context.CancelFunc),
+	}
+}
+
+// registerRoutes registers the routes of the school of the server.
+func (s *Server) registerRoutes(r chi.Router) {
 	// routes for creating and reading transactions.
-	s.router.Route("/transactions", func(r chi.Router) {
+	r.Route("/transactions", func(r chi.Router) {
 		s.registerTransactionRoutes(r)
 	})
 	// routes for refreshing, getting and deleting students.
-	s.router.Route("/students", func(r chi.Router) {
+	r.Route("/students", func(r chi.Router) {
 		s.registerStudentRoutes(r)
 	})
 	// routes for refreshing, getting and deleting marks.
-	s.router.Route("/marks", func(r chi.Router) {
+	r.Route("/marks", func(r chi.Router) {
 		s.registerMarkRoutes(r)
 	})
 	// routes for building, validating and generating period ranges.
-	s.router.Route("/periods", func(r chi.Router) {
+	r.Route("/periods", func(r chi.Router) {
 		s.registerPeriodRoutes(r)
 	})
+	// routes for managing alert rules and reading raised alerts.
+	r.Route("/alerts", func(r chi.Router) {
+		s.registerAlertRoutes(r)
+	})
+	// routes for streaming students, marks and rankings as csv or ndjson.
+	r.Route("/exports", func(r chi.Router) {
+		s.registerExportRoutes(r)
+	})
+	// routes for importing marks and rolling back imports.
+	r.Route("/imports", func(r chi.Router) {
+		s.registerImportRoutes(r)
+	})
+	// routes for calculating statistics.
+	r.Route("/statistics", func(r chi.Router) {
+		s.registerStatisticsRoutes(r)
+	})
+	// routes for generating rankings, backing up ranks and managing weight profiles.
+	r.Route("/rankings", func(r chi.Router) {
+		s.registerRankingRoutes(r)
+	})
+	// routes for forecasting the marks of the students.
+	r.Route("/forecasts", func(r chi.Router) {
+		s.registerForecastRoutes(r)
+	})
+	// routes for comparing the marks of students.
+	r.Route("/comparisons", func(r chi.Router) {
+		s.registerComparisonRoutes(r)
+	})
+	// routes for getting teachers and managing their aliases.
+	r.Route("/teachers", func(r chi.Router) {
+		s.registerTeacherRoutes(r)
+	})
+	// routes for managing the subject catalog.
+	r.Route("/subjects", func(r chi.Router) {
+		s.registerSubjectRoutes(r)
+	})
+	// routes for searching the students.
+	r.Route("/search", func(r chi.Router) {
+		s.registerSearchRoutes(r)
+	})
 
-	s.server.Handler = s.router
-	return s
+	// routes for managing the grade scales.
+	r.Route("/grades", func(r chi.Router) {
+		s.registerGradeRoutes(r)
+	})
+
+	// admin routes for taking and downloading backups of the database.
+	r.Route("/backups", func(r chi.Router) {
+		s.registerBackupRoutes(r)
+	})
+	// admin routes for retention policies, subject access exports and erasures.
+	r.Route("/privacy", func(r chi.Router) {
+		s.registerPrivacyRoutes(r)
+	})
+	// admin routes for querying the audit log.
+	r.Route("/audit", func(r chi.Router) {
+		s.registerAuditRoutes(r)
+	})
 }
 
 // Listen validates the token and starts listening on the provided address using the
 // (*http.Server).Serve() method.
 func (s *Server) Listen() error {
-	if err := s.validateToken(); err != nil {
+	if err := s.validateToken(context.Background()); err != nil {
 		return err
 	}
+	for _, school := range s.Schools {
+		if err := school.validateToken(context.Background()); err != nil {
+			return err
+		}
+	}
 
 	ln, err := net.Listen("tcp", s.Addr)
 	if err != nil {
@@ -116,20 +276,73 @@ func (s *Server) Close() error {
 			return err
 		}
 
-		// close the work queue since the server is the only writer to the work queue.
+		// close the work queues since the servers are the only writers to the work queues.
+		for _, school := range s.Schools {
+			if err := school.WorkQueue.Close(); err != nil {
+				return err
+			}
+		}
 		return s.WorkQueue.Close()
 	}
 	return nil
 }
 
+// traceMiddleware starts the trace of the request, with the request id of the client if it
+// sent a valid one. The trace id is sent back as the request id.
+func traceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRegexp.MatchString(id) {
+			id = trace.NewTraceID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		ctx, span := trace.Start(trace.NewContextWithTraceID(r.Context(), id), "http.request")
+		span.SetAttribute("method", r.Method)
+		span.SetAttribute("path", r.URL.Path)
+		ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		span.SetAttribute("route", chi.RouteContext(r.Context()).RoutePattern())
+		span.SetAttribute("status", strconv.Itoa(ww.Status()))
+		span.Finish(nil)
+	})
+}
+
+// trackMetricsMiddleware counts and times the requests by route and status.
+func trackMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// the route is only known once the request is routed.
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unknown"
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		requestCount.WithLabelValues(route, strconv.Itoa(status)).Inc()
+		requestSeconds.WithLabelValues(route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
+
 // validateTokenMiddleware validates that before a request is carried out a valid token
 // is possesed.
 //
 // it closes the server if there is an invalid token.
 func (s *Server) validateTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if err := s.validateToken(); err != nil {
-			log.Printf("Invalid token %v, exiting...\n", err)
+		if err := s.validateToken(r.Context()); err != nil {
+			trace.LogError(r.Context(), "invalid engage token, exiting", err, trace.Fields{
+				"component": "http",
+				"method":    r.Method,
+				"path":      r.URL.Path,
+			})
 			s.Close()
 			return
 		}
@@ -138,7 +351,12 @@ func (s *Server) validateTokenMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func (s *Server) validateToken() error {
-	_, err := s.EngageClient.GetAcademicYears(context.Background(), csb.PatrickArvatuPID)
+func (s *Server) validateToken(ctx context.Context) error {
+	pid := s.ValidationPID
+	if pid == 0 {
+		pid = csb.PatrickArvatuPID
+	}
+
+	_, err := s.EngageClient.GetAcademicYears(ctx, pid)
 	return err
 }
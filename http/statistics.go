@@ -0,0 +1,36 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerStatisticsRoutes registers all the routes of the statistics service.
+func (s *Server) registerStatisticsRoutes(r chi.Router) {
+	r.Post("/marks", s.handleGetMarkStatistics)
+}
+
+// POST "/statistics/marks"
+//
+// handleGetMarkStatistics parses a statistics filter from the request body and calculates
+// the statistics of the marks scoped by the filter, for each group of marks.
+func (s *Server) handleGetMarkStatistics(w http.ResponseWriter, r *http.Request) {
+	var filter csb.StatisticsFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	stats, err := s.StatisticsService.MarkStatistics(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, stats); err != nil {
+		LogError(r, err)
+	}
+}
@@ -20,24 +20,40 @@ func (s *Server) registerStudentRoutes(r chi.Router) {
 	r.Post("/refresh", s.handleRefreshStudent)
 }
 
+// findStudentsResponse represents a page of students sent over http.
+type findStudentsResponse struct {
+	Students interface{} `json:"students"`
+	csb.Page
+}
+
 // POST "/students"
 //
-// handleGetStudents parses a student filter from the request body and finds all students
-// with the provided filter.
+// handleGetStudents parses a student filter from the request body and finds a page of the
+// students with the provided filter, along side the total count and the next cursor.
+//
+// The optional include query parameter adds to the associations of the filter and the
+// optional fields query parameter selects the fields of each student.
 func (s *Server) handleGetStudents(w http.ResponseWriter, r *http.Request) {
 	var filter csb.StudentFilter
 	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
 		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
 		return
 	}
+	filter.Include = append(filter.Include, queryList(r, "include")...)
+
+	students, page, err := s.StudentService.FindStudents(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
 
-	students, err := s.StudentService.FindStudents(r.Context(), filter)
+	selected, err := selectFields(students, queryList(r, "fields"))
 	if err != nil {
 		SendErr(w, r, err)
 		return
 	}
 
-	if err := WriteJSON(w, students); err != nil {
+	if err := WriteJSON(w, findStudentsResponse{Students: selected, Page: page}); err != nil {
 		LogError(r, err)
 	}
 }
@@ -46,6 +62,9 @@ func (s *Server) handleGetStudents(w http.ResponseWriter, r *http.Request) {
 //
 // handleGetStudent gets the student with the provided pupil ID. returns 404 if the student
 // isnt found.
+//
+// The associations are loaded with the optional include query parameter and the fields of
+// the student are selected with the optional fields query parameter.
 func (s *Server) handleGetStudent(w http.ResponseWriter, r *http.Request) {
 	pid, err := strconv.Atoi(chi.URLParam(r, "pid"))
 	if err != nil {
@@ -53,13 +72,19 @@ func (s *Server) handleGetStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	student, err := s.StudentService.FindStudentByPID(r.Context(), pid)
+	student, err := s.StudentService.FindStudentByPID(r.Context(), pid, queryList(r, "include"))
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	selected, err := selectFieldsOf(student, queryList(r, "fields"))
 	if err != nil {
 		SendErr(w, r, err)
 		return
 	}
 
-	if err := WriteJSON(w, student); err != nil {
+	if err := WriteJSON(w, selected); err != nil {
 		LogError(r, err)
 	}
 }
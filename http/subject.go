@@ -0,0 +1,164 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerSubjectRoutes registers all the routes of the subject service.
+func (s *Server) registerSubjectRoutes(r chi.Router) {
+	r.Get("/", s.handleGetSubjects)
+	r.Post("/", s.handleCreateSubject)
+	r.Get("/{id}", s.handleGetSubject)
+	r.Post("/{id}", s.handleUpdateSubject)
+	r.Delete("/{id}", s.handleDeleteSubject)
+
+	// engage methods.
+	r.Post("/sync", s.handleSyncSubjects)
+}
+
+// GET "/subjects"
+//
+// handleGetSubjects finds all the subjects of the catalog. The subjects can be filtered with
+// the optional code and name query parameters.
+func (s *Server) handleGetSubjects(w http.ResponseWriter, r *http.Request) {
+	var filter csb.SubjectFilter
+	if v := r.URL.Query().Get("code"); v != "" {
+		filter.EngageCode = &v
+	}
+	if v := r.URL.Query().Get("name"); v != "" {
+		filter.Name = &v
+	}
+
+	subjects, err := s.SubjectService.FindSubjects(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, subjects); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/subjects"
+//
+// handleCreateSubject parses a subject from the request body and creates it. returns 409 if
+// the engage code or name is taken.
+func (s *Server) handleCreateSubject(w http.ResponseWriter, r *http.Request) {
+	var subject csb.Subject
+	if err := json.NewDecoder(r.Body).Decode(&subject); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	if err := s.SubjectService.CreateSubject(r.Context(), &subject); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := WriteJSON(w, subject); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/subjects/{id}"
+//
+// handleGetSubject gets the subject with the provided id. returns 404 if the subject isnt
+// found.
+func (s *Server) handleGetSubject(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	subject, err := s.SubjectService.FindSubjectByID(r.Context(), id)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, subject); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/subjects/{id}"
+//
+// handleUpdateSubject parses a subject update from the request body and applies it to the
+// subject with the provided id. returns 404 if the subject isnt found and 409 if the engage
+// code or name is taken.
+func (s *Server) handleUpdateSubject(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	var upd csb.SubjectUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	subject, err := s.SubjectService.UpdateSubject(r.Context(), id, upd)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, subject); err != nil {
+		LogError(r, err)
+	}
+}
+
+// DELETE "/subjects/{id}"
+//
+// handleDeleteSubject deletes the subject with the provided id along with its marks. returns
+// 404 if the subject isnt found and 204 if the deletion is sucessful.
+func (s *Server) handleDeleteSubject(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	if err := s.SubjectService.DeleteSubject(r.Context(), id); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST "/subjects/sync"
+//
+// handleSyncSubjects parses the students to sync from the request body and queues a transaction
+// on the work queue syncing the subject catalog with their reporting subjects in engage. An
+// empty body syncs all the students attending school.
+//
+// It returns the scheduled transaction along side the transaction id.
+func (s *Server) handleSyncSubjects(w http.ResponseWriter, r *http.Request) {
+	var sync csb.SyncSubjects
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&sync); err != nil {
+			SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+			return
+		}
+	}
+
+	transaction, err := s.pushTransaction(r.Context(), sync)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, transaction); err != nil {
+		LogError(r, err)
+	}
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/go-chi/chi/v5"
+)
+
+// registerTeacherRoutes registers all the routes of the teacher service.
+func (s *Server) registerTeacherRoutes(r chi.Router) {
+	r.Get("/", s.handleGetTeachers)
+	r.Get("/{id}", s.handleGetTeacher)
+	r.Get("/{id}/marks", s.handleGetTeacherMarks)
+
+	// alias methods.
+	r.Post("/{id}/aliases", s.handleAddTeacherAlias)
+	r.Post("/{id}/merge", s.handleMergeTeachers)
+}
+
+// GET "/teachers"
+//
+// handleGetTeachers finds all the teachers with their aliases, subjects and academic years.
+// The teachers can be filtered with the optional name, subject_id and academic_year query
+// parameters.
+func (s *Server) handleGetTeachers(w http.ResponseWriter, r *http.Request) {
+	var filter csb.TeacherFilter
+	var err error
+	if v := r.URL.Query().Get("name"); v != "" {
+		filter.Name = &v
+	}
+	if filter.SubjectID, err = queryInt(r, "subject_id"); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	if filter.AcademicYear, err = queryInt(r, "academic_year"); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	teachers, err := s.TeacherService.FindTeachers(r.Context(), filter)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, teachers); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/teachers/{id}"
+//
+// handleGetTeacher gets the teacher with the provided id. returns 404 if the teacher isnt
+// found.
+func (s *Server) handleGetTeacher(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	teacher, err := s.TeacherService.FindTeacherByID(r.Context(), id)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, teacher); err != nil {
+		LogError(r, err)
+	}
+}
+
+// GET "/teachers/{id}/marks"
+//
+// handleGetTeacherMarks gets the marks issued by the teacher with the provided id. returns
+// 404 if the teacher isnt found.
+func (s *Server) handleGetTeacherMarks(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	marks, err := s.TeacherService.FindTeacherMarks(r.Context(), id)
+	if err != nil {
+		SendErr(w, r, err)
+		return
+	}
+
+	if err := WriteJSON(w, marks); err != nil {
+		LogError(r, err)
+	}
+}
+
+// POST "/teachers/{id}/aliases"
+//
+// handleAddTeacherAlias parses an alias from the request body and links it to the teacher
+// with the provided id. returns 404 if the teacher isnt found, 409 if the alias belongs to
+// another teacher and 204 if the alias is linked.
+func (s *Server) handleAddTeacherAlias(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	var req struct {
+		Alias string `json:"alias"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	if err := s.TeacherService.AddTeacherAlias(r.Context(), id, req.Alias); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST "/teachers/{id}/merge"
+//
+// handleMergeTeachers parses the id of another teacher from the request body and merges it
+// into the teacher with the provided id. returns 404 if any of the teachers isnt found and
+// 204 if the merge is sucessful.
+func (s *Server) handleMergeTeachers(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "invalid id format"))
+		return
+	}
+
+	var req struct {
+		From int `json:"from"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendErr(w, r, csb.Errorf(csb.EINVALID, "decode: invalid request body"))
+		return
+	}
+
+	if err := s.TeacherService.MergeTeachers(r.Context(), req.From, id); err != nil {
+		SendErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/trace"
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 )
@@ -41,6 +42,9 @@ func (s *Server) handleTransactionUpdates(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	websocketSubscribers.Inc()
+	defer websocketSubscribers.Dec()
+
 	timer := time.NewTicker(websocketPingConnections)
 	defer timer.Stop()
 	defer conn.Close()
@@ -106,16 +110,24 @@ func (s *Server) handleCancelTransaction(w http.ResponseWriter, r *http.Request)
 //
 // A transaction with a populated id field or an non nil error are returned.
 func (s *Server) pushTransaction(ctx context.Context, data any) (*csb.Transaction, error) {
-	ctx, cancel := context.WithCancel(ctx)
+	// the transaction outlives the request, it only keeps the trace id and the actor of the
+	// request. the changes of the transaction are made by a job on behalf of the actor.
+	detached := trace.NewContextWithTraceID(context.Background(), trace.TraceID(ctx))
+	detached = csb.NewContextWithActor(detached, "job:"+csb.ActorFromContext(ctx))
+	ctx, cancel := context.WithCancel(detached)
 	transaction := &csb.Transaction{
 		Data: data,
 		Ctx:  ctx,
 	}
 
 	if err := s.WorkQueue.Publish(transaction); err != nil {
+		cancel()
 		return nil, err
 	}
+
+	s.transactionMu.Lock()
 	s.cancelTransactions[transaction.Id] = cancel
+	s.transactionMu.Unlock()
 
 	return transaction, nil
 }
@@ -0,0 +1,86 @@
+package csb
+
+import (
+	"context"
+	"time"
+)
+
+// MarkImportRow represents a single mark to import.
+type MarkImportRow struct {
+	// Line is the line of the row in the imported file, used when reporting errors.
+	Line int `json:"line"`
+	// PID is the pupil ID of the student who recieved the mark.
+	PID int `json:"pid"`
+	// Subject is either the engage code or the name of the subject.
+	Subject string `json:"subject"`
+	// Teacher is the name of the teacher who gave the mark.
+	Teacher string `json:"teacher"`
+	// Percentage represents the grade recieved out of 100.
+	Percentage int `json:"percentage"`
+	// Period on which the mark was recieved, must be full.
+	Period Period `json:"period"`
+}
+
+// MarkImport represents a request to the ImportMarks service.
+type MarkImport struct {
+	// Rows are the marks to import.
+	Rows []MarkImportRow `json:"rows"`
+	// DryRun indicates wether the rows should only be validated and not imported.
+	DryRun bool `json:"dry_run"`
+}
+
+// ImportRowError represents an error with a single imported row.
+type ImportRowError struct {
+	// Line of the row with the error.
+	Line int `json:"line"`
+	// Message describes the error.
+	Message string `json:"message"`
+}
+
+// ImportBatch represents a group of marks imported together. Every imported mark links to
+// its batch so the whole import can be rolled back.
+type ImportBatch struct {
+	// ID of the batch.
+	ID int `json:"id"`
+	// Rows is the amount of marks imported with the batch.
+	Rows int `json:"rows"`
+	// Timestamps.
+	CreatedAt time.Time `json:"created_at"`
+	// RolledBackAt is populated if the batch was rolled back.
+	RolledBackAt *time.Time `json:"rolled_back_at"`
+}
+
+// ImportReport represents the outcome of an import.
+type ImportReport struct {
+	// Batch is the created batch, it is nil for dry runs or imports with errors.
+	Batch *ImportBatch `json:"batch"`
+	// DryRun indicates wether the import was a dry run.
+	DryRun bool `json:"dry_run"`
+	// Valid is the amount of rows which passed validation.
+	Valid int `json:"valid"`
+	// Errors are the errors of each invalid row.
+	Errors []ImportRowError `json:"errors"`
+}
+
+// ImportService represents a service used to import marks which never reached engage.
+type ImportService interface {
+	// ImportMarks validates each row of the import and imports all the rows in a single
+	// batch.
+	//
+	// If any of the rows is invalid nothing is imported and the row errors are reported.
+	ImportMarks(ctx context.Context, imp MarkImport) (*ImportReport, error)
+
+	// FindImportBatchByID returns the import batch with id = id.
+	//
+	// returns ENOTFOUND if the batch doesnt exist.
+	FindImportBatchByID(ctx context.Context, id int) (*ImportBatch, error)
+
+	// FindImportBatches returns all the import batches, newest first.
+	FindImportBatches(ctx context.Context) ([]*ImportBatch, error)
+
+	// RollbackImportBatch permanently deletes all the marks imported with the batch with
+	// id = id.
+	//
+	// returns ENOTFOUND if the batch doesnt exist and ECONFLICT if it was already rolled back.
+	RollbackImportBatch(ctx context.Context, id int) error
+}
@@ -0,0 +1,46 @@
+package csb
+
+import "strings"
+
+// Include represents the associations loaded along side a result. Nested associations are
+// joined with a dot, marks.subject includes the marks and the subject of each mark.
+type Include []string
+
+// Has reports wether the association or any association nested in it is included.
+func (i Include) Has(association string) bool {
+	for _, v := range i {
+		if v == association || strings.HasPrefix(v, association+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Nested returns the associations nested in the association.
+func (i Include) Nested(association string) Include {
+	out := make(Include, 0)
+	for _, v := range i {
+		if nested := strings.TrimPrefix(v, association+"."); nested != v {
+			out = append(out, nested)
+		}
+	}
+	return out
+}
+
+// Validate returns EINVALID if any association isnt one of the valid associations.
+func (i Include) Validate(valid ...string) error {
+	for _, v := range i {
+		ok := false
+		for _, association := range valid {
+			if v == association {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return Errorf(EINVALID, "validate: invalid association: %v", v)
+		}
+	}
+	return nil
+}
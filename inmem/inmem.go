@@ -0,0 +1,299 @@
+package inmem
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+// DB holds the students, subjects, teachers and marks in memory, shared by the services
+// created with it. It is safe for concurrent use.
+type DB struct {
+	mu sync.RWMutex
+
+	students map[int]*csb.Student
+	// takes holds the subject ids each student took in each academic year, by pid.
+	takes map[int]map[int]map[int]struct{}
+
+	subjectID int
+	subjects  map[int]csb.Subject
+
+	teacherID int
+	// teachers holds the teacher id of each alias, by lower case alias.
+	teachers map[string]int
+
+	markID int
+	marks  map[int]*csb.Mark
+}
+
+// NewDB creates a new empty in memory database.
+func NewDB() *DB {
+	return &DB{
+		students: make(map[int]*csb.Student),
+		takes:    make(map[int]map[int]map[int]struct{}),
+		subjects: make(map[int]csb.Subject),
+		teachers: make(map[string]int),
+		marks:    make(map[int]*csb.Mark),
+	}
+}
+
+// Fixture represents the structure of a json fixture file seeding the database.
+//
+// The subjects of the students are read from their subject history, the subject of a mark
+// is matched by id, engage code and then name.
+type Fixture struct {
+	Subjects []csb.Subject `json:"subjects"`
+	Students []csb.Student `json:"students"`
+	Marks    []csb.Mark    `json:"marks"`
+}
+
+// Load seeds the database with the fixture file at path.
+//
+// returns EINVALID if any subject, student or mark in the fixture isnt valid or the subject
+// of a mark doesnt exist and ECONFLICT if a subject or student already exists.
+func (db *DB) Load(path string) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(buf, &fixture); err != nil {
+		return csb.Errorf(csb.EINVALID, "invalid fixture format: %v", err)
+	}
+
+	return db.Seed(fixture)
+}
+
+// Seed seeds the database with the fixture, either the whole fixture is seeded or none of
+// it.
+func (db *DB) Seed(fixture Fixture) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// seed a copy so a failure leaves the database untouched.
+	next := db.clone()
+	for i := range fixture.Subjects {
+		if err := next.createSubject(&fixture.Subjects[i]); err != nil {
+			return err
+		}
+	}
+	for i := range fixture.Students {
+		if len(fixture.Students[i].Subjects) == 0 {
+			fixture.Students[i].Subjects = subjectsTaken(fixture.Students[i].SubjectHistory)
+		}
+		if err := next.createStudent(&fixture.Students[i]); err != nil {
+			return err
+		}
+	}
+	for i := range fixture.Marks {
+		mark := &fixture.Marks[i]
+		subject, err := next.findSubject(mark.Subject)
+		if csb.ErrorCode(err) == csb.ENOTFOUND {
+			return csb.Errorf(csb.EINVALID, "mark has unknown subject: %+v", mark.Subject)
+		} else if err != nil {
+			return err
+		}
+		mark.SubjectID = subject.ID
+
+		if _, ok := next.students[mark.StudentID]; !ok {
+			return csb.Errorf(csb.EINVALID, "mark has unknown student: %v", mark.StudentID)
+		}
+		if err := next.createMark(mark); err != nil {
+			return err
+		}
+	}
+
+	db.students, db.takes = next.students, next.takes
+	db.subjectID, db.subjects = next.subjectID, next.subjects
+	db.teacherID, db.teachers = next.teacherID, next.teachers
+	db.markID, db.marks = next.markID, next.marks
+	return nil
+}
+
+// clone returns a deep copy of the database, the caller must hold the lock.
+func (db *DB) clone() *DB {
+	out := NewDB()
+	out.subjectID, out.teacherID, out.markID = db.subjectID, db.teacherID, db.markID
+
+	for pid, student := range db.students {
+		out.students[pid] = copyStudent(student)
+	}
+	for pid, years := range db.takes {
+		out.takes[pid] = make(map[int]map[int]struct{}, len(years))
+		for academicYear, subjects := range years {
+			out.takes[pid][academicYear] = make(map[int]struct{}, len(subjects))
+			for id := range subjects {
+				out.takes[pid][academicYear][id] = struct{}{}
+			}
+		}
+	}
+	for id, subject := range db.subjects {
+		out.subjects[id] = subject
+	}
+	for alias, id := range db.teachers {
+		out.teachers[alias] = id
+	}
+	for id, mark := range db.marks {
+		out.marks[id] = copyMark(mark)
+	}
+
+	return out
+}
+
+// findStudentByPID returns a copy of the student with pid = pid without associations.
+//
+// returns ENOTFOUND if the student isnt found.
+func (db *DB) findStudentByPID(pid int) (*csb.Student, error) {
+	student, ok := db.students[pid]
+	if !ok {
+		return nil, csb.Errorf(csb.ENOTFOUND, "student not found")
+	}
+
+	return copyStudent(student), nil
+}
+
+func (db *DB) createStudent(student *csb.Student) error {
+	if err := student.Validate(); err != nil {
+		return err
+	}
+	if _, ok := db.students[student.PID]; ok {
+		return csb.Errorf(csb.ECONFLICT, "student %v already exists", student.PID)
+	}
+
+	student.CreatedAt = time.Now()
+	student.UpdatedAt = student.CreatedAt
+	if !student.AttendsSchool {
+		student.CurrentYear = 0
+	}
+
+	db.students[student.PID] = &csb.Student{
+		PID:           student.PID,
+		Name:          student.Name,
+		CurrentYear:   student.CurrentYear,
+		AttendsSchool: student.AttendsSchool,
+		CreatedAt:     student.CreatedAt,
+		UpdatedAt:     student.UpdatedAt,
+	}
+
+	return db.setStudentSubjects(student.PID, student.SubjectHistory)
+}
+
+func (db *DB) updateStudent(prev, next *csb.Student) error {
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	// replace the subjects of the academic years reported by engage.
+	if err := db.setStudentSubjects(prev.PID, next.SubjectHistory); err != nil {
+		return err
+	}
+
+	student := db.students[prev.PID]
+	student.Name = next.Name
+	student.AttendsSchool = next.AttendsSchool
+	student.CurrentYear = next.CurrentYear
+	if !next.AttendsSchool {
+		student.CurrentYear = 0
+	}
+	next.UpdatedAt = time.Now()
+	student.UpdatedAt = next.UpdatedAt
+
+	return nil
+}
+
+// deleteStudent deletes the student along side their subjects and marks.
+func (db *DB) deleteStudent(pid int) error {
+	if _, err := db.findStudentByPID(pid); err != nil {
+		return err
+	}
+
+	delete(db.students, pid)
+	delete(db.takes, pid)
+	for id, mark := range db.marks {
+		if mark.StudentID == pid {
+			delete(db.marks, id)
+		}
+	}
+
+	return nil
+}
+
+// setStudentSubjects replaces the subjects the student took in each academic year of the
+// history, the academic years missing from the history are left untouched.
+func (db *DB) setStudentSubjects(pid int, history []csb.SubjectsTaken) error {
+	for _, taken := range history {
+		subjects := make(map[int]struct{}, len(taken.Subjects))
+		for _, subject := range taken.Subjects {
+			found, err := db.attachSubject(subject)
+			if err != nil {
+				return err
+			}
+			subjects[found.ID] = struct{}{}
+		}
+
+		if db.takes[pid] == nil {
+			db.takes[pid] = make(map[int]map[int]struct{})
+		}
+		db.takes[pid][taken.AcademicYear] = subjects
+	}
+
+	return nil
+}
+
+// subjectsTaken returns the distinct subjects of the history.
+func subjectsTaken(history []csb.SubjectsTaken) []csb.Subject {
+	out := make([]csb.Subject, 0)
+	seen := make(map[string]struct{})
+	for _, taken := range history {
+		for _, subject := range taken.Subjects {
+			key := subject.EngageCode + "\x00" + subject.Name
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			out = append(out, subject)
+		}
+	}
+	return out
+}
+
+// resolveTeacher returns the id of the teacher the spelling of the name is an alias of,
+// creating a new teacher if the spelling is unknown.
+func (db *DB) resolveTeacher(name string) int {
+	alias := strings.ToLower(csb.NormalizeTeacherName(name))
+	if id, ok := db.teachers[alias]; ok {
+		return id
+	}
+
+	db.teacherID++
+	db.teachers[alias] = db.teacherID
+	return db.teacherID
+}
+
+func copyStudent(student *csb.Student) *csb.Student {
+	out := *student
+	return &out
+}
+
+func copyMark(mark *csb.Mark) *csb.Mark {
+	out := *mark
+	if mark.Period.Term != nil {
+		term := *mark.Period.Term
+		out.Period.Term = &term
+	}
+	if mark.Period.Importance != nil {
+		importance := *mark.Period.Importance
+		out.Period.Importance = &importance
+	}
+	if mark.ImportBatchID != nil {
+		id := *mark.ImportBatchID
+		out.ImportBatchID = &id
+	}
+	return &out
+}
@@ -0,0 +1,142 @@
+package inmem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/csbtest"
+)
+
+func TestConformance(t *testing.T) {
+	csbtest.TestStudentService(t, newServices)
+	csbtest.TestMarkService(t, newServices)
+}
+
+// newServices creates the services over a new empty database.
+func newServices(t *testing.T, e *csbtest.Engage) csbtest.Services {
+	db := NewDB()
+
+	return csbtest.Services{
+		Students: NewStudentService(db, e.Client(), false),
+		Marks:    NewMarkService(db, false, e.Client(), e.PeriodService()),
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+
+	db := NewDB()
+	students := NewStudentService(db, nil, false)
+	marks := NewMarkService(db, false, nil, nil)
+
+	// load writes the fixture to a file and loads it.
+	load := func(t *testing.T, fixture string) error {
+		t.Helper()
+
+		path := filepath.Join(t.TempDir(), "fixture.json")
+		if err := os.WriteFile(path, []byte(fixture), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return db.Load(path)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		err := load(t, `{
+			"subjects": [{"engage_code": "CL1-103", "name": "Mathematics"}],
+			"students": [{
+				"pid": 1001,
+				"name": "Ana Popescu",
+				"current_year": 11,
+				"attends_school": true,
+				"subject_history": [{"academic_year": 2022, "subjects": [{"engage_code": "CL1-103", "name": "Mathematics"}]}]
+			}],
+			"marks": [{
+				"student_id": 1001,
+				"subject": {"engage_code": "CL1-103"},
+				"teacher": "Mr Smith",
+				"percentage": 80,
+				"period": {"academic_year": 2022, "term": 1, "importance": "Midterm"}
+			}]
+		}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		student, err := students.FindStudentByPID(ctx, 1001, csb.Include{"subjects"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(student.Subjects) != 1 || student.Subjects[0].Name != "Mathematics" {
+			t.Fatalf("unexpected subjects: %+v", student.Subjects)
+		}
+		found, err := marks.FindMarksByPID(ctx, 1001)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 1 || found[0].Percentage != 80 || found[0].SubjectID == 0 {
+			t.Fatalf("unexpected marks: %+v", found)
+		}
+	})
+
+	// every fixture fails after seeding some of its records, none of them are kept.
+	for _, tt := range []struct {
+		name    string
+		fixture string
+		code    string
+	}{
+		{
+			name:    "InvalidFormat",
+			fixture: `{"students": [`,
+			code:    csb.EINVALID,
+		},
+		{
+			name: "UnknownMarkSubject",
+			fixture: `{
+				"subjects": [{"engage_code": "CL1-125", "name": "Physics"}],
+				"students": [{"pid": 1002, "name": "Ion Popa", "subjects": [{"engage_code": "CL1-125"}]}],
+				"marks": [{
+					"student_id": 1002,
+					"subject": {"engage_code": "CL1-999"},
+					"teacher": "Ms Jones",
+					"percentage": 55,
+					"period": {"academic_year": 2022, "term": 1, "importance": "Midterm"}
+				}]
+			}`,
+			code: csb.EINVALID,
+		},
+		{
+			name: "ConflictingStudent",
+			fixture: `{
+				"subjects": [{"engage_code": "CL1-125", "name": "Physics"}],
+				"students": [
+					{"pid": 1002, "name": "Ion Popa", "subjects": [{"engage_code": "CL1-125"}]},
+					{"pid": 1001, "name": "Ana Popescu", "subjects": [{"engage_code": "CL1-125"}]}
+				]
+			}`,
+			code: csb.ECONFLICT,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := load(t, tt.fixture); csb.ErrorCode(err) != tt.code {
+				t.Fatalf("expected error code %q, got: %v", tt.code, err)
+			}
+
+			if _, err := db.findStudentByPID(1002); csb.ErrorCode(err) != csb.ENOTFOUND {
+				t.Fatalf("expected the students of the fixture to be rolled back, got: %v", err)
+			}
+			if db.subjectID != 1 || len(db.subjects) != 1 {
+				t.Fatalf("expected the subjects of the fixture to be rolled back, got: %+v", db.subjects)
+			}
+			found, err := marks.FindMarksByPID(ctx, 1001)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != 1 {
+				t.Fatalf("expected the seeded marks to be kept, got: %+v", found)
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/engage"
+)
+
+var _ csb.MarkService = (*MarkService)(nil)
+
+// MarkService wraps around an engage client and period service, storing the marks in memory.
+//
+// Grade scales arent supported, filtering on a grade scale or a grade returns
+// ENOTIMPLEMENTED and new marks arent evaluated against alert rules.
+type MarkService struct {
+	// db for persistance.
+	db *DB
+	// client for updates.
+	c *engage.Client
+	// periodService is used to handle periods.
+	periodService csb.PeriodService
+	// fallback indicates wether failed searches should fallback on the engage client.
+	fallback bool
+}
+
+// NewMarkService creates a new mark service with the provided database, engage client and period service.
+func NewMarkService(db *DB, fallback bool, client *engage.Client, periodService csb.PeriodService) *MarkService {
+	return &MarkService{
+		db:            db,
+		c:             client,
+		periodService: periodService,
+		fallback:      fallback,
+	}
+}
+
+// FindMarkByID returns a marked based on the passed id with the included associations.
+//
+// returns ENOTFOUND if the mark isnt found.
+func (s *MarkService) FindMarkByID(ctx context.Context, id int, include csb.Include) (*csb.Mark, error) {
+	if err := include.Validate(csb.MarkAssociations...); err != nil {
+		return nil, err
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	mark, err := s.db.findMarkByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.db.attachMarkAssociations(mark, include)
+
+	return mark, nil
+}
+
+// FindMarksByPID returns a range of marks based on the pupil id.
+//
+// find marks only fetches local marks. To get all marks, use refresh handler.
+func (s *MarkService) FindMarksByPID(ctx context.Context, pid int) ([]*csb.Mark, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	marks := s.db.findMarks(csb.MarksFilter{PID: &pid})
+	if err := s.db.attachMarksStudent(pid, marks); err != nil {
+		return nil, err
+	}
+
+	return marks, nil
+}
+
+// FindMarksByPeriod returns a range of marks for the specified period.
+//
+// If the period is full, the request will use the fallback parameter and fallback on the engage
+// client optinally.
+//
+// If the period isnt full, the request will simply provide the local data.
+func (s *MarkService) FindMarksByPeriod(ctx context.Context, pid int, period csb.Period) ([]*csb.Mark, error) {
+	full, err := period.Full()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := csb.MarksFilter{PID: &pid, Periods: []csb.Period{period}}
+	if full && s.fallback {
+		marksEngage, err := s.findMarksByFullPeriodEngage(ctx, pid, period)
+		if err != nil {
+			return nil, err
+		}
+
+		s.db.mu.Lock()
+		defer s.db.mu.Unlock()
+
+		if err := s.db.createDiff(pid, s.db.findMarks(filter), marksEngage); err != nil {
+			return nil, err
+		}
+		return marksEngage, nil
+	} else if !full { // if the period isnt full use local data since we are potentially dealing with allot of data.
+		if filter.Periods, err = s.periodService.BuildPeriods(ctx, pid, period.AcademicYear, *period.Term); err != nil {
+			return nil, err
+		}
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	marks := s.db.findMarks(filter)
+	if err := s.db.attachMarksStudent(pid, marks); err != nil {
+		return nil, err
+	}
+
+	return marks, nil
+}
+
+// FindMarksByPeriodRange returns a range of marks in the specified period range.
+//
+// All the data will be fetched from memory.
+func (s *MarkService) FindMarksByPeriodRange(ctx context.Context, from, to csb.Period, filter csb.MarksFilter) (_ []*csb.Mark, err error) {
+	if filter.PID == nil {
+		return nil, csb.Errorf(csb.EINVALID, "cannot generate marks over period range without a student id")
+	}
+	if err := validateMarksFilter(filter); err != nil {
+		return nil, err
+	}
+
+	// if valid id provided save requests to engage for building period range.
+	if filter.ID != nil {
+		s.db.mu.RLock()
+		defer s.db.mu.RUnlock()
+
+		mark, err := s.db.findMarkByID(*filter.ID)
+		if err != nil {
+			return nil, err
+		}
+		s.db.attachMarkAssociations(mark, csb.Include{"student"})
+
+		return []*csb.Mark{mark}, nil
+	}
+
+	filter.Periods, err = s.periodService.PeriodRange(ctx, *filter.PID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	marks := s.db.findMarks(filter)
+	if err := s.db.attachMarksStudent(*filter.PID, marks); err != nil {
+		return nil, err
+	}
+	return marks, nil
+}
+
+// FindMarks returns a page of marks based on filter with the included associations. Pages
+// without a limit have csb.DefaultPageSize marks and limits are capped to csb.MaxPageSize.
+func (s *MarkService) FindMarks(ctx context.Context, filter csb.MarksFilter) ([]*csb.Mark, csb.Page, error) {
+	if err := filter.Include.Validate(csb.MarkAssociations...); err != nil {
+		return nil, csb.Page{}, err
+	}
+	if err := validateMarksFilter(filter); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	p, err := newPage(csb.MarkSortKeys, "id", filter.Sort, filter.Limit, filter.Offset, filter.Cursor)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	marks, page := p.apply(s.db.findMarks(filter))
+	for _, mark := range marks {
+		s.db.attachMarkAssociations(mark, filter.Include)
+	}
+
+	return marks, page, nil
+}
+
+// DeleteMark permanently deletes a mark with the specified id.
+//
+// returns ENOTFOUND if the mark isnt found.
+func (s *MarkService) DeleteMark(ctx context.Context, id int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, err := s.db.findMarkByID(id); err != nil {
+		return err
+	}
+	delete(s.db.marks, id)
+
+	return nil
+}
+
+// RefreshMarks refreshes marks for the student with pid = pid from the period range.
+//
+// It only checks for new marks since it is very uncommon that a mark gets updated or
+// deleted. The periods are refreshed one at a time, periods refreshed before an error are
+// kept.
+func (s *MarkService) RefreshMarks(ctx context.Context, pid int, from, to csb.Period) error {
+	s.db.mu.RLock()
+	_, err := s.db.findStudentByPID(pid)
+	s.db.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	periods, err := s.periodService.PeriodRange(ctx, pid, from, to)
+	if err != nil {
+		return err
+	}
+
+	for len(periods) > 0 {
+		period := periods[0]
+
+		marksEngage, err := s.findMarksByFullPeriodEngage(ctx, pid, period)
+		if err != nil {
+			return err
+		}
+
+		s.db.mu.Lock()
+		marksLocal := s.db.findMarks(csb.MarksFilter{PID: &pid, Periods: []csb.Period{period}})
+		err = s.db.createDiff(pid, marksLocal, marksEngage)
+		s.db.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("refresh marks: %w", ctx.Err())
+		case <-time.After(engage.RequestTimeout):
+		}
+		periods = periods[1:]
+	}
+
+	return nil
+}
+
+func (s *MarkService) findMarksByFullPeriodEngage(ctx context.Context, pid int, period csb.Period) ([]*csb.Mark, error) {
+	engageTerm, err := s.periodService.PeriodToEngageTerm(ctx, pid, period)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.c.FindMarks(ctx, pid, period, engageTerm)
+}
+
+// validateMarksFilter returns ENOTIMPLEMENTED if the filter has a grade scale or a grade.
+func validateMarksFilter(filter csb.MarksFilter) error {
+	if filter.GradeScale != nil || filter.Grade != nil {
+		return csb.Errorf(csb.ENOTIMPLEMENTED, "grade scales arent supported in memory")
+	}
+	return nil
+}
+
+// findMarkByID returns a copy of the mark with id = id, with its subject.
+//
+// returns ENOTFOUND if the mark isnt found.
+func (db *DB) findMarkByID(id int) (*csb.Mark, error) {
+	mark, ok := db.marks[id]
+	if !ok {
+		return nil, csb.Errorf(csb.ENOTFOUND, "mark not found")
+	}
+
+	out := copyMark(mark)
+	out.Subject = db.subjects[out.SubjectID]
+	return out, nil
+}
+
+// findMarks returns copies of the marks matching the filter in id order, with their subject.
+// The sort, limit, offset and cursor of the filter are ignored.
+func (db *DB) findMarks(filter csb.MarksFilter) []*csb.Mark {
+	// resolve the teacher once, unknown teachers match no marks.
+	teacherID := -1
+	if v := filter.Teacher; v != nil {
+		if id, ok := db.teachers[strings.ToLower(csb.NormalizeTeacherName(*v))]; ok {
+			teacherID = id
+		}
+	}
+
+	marks := make([]*csb.Mark, 0)
+	for id := 1; id <= db.markID; id++ {
+		mark, ok := db.marks[id]
+		if !ok {
+			continue
+		}
+
+		if v := filter.ID; v != nil && mark.ID != *v {
+			continue
+		}
+		if v := filter.PID; v != nil && mark.StudentID != *v {
+			continue
+		}
+		if filter.Teacher != nil && mark.TeacherID != teacherID {
+			continue
+		}
+		if v := filter.TeacherID; v != nil && mark.TeacherID != *v {
+			continue
+		}
+		if v := filter.MinPercentage; v != nil && mark.Percentage < *v {
+			continue
+		}
+		if v := filter.MaxPercentage; v != nil && mark.Percentage > *v {
+			continue
+		}
+		if len(filter.Periods) > 0 && !matchAnyPeriod(mark.Period, filter.Periods) {
+			continue
+		}
+		if len(filter.Subjects) > 0 && !matchAnySubject(db.subjects[mark.SubjectID], filter.Subjects) {
+			continue
+		}
+
+		out := copyMark(mark)
+		out.Subject = db.subjects[out.SubjectID]
+		marks = append(marks, out)
+	}
+
+	return marks
+}
+
+func (db *DB) createMark(mark *csb.Mark) error {
+	if err := mark.Validate(); err != nil {
+		return err
+	}
+
+	mark.TeacherID = db.resolveTeacher(mark.Teacher)
+	mark.CreatedAt = time.Now()
+
+	db.markID++
+	mark.ID = db.markID
+
+	stored := copyMark(mark)
+	stored.Subject, stored.Student = csb.Subject{}, nil
+	db.marks[mark.ID] = stored
+
+	return nil
+}
+
+// createDiff adds the engage marks with subjects missing from the local marks of the same
+// period and attaches the student and subject to the engage marks.
+func (db *DB) createDiff(pid int, local, engage []*csb.Mark) error {
+	if err := db.attachMarksStudent(pid, engage); err != nil {
+		return err
+	}
+
+	// marks in the same period can only have different subjects, do a shallow difference
+	// check on only the subjects.
+	diff := make(map[int]struct{}, len(local))
+	for _, markLocal := range local {
+		diff[markLocal.SubjectID] = struct{}{}
+	}
+
+	for _, markEngage := range engage {
+		if _, ok := diff[markEngage.SubjectID]; !ok {
+			if err := db.createMark(markEngage); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// attachMarkAssociations attaches the included associations of the mark. The subject is
+// already read with the mark.
+func (db *DB) attachMarkAssociations(mark *csb.Mark, include csb.Include) {
+	if !include.Has("student") {
+		return
+	}
+
+	student, err := db.findStudentByPID(mark.StudentID)
+	if err != nil {
+		return
+	}
+	mark.Student = student
+	db.attachStudentAssociations(mark.Student, include.Nested("student"))
+}
+
+// attachMarksStudent attaches the student with pid = pid to the marks and resolves the
+// subject of the marks read from engage by name.
+//
+// returns ENOTFOUND if the student or a subject isnt found.
+func (db *DB) attachMarksStudent(pid int, marks []*csb.Mark) error {
+	student, err := db.findStudentByPID(pid)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range marks {
+		m.Student = student
+		if m.SubjectID != 0 {
+			continue
+		}
+
+		if m.Subject, err = db.findSubject(csb.Subject{Name: m.Subject.Name}); err != nil {
+			return err
+		}
+		m.SubjectID = m.Subject.ID
+	}
+
+	return nil
+}
+
+// matchAnyPeriod reports wether the period matches the populated fields of any of periods.
+func matchAnyPeriod(period csb.Period, periods []csb.Period) bool {
+	for _, p := range periods {
+		if period.AcademicYear != p.AcademicYear {
+			continue
+		}
+		if p.Term != nil && *period.Term != *p.Term {
+			continue
+		}
+		if p.Importance != nil && *period.Importance != *p.Importance {
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+// matchAnySubject reports wether the subject matches any of subjects.
+func matchAnySubject(subject csb.Subject, subjects []csb.Subject) bool {
+	for _, s := range subjects {
+		if matchSubject(subject, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package inmem
+
+import (
+	"sort"
+	"strings"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+// page represents a page request with its sort resolved to the sort key of the results.
+type page[T any] struct {
+	*csb.PageRequest
+	// key returns the sort key of a result, the primary key is always the last value so the
+	// order is total.
+	key func(T) []interface{}
+}
+
+// newPage resolves the sort, limit, offset and cursor of a filter against the sort keys.
+//
+// returns EINVALID if the sort field is unknown, the cursor doesnt belong to the sort or an
+// offset is combined with a cursor.
+func newPage[T any](sorts map[string]func(T) []interface{}, fallback, sort string, limit, offset int, cursor string) (*page[T], error) {
+	req, err := csb.NewPageRequest(sorts, fallback, sort, limit, offset, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page[T]{PageRequest: req, key: sorts[req.Field]}, nil
+}
+
+// apply sorts the results and returns the results in the page along side the total and
+// the next cursor.
+func (p *page[T]) apply(results []T) ([]T, csb.Page) {
+	page := csb.Page{Total: len(results)}
+
+	sort.Slice(results, func(i, j int) bool {
+		c := compareKeys(p.key(results[i]), p.key(results[j]))
+		if p.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	// skip the results up to the cursor.
+	from := 0
+	if p.After != nil {
+		from = sort.Search(len(results), func(i int) bool {
+			c := compareKeys(p.key(results[i]), p.After)
+			if p.Desc {
+				return c < 0
+			}
+			return c > 0
+		})
+	}
+	from += p.Offset
+	if from > len(results) {
+		from = len(results)
+	}
+
+	to := from + p.Limit
+	if to >= len(results) {
+		return results[from:], page
+	}
+
+	// the results after the page tell that there is a next page.
+	page.NextCursor = p.Cursor(p.key(results[to-1]))
+
+	return results[from:to], page
+}
+
+// compareKeys compares two sort keys value by value. Numbers are compared as float64 since
+// the keys of a cursor are decoded from json.
+func compareKeys(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		switch x := a[i].(type) {
+		case string:
+			y, _ := b[i].(string)
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		default:
+			fx, fy := toFloat(a[i]), toFloat(b[i])
+			switch {
+			case fx < fy:
+				return -1
+			case fx > fy:
+				return 1
+			}
+		}
+	}
+
+	return len(a) - len(b)
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		return 0
+	}
+}
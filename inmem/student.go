@@ -0,0 +1,225 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/engage"
+)
+
+var _ csb.StudentService = (*StudentService)(nil)
+
+// StudentService wraps around an engage client, storing the students in memory.
+type StudentService struct {
+	// db for persistance.
+	db *DB
+	// client for updates.
+	c *engage.Client
+	// fallback indicates wether fetch to new students should be saved.
+	fallback bool
+}
+
+// NewStudentService creates a new student service with the provided database and engage client.
+func NewStudentService(db *DB, client *engage.Client, fallback bool) *StudentService {
+	return &StudentService{
+		db:       db,
+		c:        client,
+		fallback: fallback,
+	}
+}
+
+// FindStudentByPID returns a student based on the passed pid.
+//
+// If the student isnt originally found in memory, the service will try to search engage
+// using the engage client, if the user isnt found, ultimately ENOTFOUND is returned.
+//
+// If the user is found in engage and not in memory and fallback is true the user is saved
+// before returned.
+//
+// Only the included associations are loaded, the subjects of students from engage are
+// dropped if they arent included.
+func (s *StudentService) FindStudentByPID(ctx context.Context, pid int, include csb.Include) (*csb.Student, error) {
+	if err := include.Validate(csb.StudentAssociations...); err != nil {
+		return nil, err
+	}
+
+	s.db.mu.RLock()
+	student, err := s.db.findStudentByPID(pid)
+	if err == nil {
+		s.db.attachStudentAssociations(student, include)
+	}
+	s.db.mu.RUnlock()
+
+	if csb.ErrorCode(err) != csb.ENOTFOUND {
+		return student, err
+	}
+
+	student, err = s.c.FindStudent(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	if s.fallback {
+		s.db.mu.Lock()
+		s.db.createStudent(student)
+		s.db.mu.Unlock()
+	}
+
+	// engage students come with their subjects, which are needed to save them.
+	if !include.Has("subjects") {
+		student.Subjects, student.SubjectHistory = nil, nil
+	}
+	return student, nil
+}
+
+// FindStudents returns a page of students based on the filter with the included
+// associations. Pages without a limit have csb.DefaultPageSize students and limits are capped to
+// csb.MaxPageSize.
+func (s *StudentService) FindStudents(ctx context.Context, filter csb.StudentFilter) ([]*csb.Student, csb.Page, error) {
+	if err := filter.Include.Validate(csb.StudentAssociations...); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	p, err := newPage(csb.StudentSortKeys, "pid", filter.Sort, filter.Limit, filter.Offset, filter.Cursor)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	students, page := p.apply(s.db.findStudents(filter))
+	for _, student := range students {
+		s.db.attachStudentAssociations(student, filter.Include)
+	}
+
+	return students, page, nil
+}
+
+// DeleteStudent permanently deletes a student specified by pid along side their marks.
+// returns ENOTFOUND if student isnt found.
+func (s *StudentService) DeleteStudent(ctx context.Context, pid int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.deleteStudent(pid)
+}
+
+// RefreshStudents refreshes students incrementally starting from refresh.StartPID, refresh.N
+// times.
+//
+// If a student is in engage but not in memory then the student is added. If refresh.Purge
+// is set to true and the student from engage is not attending school then the student wont
+// be added.
+//
+// If refresh.Purge is set to true and a student in memory is not attending the school any
+// more in engage, then the user is deleted.
+//
+// If the student is both in engage and in memory, an update will be made so that the
+// students in memory have the newest data.
+//
+// The students are refreshed one at a time, students refreshed before an error are kept.
+func (s *StudentService) RefreshStudents(ctx context.Context, refresh csb.RefreshStudents) error {
+	PIDCount := refresh.StartPID
+	for i := 0; i < refresh.N; i++ {
+		// engage copy.
+		studentEngage, err := s.c.FindStudent(ctx, PIDCount)
+		if err != nil && csb.ErrorCode(err) != csb.ENOTFOUND {
+			return err
+		}
+
+		if err := s.refreshStudent(PIDCount, studentEngage, refresh.Purge); err != nil {
+			return err
+		}
+
+		PIDCount++
+
+		// dont spam engage.
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("refresh students: %w", ctx.Err())
+		case <-time.After(engage.RequestTimeout):
+		}
+	}
+
+	return nil
+}
+
+// refreshStudent refreshes the student with pid = pid from the engage copy, nil if the
+// student isnt in engage.
+func (s *StudentService) refreshStudent(pid int, studentEngage *csb.Student, purge bool) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	// local copy.
+	studentLocal, err := s.db.findStudentByPID(pid)
+	if err != nil && csb.ErrorCode(err) != csb.ENOTFOUND {
+		return err
+	}
+
+	switch {
+	case studentEngage == nil:
+		// no data from engage.
+	case studentLocal == nil:
+		// engage ahead of local copy, skip the students who dont attend the school if this
+		// request is actively purgeing.
+		if !studentEngage.AttendsSchool && purge {
+			break
+		}
+
+		return s.db.createStudent(studentEngage)
+	case !studentEngage.AttendsSchool && purge:
+		return s.db.deleteStudent(pid)
+	default:
+		// data from both engage and local copy, update local copy.
+		return s.db.updateStudent(studentLocal, studentEngage)
+	}
+
+	return nil
+}
+
+// findStudents returns copies of the students matching the filter, the sort, limit, offset
+// and cursor of the filter are ignored.
+func (db *DB) findStudents(filter csb.StudentFilter) []*csb.Student {
+	students := make([]*csb.Student, 0)
+	for _, student := range db.students {
+		if v := filter.PID; v != nil && student.PID != *v {
+			continue
+		}
+		if v := filter.Name; v != nil && !strings.Contains(strings.ToLower(student.Name), strings.ToLower(*v)) {
+			continue
+		}
+		if v := filter.CurrentYear; v != nil && (!student.AttendsSchool || student.CurrentYear != *v) {
+			continue
+		}
+		if v := filter.AttendsSchool; v != nil && student.AttendsSchool != *v {
+			continue
+		}
+
+		// students must take all the subjects.
+		takes := true
+		for _, subject := range filter.Subjects {
+			takes = takes && db.takesSubject(student.PID, subject)
+		}
+		if !takes {
+			continue
+		}
+
+		students = append(students, copyStudent(student))
+	}
+
+	return students
+}
+
+// attachStudentAssociations attaches the included associations of the student.
+func (db *DB) attachStudentAssociations(student *csb.Student, include csb.Include) {
+	if include.Has("subjects") {
+		student.Subjects = db.findSubjectsByPID(student.PID)
+		student.SubjectHistory = db.findSubjectHistoryByPID(student.PID)
+	}
+	if include.Has("marks") {
+		student.Marks = db.findMarks(csb.MarksFilter{PID: &student.PID})
+	}
+}
@@ -0,0 +1,122 @@
+package inmem
+
+import (
+	"sort"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+// findSubject returns the subject matching the most specific populated field of subject: id,
+// engage code and then name.
+//
+// returns ENOTFOUND if no subject matches.
+func (db *DB) findSubject(subject csb.Subject) (csb.Subject, error) {
+	for _, s := range db.subjects {
+		if matchSubject(s, subject) {
+			return s, nil
+		}
+	}
+
+	return csb.Subject{}, csb.Errorf(csb.ENOTFOUND, "no subject found")
+}
+
+func (db *DB) createSubject(subject *csb.Subject) error {
+	if err := subject.Validate(); err != nil {
+		return err
+	}
+
+	for _, s := range db.subjects {
+		if s.EngageCode == subject.EngageCode || s.Name == subject.Name {
+			return csb.Errorf(csb.ECONFLICT, "subject engage code or name already taken")
+		}
+	}
+
+	db.subjectID++
+	subject.ID = db.subjectID
+	db.subjects[subject.ID] = *subject
+
+	return nil
+}
+
+// attachSubject returns the subject with the engage code of subject. Subjects with unknown
+// engage codes are added to the catalog, named after their engage code if the subject has no
+// name or another subject already has the name.
+func (db *DB) attachSubject(subject csb.Subject) (csb.Subject, error) {
+	found, err := db.findSubject(csb.Subject{EngageCode: subject.EngageCode})
+	if csb.ErrorCode(err) == csb.ENOTFOUND {
+		found = csb.Subject{EngageCode: subject.EngageCode, Name: subject.Name}
+		if _, err := db.findSubject(csb.Subject{Name: found.Name}); found.Name == "" || err == nil {
+			found.Name = found.EngageCode
+		}
+		err = db.createSubject(&found)
+	}
+
+	return found, err
+}
+
+// findSubjectsByPID returns all the subjects the student took, ordered by name.
+func (db *DB) findSubjectsByPID(pid int) []csb.Subject {
+	seen := make(map[int]struct{})
+	for _, subjects := range db.takes[pid] {
+		for id := range subjects {
+			seen[id] = struct{}{}
+		}
+	}
+
+	return db.sortedSubjects(seen)
+}
+
+// findSubjectHistoryByPID returns the subjects the student took in each academic year, ordered
+// by academic year.
+func (db *DB) findSubjectHistoryByPID(pid int) []csb.SubjectsTaken {
+	history := make([]csb.SubjectsTaken, 0, len(db.takes[pid]))
+	for academicYear, subjects := range db.takes[pid] {
+		if len(subjects) == 0 {
+			continue
+		}
+
+		history = append(history, csb.SubjectsTaken{
+			AcademicYear: academicYear,
+			Subjects:     db.sortedSubjects(subjects),
+		})
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].AcademicYear < history[j].AcademicYear })
+
+	return history
+}
+
+// takesSubject reports wether the student ever took a subject matching subject.
+func (db *DB) takesSubject(pid int, subject csb.Subject) bool {
+	for _, subjects := range db.takes[pid] {
+		for id := range subjects {
+			if matchSubject(db.subjects[id], subject) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (db *DB) sortedSubjects(ids map[int]struct{}) []csb.Subject {
+	out := make([]csb.Subject, 0, len(ids))
+	for id := range ids {
+		out = append(out, db.subjects[id])
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+}
+
+// matchSubject reports wether s matches the most specific populated field of subject: id,
+// engage code and then name.
+func matchSubject(s, subject csb.Subject) bool {
+	switch {
+	case subject.ID != 0:
+		return s.ID == subject.ID
+	case subject.EngageCode != "":
+		return s.EngageCode == subject.EngageCode
+	default:
+		return s.Name == subject.Name
+	}
+}
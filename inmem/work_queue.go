@@ -3,10 +3,15 @@ package inmem
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/trace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 // defaultBufSize represents the default buffer used as a queue to accumulate transactions.
@@ -15,6 +20,25 @@ import (
 // for engage, and we dont want to spam engage.
 const defaultBufSize int = 50
 
+// metrics of the work queues, the transactions are timed from the start of their processing by
+// type and final state, either done, failed or cancelled.
+var (
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "csb_inmem_work_queue_depth",
+		Help: "Number of transactions waiting in the in memory work queues.",
+	})
+
+	activeWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "csb_inmem_work_queue_active_workers",
+		Help: "Number of in memory work queue workers processing a transaction.",
+	})
+
+	transactionSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "csb_inmem_work_queue_transaction_seconds",
+		Help: "In memory work queue transaction duration in seconds by type and final state.",
+	}, []string{"type", "state"})
+)
+
 // WorkQueue represents an in memory implementation of a work queue.
 //
 // Note that this work queue implementation runs transactions synchronously using only
@@ -55,6 +79,7 @@ func (w *WorkQueue) listen() {
 		case <-w.done:
 			return
 		case val := <-w.queue:
+			queueDepth.Dec()
 			select {
 			case <-val.Ctx.Done():
 				continue
@@ -64,10 +89,18 @@ func (w *WorkQueue) listen() {
 			state := w.states[val.Id]
 			state.newSatus <- csb.Status{State: csb.Processing}
 
+			activeWorkers.Inc()
+			start := time.Now()
+			_, span := trace.Start(val.Ctx, "work_queue.transaction")
+			span.SetAttribute("id", strconv.FormatInt(val.Id, 10))
+			span.SetAttribute("type", val.Type())
 			status := csb.Status{State: csb.Done}
 			if err := w.handler(val); err != nil {
 				status.Error = err
 			}
+			span.Finish(status.Error)
+			transactionSeconds.WithLabelValues(val.Type(), finalState(val, status.Error)).Observe(time.Since(start).Seconds())
+			activeWorkers.Dec()
 
 			state.newSatus <- status
 		}
@@ -98,10 +131,12 @@ func (w *WorkQueue) Publish(transaction *csb.Transaction) error {
 	w.states[transaction.Id] = s
 	go s.bind(transaction) // bind the state to the transaction.
 
+	queueDepth.Inc()
 	select {
 	case w.queue <- transaction:
 		return nil
 	default:
+		queueDepth.Dec()
 		return fmt.Errorf("publish: transaction queue is full")
 	}
 }
@@ -148,6 +183,18 @@ func (w *WorkQueue) Close() error {
 	return nil
 }
 
+// finalState returns the final state of the processed transaction as a metric label.
+func finalState(transaction *csb.Transaction, err error) string {
+	switch {
+	case err == nil:
+		return "done"
+	case transaction.Ctx.Err() != nil:
+		return "cancelled"
+	default:
+		return "failed"
+	}
+}
+
 // Subscription represents a subscription to a transaction.
 type Subscription struct {
 	Id    int64
@@ -12,21 +12,33 @@ type Mark struct {
 
 	// Links to student.
 	StudentID int      `json:"student_id"`
-	Student   *Student `json:"student"`
+	Student   *Student `json:"student,omitempty"`
 
 	// Subject at which the mark was recieved.
 	SubjectID int     `json:"subject_id"`
 	Subject   Subject `json:"subject"`
-	// Teacher is the name of the teacher teaching the subject when the mark was recieved.
+	// Teacher is the name of the teacher teaching the subject when the mark was recieved,
+	// as spelled in the render.
 	Teacher string `json:"teacher"`
+	// TeacherID links to the teacher the spelling of the name is an alias of.
+	TeacherID int `json:"teacher_id"`
 	// Percentage represents the grade recieved out of 100.
 	Percentage int `json:"percentage"`
+	// Grade is the grade derived from the percentage, only populated if a grade scale was
+	// requested.
+	Grade string `json:"grade,omitempty"`
 	// Exam period on which the mark was recieved.
 	Period Period `json:"period"`
+	// ImportBatchID links to the import batch of the mark, nil if the mark came from engage.
+	ImportBatchID *int `json:"import_batch_id,omitempty"`
 	// Timestamp.
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// MarkAssociations are the associations which can be included with a mark. The subject of a
+// mark is read with the mark so including it changes nothing.
+var MarkAssociations = []string{"student", "student.subjects", "subject"}
+
 func (m *Mark) Validate() error {
 	if m.StudentID == 0 {
 		return Errorf(EINVALID, "validate: mark missing student id field")
@@ -51,10 +63,10 @@ func (m *Mark) Validate() error {
 
 // MarkService represents a mark service.
 type MarkService interface {
-	// FindMarkByID returns a mark with the id = id.
+	// FindMarkByID returns a mark with the id = id along side the included associations.
 	//
-	// return ENOTFOUND if the mark doesnt exist.
-	FindMarkByID(ctx context.Context, id int) (*Mark, error)
+	// return ENOTFOUND if the mark doesnt exist and EINVALID if an association isnt valid.
+	FindMarkByID(ctx context.Context, id int, include Include) (*Mark, error)
 
 	// FindMarksByPID returns the marks of the student with pid = pid.
 	//
@@ -73,8 +85,10 @@ type MarkService interface {
 	// If the to period is before the from period, EINVALID is returned.
 	FindMarksByPeriodRange(ctx context.Context, from, to Period, filter MarksFilter) ([]*Mark, error)
 
-	// FindMarks finds the marks with the appropiate filter.
-	FindMarks(ctx context.Context, filter MarksFilter) ([]*Mark, error)
+	// FindMarks finds a page of the marks with the appropiate filter.
+	//
+	// returns EINVALID if the sort field, cursor or an association is invalid.
+	FindMarks(ctx context.Context, filter MarksFilter) ([]*Mark, Page, error)
 
 	// DeleteMark permanently deletes the mark with id = id.
 	//
@@ -94,12 +108,20 @@ type MarksFilter struct {
 	ID *int `json:"id"`
 	// PID filters on the student id.
 	PID *int `json:"pid"`
-	// Teacher filters on the name of the teacher.
+	// Teacher filters on the name of the teacher, matching any alias of the teacher.
 	Teacher *string `json:"teacher"`
+	// TeacherID filters on the teacher id.
+	TeacherID *int `json:"teacher_id"`
 	// MinPercentage sets a minimum percentage for the results.
 	MinPercentage *int `json:"min_percentage"`
 	// MaxPercentage sets a maximum percentage for the results.
 	MaxPercentage *int `json:"max_percentage"`
+	// GradeScale is the name of the grade scale family used to derive the grade of each mark.
+	GradeScale *string `json:"grade_scale"`
+	// Grade filters on the derived grade of the marks, in place of a percentage range.
+	//
+	// Filtering on the grade requires a grade scale.
+	Grade *string `json:"grade"`
 	// Periods filters on the POPULATED period fields on the period fields.
 	//
 	// If only the academic year is populated then the filter will only be applied on the academic year.
@@ -107,4 +129,17 @@ type MarksFilter struct {
 	// Subjects filters on the marks subjects and only lets through the marks with the specified
 	// subjects.
 	Subjects []Subject `json:"subjects"`
+
+	// Include lists the associations loaded with each mark, none if empty.
+	Include Include `json:"include"`
+
+	// Sort orders the page by id, percentage or period, prefixed with - for descending order.
+	// Defaults to id.
+	Sort string `json:"sort"`
+	// Limit caps the size of the page.
+	Limit int `json:"limit"`
+	// Offset skips the first marks, Cursor continues from the next cursor of a previous page
+	// in place of an offset.
+	Offset int    `json:"offset"`
+	Cursor string `json:"cursor"`
 }
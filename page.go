@@ -0,0 +1,124 @@
+package csb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// DefaultPageSize is the size of the pages without a limit and MaxPageSize is the largest
+// page a limit can request.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// MarkSortKeys are the sort keys of the marks by each sort field, the id is always the last
+// value so the order is total.
+var MarkSortKeys = map[string]func(*Mark) []interface{}{
+	"id":         func(m *Mark) []interface{} { return []interface{}{m.ID} },
+	"percentage": func(m *Mark) []interface{} { return []interface{}{m.Percentage, m.ID} },
+	"period": func(m *Mark) []interface{} {
+		return []interface{}{m.Period.AcademicYear, *m.Period.Term, *m.Period.Importance, m.ID}
+	},
+}
+
+// StudentSortKeys are the sort keys of the students by each sort field, the pupil id is always
+// the last value so the order is total.
+var StudentSortKeys = map[string]func(*Student) []interface{}{
+	"pid":          func(s *Student) []interface{} { return []interface{}{s.PID} },
+	"name":         func(s *Student) []interface{} { return []interface{}{s.Name, s.PID} },
+	"current_year": func(s *Student) []interface{} { return []interface{}{s.CurrentYear, s.PID} },
+}
+
+// Page represents the position of a page of results in all the results matching a filter.
+type Page struct {
+	// Total is the number of results matching the filter over all the pages.
+	Total int `json:"total"`
+	// NextCursor continues after the last result of the page, empty on the last page.
+	NextCursor string `json:"next_cursor"`
+}
+
+// PageRequest represents the sort, limit, offset and cursor of a filter resolved against the
+// sort fields of the results.
+type PageRequest struct {
+	// Field is the sort field, the results are in descending order if Desc is set.
+	Field string
+	Desc  bool
+
+	Limit  int
+	Offset int
+	// After is the sort key of the last result of the previous page, nil if the page doesnt
+	// continue from a cursor.
+	After []interface{}
+}
+
+// pageCursor is the decoded form of a cursor.
+type pageCursor struct {
+	Sort string        `json:"s"`
+	Key  []interface{} `json:"k"`
+}
+
+// NewPageRequest resolves the sort, limit, offset and cursor of a filter against the sort
+// fields of the results, the fallback sort is used if sort is empty. Pages without a limit
+// have DefaultPageSize results and limits are capped to MaxPageSize.
+//
+// returns EINVALID if the sort field is unknown, the cursor doesnt belong to the sort or an
+// offset is combined with a cursor.
+func NewPageRequest[K any](sorts map[string]K, fallback, sort string, limit, offset int, cursor string) (*PageRequest, error) {
+	if sort == "" {
+		sort = fallback
+	}
+	p := &PageRequest{
+		Field:  strings.TrimPrefix(sort, "-"),
+		Desc:   strings.HasPrefix(sort, "-"),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if _, ok := sorts[p.Field]; !ok {
+		return nil, Errorf(EINVALID, "invalid sort field: %v", p.Field)
+	}
+
+	switch {
+	case p.Limit < 0 || p.Offset < 0:
+		return nil, Errorf(EINVALID, "limit and offset cannot be negative")
+	case p.Limit == 0:
+		p.Limit = DefaultPageSize
+	case p.Limit > MaxPageSize:
+		p.Limit = MaxPageSize
+	}
+
+	if cursor == "" {
+		return p, nil
+	}
+	if p.Offset != 0 {
+		return nil, Errorf(EINVALID, "cannot combine an offset with a cursor")
+	}
+
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, Errorf(EINVALID, "invalid cursor format")
+	}
+	var c pageCursor
+	if err := json.Unmarshal(buf, &c); err != nil || len(c.Key) == 0 {
+		return nil, Errorf(EINVALID, "invalid cursor format")
+	}
+	if c.Sort != sort {
+		return nil, Errorf(EINVALID, "cursor doesnt belong to sort: %v", sort)
+	}
+	p.After = c.Key
+
+	return p, nil
+}
+
+// Cursor returns the cursor continuing after the result with the sort key.
+func (p *PageRequest) Cursor(key []interface{}) string {
+	sort := p.Field
+	if p.Desc {
+		sort = "-" + sort
+	}
+
+	buf, _ := json.Marshal(pageCursor{Sort: sort, Key: key})
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
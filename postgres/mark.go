@@ -0,0 +1,632 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/engage"
+)
+
+var _ csb.MarkService = (*MarkService)(nil)
+
+// MarkService wraps aroun an engage client and period service, storing the marks in
+// postgres.
+//
+// Grade scales and alerts arent stored in postgres, filtering on grades returns
+// ENOTIMPLEMENTED and new marks arent evaluated against alert rules.
+type MarkService struct {
+	// db for persistance.
+	db *DB
+	// client for updates.
+	c *engage.Client
+	// periodService is used to handle periods.
+	periodService csb.PeriodService
+	// fallback indicates wether failed searches should fallback on the engage client.
+	fallback bool
+}
+
+// NewMarkService creates a new a new mark service with the provided database, engage client and period service.
+func NewMarkService(db *DB, fallback bool, client *engage.Client, periodService csb.PeriodService) *MarkService {
+	return &MarkService{
+		db:            db,
+		c:             client,
+		periodService: periodService,
+		fallback:      fallback,
+	}
+}
+
+// FindMarkByID returns a marked based on the passed id with the included associations.
+//
+// returns ENOTFOUND if the mark isnt found.
+func (s *MarkService) FindMarkByID(ctx context.Context, id int, include csb.Include) (*csb.Mark, error) {
+	if err := include.Validate(csb.MarkAssociations...); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	mark, err := findMarkByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachMarkAssociations(ctx, tx, mark, include); err != nil {
+		return nil, err
+	}
+
+	return mark, nil
+}
+
+// FindMarksByPID returns a range of marks based on the pupil id.
+//
+// find marks only fetches local marks. To get all marks, use refresh handler.
+func (s *MarkService) FindMarksByPID(ctx context.Context, pid int) ([]*csb.Mark, error) {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// find marks by PID only works for local data since we are potentially dealing with allot
+	// of marks and we dont want to spam engage. For loading more data use refresh marks.
+	marks, err := findMarksByPID(ctx, tx, pid)
+	if err != nil {
+		return nil, err
+	} else if err := attachMarksSubjectsWithStudent(ctx, tx, pid, marks); err != nil {
+		return nil, err
+	}
+
+	return marks, nil
+}
+
+// FindMarksByPeriod returns a range of marks for the specified period.
+//
+// If the period is full, the request will use the fallback parameter and fallback on the engage
+// client optinally.
+//
+// If the period isnt full, the request will simply provide the local data.
+func (s *MarkService) FindMarksByPeriod(ctx context.Context, pid int, period csb.Period) (marks []*csb.Mark, err error) {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	full, err := period.Full()
+	if err != nil {
+		return nil, err
+	}
+
+	if full {
+		marks, err = s.findMarksByFullPeriodFallback(ctx, tx, pid, period)
+		// if fallback is true, we already have fully populated marks, return early.
+		if s.fallback == true {
+			return marks, err
+		}
+	} else { // if the period isnt full use local data since we are potentially dealing with allot of data.
+		periods, err := s.periodService.BuildPeriods(ctx, pid, period.AcademicYear, *period.Term)
+		if err != nil {
+			return nil, err
+		}
+
+		marks, err = findMarks(ctx, tx, csb.MarksFilter{PID: &pid, Periods: periods})
+	}
+
+	if err != nil {
+		return nil, err
+	} else if err := attachMarksSubjectsWithStudent(ctx, tx, pid, marks); err != nil {
+		return nil, err
+	}
+
+	return marks, tx.Commit()
+}
+
+// FindMarksByPeriodRange returns a range of marks in the specified period range.
+//
+// All the data will be fetched from local storage.
+func (s *MarkService) FindMarksByPeriodRange(ctx context.Context, from, to csb.Period, filter csb.MarksFilter) (_ []*csb.Mark, err error) {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if filter.PID == nil {
+		return nil, csb.Errorf(csb.EINVALID, "cannot generate marks over period range without a student id")
+	}
+	// if valid id provided save requests to engage for building period range.
+	if filter.ID != nil {
+		mark, err := findMarkByID(ctx, tx, *filter.ID)
+		if err != nil {
+			return nil, err
+		} else if err := attachMarkAssociations(ctx, tx, mark, csb.Include{"student"}); err != nil {
+			return nil, err
+		}
+
+		return []*csb.Mark{mark}, nil
+	}
+
+	filter.Periods, err = s.periodService.PeriodRange(ctx, *filter.PID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	marks, err := findMarks(ctx, tx, filter)
+	if err != nil {
+		return nil, err
+	} else if err := attachMarksSubjectsWithStudent(ctx, tx, *filter.PID, marks); err != nil {
+		return nil, err
+	}
+	return marks, nil
+}
+
+// FindMarks returns a page of marks based on filter with the included associations. Pages
+// without a limit have csb.DefaultPageSize marks and limits are capped to csb.MaxPageSize.
+func (s *MarkService) FindMarks(ctx context.Context, filter csb.MarksFilter) ([]*csb.Mark, csb.Page, error) {
+	if err := filter.Include.Validate(csb.MarkAssociations...); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+	defer tx.Rollback()
+
+	marks, page, err := findMarksPage(ctx, tx, filter)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	for _, mark := range marks {
+		if err := attachMarkAssociations(ctx, tx, mark, filter.Include); err != nil {
+			return nil, csb.Page{}, err
+		}
+	}
+
+	return marks, page, nil
+}
+
+// DeleteMark permanently deletes a mark with the specified id.
+//
+// returns ENOTFOUND if the mark isnt found.
+func (s *MarkService) DeleteMark(ctx context.Context, id int) error {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteMark(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefreshMarks refreshes marks for the student with pid = pid from the period range.
+//
+// It only checks for new marks since it is very uncommon that a mark gets updated or
+// deleted.
+func (s *MarkService) RefreshMarks(ctx context.Context, pid int, from, to csb.Period) error {
+	// asign student manualy since we are again potentially dealing with allot of marks
+	// and dont want to spam engage or the local database.
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := findStudentByPID(ctx, tx, pid); err != nil {
+		return err
+	}
+
+	periods, err := s.periodService.PeriodRange(ctx, pid, from, to)
+	if err != nil {
+		return err
+	}
+
+	for len(periods) > 0 {
+		period := periods[0]
+
+		marksLocal, err := findMarksByFullPeriod(ctx, tx, pid, period)
+		if err != nil {
+			return err
+		}
+
+		marksEngage, err := s.findMarksByFullPeriodEngage(ctx, pid, period)
+		if err != nil {
+			return err
+		} else if err := attachMarksSubjectsWithStudent(ctx, tx, pid, marksEngage); err != nil { // attach subjects to engage marks to be able to make a shallow difference in createDiff. (SubjectID field needed)
+			return err
+		}
+
+		if err := createDiff(ctx, tx, marksLocal, marksEngage); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(engage.RequestTimeout):
+		}
+		periods = periods[1:]
+	}
+
+	return tx.Commit()
+}
+
+func findMarkByID(ctx context.Context, tx *sql.Tx, id int) (*csb.Mark, error) {
+	m, err := findMarks(ctx, tx, csb.MarksFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(m) == 0 {
+		return nil, csb.Errorf(csb.ENOTFOUND, "mark not found")
+	}
+
+	return m[0], nil
+}
+
+func findMarksByPID(ctx context.Context, tx *sql.Tx, pid int) ([]*csb.Mark, error) {
+	m, err := findMarks(ctx, tx, csb.MarksFilter{PID: &pid})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (s *MarkService) findMarksByFullPeriodEngage(ctx context.Context, pid int, period csb.Period) ([]*csb.Mark, error) {
+	engageTerm, err := s.periodService.PeriodToEngageTerm(ctx, pid, period)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.c.FindMarks(ctx, pid, period, engageTerm)
+}
+
+func findMarksByFullPeriod(ctx context.Context, tx *sql.Tx, pid int, period csb.Period) ([]*csb.Mark, error) {
+	m, err := findMarks(ctx, tx, csb.MarksFilter{PID: &pid, Periods: []csb.Period{period}})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (s *MarkService) findMarksByFullPeriodFallback(ctx context.Context, tx *sql.Tx, pid int, period csb.Period) (marks []*csb.Mark, err error) {
+	marks, err = findMarksByFullPeriod(ctx, tx, pid, period)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.fallback {
+		engageMarks, err := s.findMarksByFullPeriodEngage(ctx, pid, period)
+		if err != nil {
+			return nil, err
+		} else if err := attachMarksSubjectsWithStudent(ctx, tx, pid, engageMarks); err != nil {
+			return nil, err
+		}
+
+		if err := createDiff(ctx, tx, marks, engageMarks); err != nil {
+			return nil, err
+		}
+		marks = engageMarks
+	}
+
+	return marks, nil
+}
+
+func findMarks(ctx context.Context, tx *sql.Tx, filter csb.MarksFilter) ([]*csb.Mark, error) {
+	marks := make([]*csb.Mark, 0)
+	err := iterMarks(ctx, tx, filter, func(mark *csb.Mark) error {
+		marks = append(marks, mark)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return marks, nil
+}
+
+// findMarksPage returns the page of the marks matching the filter requested by the sort,
+// limit, offset and cursor of the filter.
+func findMarksPage(ctx context.Context, tx *sql.Tx, filter csb.MarksFilter) ([]*csb.Mark, csb.Page, error) {
+	p, err := newPage(markSorts, "id", filter.Sort, filter.Limit, filter.Offset, filter.Cursor)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	args := []interface{}{}
+	where, err := marksWhere(&args, filter)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	var page csb.Page
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM marks WHERE `+where, args...).Scan(&page.Total); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	marks := make([]*csb.Mark, 0, p.Limit+1)
+	if err := queryMarks(ctx, tx, filter, p, func(mark *csb.Mark) error {
+		marks = append(marks, mark)
+		return nil
+	}); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	// the extra mark only tells that there is a next page.
+	if len(marks) > p.Limit {
+		marks = marks[:p.Limit]
+
+		page.NextCursor = p.Cursor(csb.MarkSortKeys[p.Field](marks[len(marks)-1]))
+	}
+
+	return marks, page, nil
+}
+
+// iterMarks calls fn for each mark matching the filter in id order, straight from the
+// cursor. The subject of each mark is populated. Iteration stops on the first error returned
+// by fn.
+//
+// The sort, limit, offset and cursor of the filter are ignored, all the marks are iterated.
+func iterMarks(ctx context.Context, tx *sql.Tx, filter csb.MarksFilter, fn func(*csb.Mark) error) error {
+	return queryMarks(ctx, tx, filter, nil, fn)
+}
+
+// queryMarks calls fn for each mark matching the filter in the page, all the marks in id
+// order if p is nil.
+func queryMarks(ctx context.Context, tx *sql.Tx, filter csb.MarksFilter, p *page, fn func(*csb.Mark) error) error {
+	args := []interface{}{}
+	where, err := marksWhere(&args, filter)
+	if err != nil {
+		return err
+	}
+
+	orderBy, limit := "marks.id ASC", ""
+	if p != nil {
+		where += " AND " + p.where(&args)
+		orderBy = p.orderBy()
+
+		// query one more mark to tell if there is a next page.
+		limit = "LIMIT " + bind(&args, p.Limit+1) + " OFFSET " + bind(&args, p.Offset)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			marks.id,
+			marks.student_id,
+			marks.subject_id,
+			subjects.engage_code,
+			subjects.name,
+			marks.teacher,
+			marks.teacher_id,
+			marks.percentage,
+			marks.academic_year,
+			marks.term,
+			marks.importance,
+			marks.import_batch_id,
+			marks.created_at
+		FROM marks
+		JOIN subjects ON subjects.id = marks.subject_id
+		WHERE `+where+`
+		ORDER BY `+orderBy+`
+		`+limit+`
+	`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mark csb.Mark
+		var term int
+		var importance string
+		var teacherID, importBatchID sql.NullInt64
+		if err := rows.Scan(
+			&mark.ID,
+			&mark.StudentID,
+			&mark.SubjectID,
+			&mark.Subject.EngageCode,
+			&mark.Subject.Name,
+			&mark.Teacher,
+			&teacherID,
+			&mark.Percentage,
+			&mark.Period.AcademicYear,
+			&term,
+			&importance,
+			&importBatchID,
+			&mark.CreatedAt,
+		); err != nil {
+			return err
+		}
+		mark.Subject.ID = mark.SubjectID
+		mark.Period.Term, mark.Period.Importance = &term, &importance
+		mark.TeacherID = int(teacherID.Int64)
+		mark.ImportBatchID = nullIntPtr(importBatchID)
+
+		if err := fn(&mark); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// marksWhere builds the where clause for the marks filter, binding its arguments to args.
+//
+// returns ENOTIMPLEMENTED if the filter has a grade scale or a grade.
+func marksWhere(args *[]interface{}, filter csb.MarksFilter) (string, error) {
+	if filter.GradeScale != nil || filter.Grade != nil {
+		return "", csb.Errorf(csb.ENOTIMPLEMENTED, "grade scales arent supported by postgres")
+	}
+
+	where := []string{"1=1"}
+	if v := filter.ID; v != nil {
+		where = append(where, "marks.id = "+bind(args, *v))
+	}
+	if v := filter.PID; v != nil {
+		where = append(where, "marks.student_id = "+bind(args, *v))
+	}
+	if v := filter.Teacher; v != nil {
+		where = append(where, "marks.teacher_id IN (SELECT teacher_id FROM teacher_aliases WHERE LOWER(alias) = LOWER("+bind(args, csb.NormalizeTeacherName(*v))+"))")
+	}
+	if v := filter.TeacherID; v != nil {
+		where = append(where, "marks.teacher_id = "+bind(args, *v))
+	}
+	if v := filter.MinPercentage; v != nil {
+		where = append(where, "marks.percentage >= "+bind(args, *v))
+	}
+	if v := filter.MaxPercentage; v != nil {
+		where = append(where, "marks.percentage <= "+bind(args, *v))
+	}
+
+	// only filter on the populated period fields.
+	if len(filter.Periods) > 0 {
+		periods := make([]string, 0, len(filter.Periods))
+		for _, period := range filter.Periods {
+			cond := "marks.academic_year = " + bind(args, period.AcademicYear)
+			if period.Term != nil {
+				cond += " AND marks.term = " + bind(args, *period.Term)
+			}
+			if period.Importance != nil {
+				cond += " AND marks.importance = " + bind(args, *period.Importance)
+			}
+			periods = append(periods, "("+cond+")")
+		}
+		where = append(where, "("+strings.Join(periods, " OR ")+")")
+	}
+
+	if len(filter.Subjects) > 0 {
+		subjects := make([]string, 0, len(filter.Subjects))
+		for _, subject := range filter.Subjects {
+			subjects = append(subjects, "("+subjectCondition(args, subject)+")")
+		}
+		where = append(where, "marks.subject_id IN (SELECT id FROM subjects WHERE "+strings.Join(subjects, " OR ")+")")
+	}
+
+	return strings.Join(where, " AND "), nil
+}
+
+func deleteMark(ctx context.Context, tx *sql.Tx, id int) error {
+	if _, err := findMarkByID(ctx, tx, id); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM marks WHERE id = $1`, id)
+	return err
+}
+
+func createMark(ctx context.Context, tx *sql.Tx, mark *csb.Mark) error {
+	if err := mark.Validate(); err != nil {
+		return err
+	}
+
+	teacherID, err := resolveTeacher(ctx, tx, mark.Teacher)
+	if err != nil {
+		return err
+	}
+	mark.TeacherID = teacherID
+	mark.CreatedAt = time.Now()
+
+	return tx.QueryRowContext(ctx, `
+		INSERT INTO marks (
+			student_id,
+			subject_id,
+			teacher,
+			teacher_id,
+			percentage,
+			academic_year,
+			term,
+			importance,
+			import_batch_id,
+			created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`,
+		mark.StudentID,
+		mark.SubjectID,
+		mark.Teacher,
+		mark.TeacherID,
+		mark.Percentage,
+		mark.Period.AcademicYear,
+		mark.Period.Term,
+		mark.Period.Importance,
+		mark.ImportBatchID,
+		mark.CreatedAt,
+	).Scan(&mark.ID)
+}
+
+func createDiff(ctx context.Context, tx *sql.Tx, local, engage []*csb.Mark) error {
+	// marks in the same period can only have different subjects, do a shallow difference
+	// check on only the subjects to save cpu usage.
+	//
+	// also just add new marks, it isnt often that marks get deleted or updated.
+	diff := make(map[int]struct{}, len(local))
+	for _, markLocal := range local {
+		diff[markLocal.SubjectID] = struct{}{}
+	}
+
+	for _, markEngage := range engage {
+		if _, ok := diff[markEngage.SubjectID]; !ok {
+			if err := createMark(ctx, tx, markEngage); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// attachMarkAssociations attaches the included associations of the mark. The subject is
+// already read with the mark.
+func attachMarkAssociations(ctx context.Context, tx *sql.Tx, mark *csb.Mark, include csb.Include) (err error) {
+	if !include.Has("student") {
+		return nil
+	}
+
+	if mark.Student, err = findStudentByPID(ctx, tx, mark.StudentID); err != nil {
+		return err
+	}
+	return attachStudentAssociations(ctx, tx, mark.Student, include.Nested("student"))
+}
+
+func attachMarksSubjectsWithStudent(ctx context.Context, tx *sql.Tx, pid int, marks []*csb.Mark) (err error) {
+	student, err := findStudentByPID(ctx, tx, pid)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range marks {
+		m.Student = student
+		if err := attachMarkSubject(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func attachMarkSubject(ctx context.Context, tx *sql.Tx, mark *csb.Mark) (err error) {
+	switch {
+	// have name, want engage code + id.
+	case mark.Subject.Name != "":
+		mark.Subject, err = findSubjectByName(ctx, tx, mark.Subject.Name)
+		mark.SubjectID = mark.Subject.ID
+
+	// have id, want engage code + name.
+	case mark.SubjectID != 0:
+		mark.Subject, err = findSubjectByID(ctx, tx, mark.SubjectID)
+	}
+
+	return err
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"strings"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+// markSorts are the columns ordering the marks by each sort field, in the order of the values
+// of csb.MarkSortKeys.
+var markSorts = map[string][]string{
+	"id":         {"marks.id"},
+	"percentage": {"marks.percentage", "marks.id"},
+	"period":     {"marks.academic_year", "marks.term", "marks.importance", "marks.id"},
+}
+
+// studentSorts are the columns ordering the students by each sort field, in the order of the
+// values of csb.StudentSortKeys.
+var studentSorts = map[string][]string{
+	"pid":          {"pid"},
+	"name":         {"name", "pid"},
+	"current_year": {"COALESCE(current_year, 0)", "pid"},
+}
+
+// page represents a page request with its sort resolved to columns.
+type page struct {
+	*csb.PageRequest
+	columns []string
+}
+
+// newPage resolves the sort, limit, offset and cursor of a filter against the sort columns.
+//
+// returns EINVALID if the sort field is unknown, the cursor doesnt belong to the sort or an
+// offset is combined with a cursor.
+func newPage(sorts map[string][]string, fallback, sort string, limit, offset int, cursor string) (*page, error) {
+	req, err := csb.NewPageRequest(sorts, fallback, sort, limit, offset, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &page{PageRequest: req, columns: sorts[req.Field]}
+	if p.After != nil && len(p.After) != len(p.columns) {
+		return nil, csb.Errorf(csb.EINVALID, "invalid cursor format")
+	}
+	return p, nil
+}
+
+// where binds the cursor to args and returns the condition skipping the results up to the
+// cursor, "1=1" if the page doesnt continue from a cursor.
+func (p *page) where(args *[]interface{}) string {
+	if p.After == nil {
+		return "1=1"
+	}
+
+	op := ">"
+	if p.Desc {
+		op = "<"
+	}
+	marks := make([]string, 0, len(p.After))
+	for _, v := range p.After {
+		marks = append(marks, bind(args, v))
+	}
+
+	return "(" + strings.Join(p.columns, ", ") + ") " + op + " (" + strings.Join(marks, ", ") + ")"
+}
+
+// orderBy returns the columns of the sort with their direction.
+func (p *page) orderBy() string {
+	dir := " ASC"
+	if p.Desc {
+		dir = " DESC"
+	}
+
+	return strings.Join(p.columns, dir+", ") + dir
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Lambels/CSB-Open-API/csbtest"
+)
+
+// TestConformance runs the suite against the database of CSB_POSTGRES_DSN, its tables are
+// emptied before every run of the services. see DB.Open for starting a local database.
+func TestConformance(t *testing.T) {
+	csbtest.TestStudentService(t, newServices)
+	csbtest.TestMarkService(t, newServices)
+}
+
+// newServices opens the test database and empties its tables, skips the test if
+// CSB_POSTGRES_DSN isnt set.
+func newServices(t *testing.T, e *csbtest.Engage) csbtest.Services {
+	dsn := os.Getenv("CSB_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("CSB_POSTGRES_DSN not set")
+	}
+
+	db := NewDB(dsn, "file://../db/postgres")
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.db.Exec(`
+		TRUNCATE students, subjects, student_takes, teachers, teacher_aliases, marks, transactions
+		RESTART IDENTITY CASCADE
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	return csbtest.Services{
+		Students: NewStudentService(db, e.Client(), false),
+		Marks:    NewMarkService(db, false, e.Client(), e.PeriodService()),
+	}
+}
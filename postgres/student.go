@@ -0,0 +1,499 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/engage"
+)
+
+var _ csb.StudentService = (*StudentService)(nil)
+
+// StudentService wraps around an engage client, storing the students in postgres.
+type StudentService struct {
+	// db for persistance.
+	db *DB
+	// client for updates.
+	c *engage.Client
+	// fallback indicates wether fetch to new students should be saved.
+	fallback bool
+}
+
+// NewStudentService creates a new student service with the provided database and engage client.
+func NewStudentService(db *DB, client *engage.Client, fallback bool) *StudentService {
+	return &StudentService{
+		db:       db,
+		c:        client,
+		fallback: fallback,
+	}
+}
+
+// FindStudentByPID returns a student based on the passed pid.
+//
+// If the student isnt originally found in the database, the service will try to search
+// engage using the engage client, if the user isnt found, ultimately ENOTFOUND is returned.
+//
+// If the user is found in engage and not in the db and saveNew is true the
+// user is saved before returned.
+//
+// Only the included associations are loaded, the subjects of students from engage are
+// dropped if they arent included.
+func (s *StudentService) FindStudentByPID(ctx context.Context, pid int, include csb.Include) (*csb.Student, error) {
+	if err := include.Validate(csb.StudentAssociations...); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	student, err := findStudentByPID(ctx, tx, pid)
+	switch csb.ErrorCode(err) {
+	case "":
+		if err := attachStudentAssociations(ctx, tx, student, include); err != nil {
+			return nil, err
+		}
+
+		return student, nil
+
+	case csb.ENOTFOUND:
+		student, err := s.c.FindStudent(ctx, pid)
+		if err != nil {
+			return nil, err
+		}
+		if s.fallback {
+			if err := createStudent(ctx, tx, student); err == nil {
+				if err := tx.Commit(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// engage students come with their subjects, which are needed to save them.
+		if !include.Has("subjects") {
+			student.Subjects, student.SubjectHistory = nil, nil
+		}
+		return student, nil
+
+	default:
+		return nil, err
+	}
+}
+
+// FindStudents returns a page of students based on the filter with the included
+// associations. Pages without a limit have csb.DefaultPageSize students and limits are capped to
+// csb.MaxPageSize.
+func (s *StudentService) FindStudents(ctx context.Context, filter csb.StudentFilter) ([]*csb.Student, csb.Page, error) {
+	if err := filter.Include.Validate(csb.StudentAssociations...); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+	defer tx.Rollback()
+
+	students, page, err := findStudentsPage(ctx, tx, filter)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	for _, student := range students {
+		if err := attachStudentAssociations(ctx, tx, student, filter.Include); err != nil {
+			return nil, csb.Page{}, err
+		}
+	}
+
+	return students, page, nil
+}
+
+// DeleteStudent permanently deletes a student specified by pid.
+// returns ENOTFOUND if student isnt found.
+func (s *StudentService) DeleteStudent(ctx context.Context, pid int) error {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteStudent(ctx, tx, pid); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefreshStudents refreshes students incrementally starting from refresh.StartPID, refresh.N
+// times.
+//
+// If a student is in engage but not in the local copy of students then the student is added
+// to the local copy. If refresh.Purge is set to true and the student from engage is not
+// attending school then the copy from engage to local storage wont be made.
+//
+// If refresh.Purge is set to true and a student in the local database is not attending the school
+// any more in engage, then the user is deleted.
+//
+// If the student is both in engage and local storage, an update will be so that your local
+// storage has the newest data.
+func (s *StudentService) RefreshStudents(ctx context.Context, refresh csb.RefreshStudents) error {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	PIDCount := refresh.StartPID
+	for i := 0; i < refresh.N; i++ {
+		// engage copy.
+		studentEngage, err := s.c.FindStudent(ctx, PIDCount)
+		if err != nil && csb.ErrorCode(err) != csb.ENOTFOUND {
+			return err
+		}
+
+		// local copy.
+		studentLocal, err := findStudentByPID(ctx, tx, PIDCount)
+		if err != nil && csb.ErrorCode(err) != csb.ENOTFOUND {
+			return err
+		}
+
+		switch {
+		case studentEngage == nil && studentLocal == nil:
+			// no data from engage or local db.
+		case studentEngage != nil && studentLocal == nil:
+			// engage ahead of local db.
+			// if engage is ahead of local db with students who dont attend the school
+			// and this request is actively purgeing, skip the creation.
+			if !studentEngage.AttendsSchool && refresh.Purge {
+				break
+			}
+
+			if err := createStudent(ctx, tx, studentEngage); err != nil {
+				return err
+			}
+		case studentEngage != nil && studentLocal != nil:
+			if !studentEngage.AttendsSchool && refresh.Purge {
+				if err := deleteStudent(ctx, tx, PIDCount); err != nil {
+					return err
+				}
+
+				break
+			}
+
+			// data from both engage and local db, update local db.
+			if err := updateStudent(ctx, tx, studentLocal, studentEngage); err != nil {
+				return err
+			}
+		}
+
+		PIDCount++
+
+		// dont spam engage.
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("refresh students: %w", ctx.Err())
+		case <-time.After(engage.RequestTimeout):
+		}
+	}
+
+	return tx.Commit()
+}
+
+func findStudentByPID(ctx context.Context, tx *sql.Tx, pid int) (*csb.Student, error) {
+	s, err := findStudents(ctx, tx, csb.StudentFilter{PID: &pid})
+	if err != nil {
+		return nil, err
+	} else if len(s) == 0 {
+		return nil, csb.Errorf(csb.ENOTFOUND, "student not found")
+	}
+
+	return s[0], nil
+}
+
+func findStudents(ctx context.Context, tx *sql.Tx, filter csb.StudentFilter) ([]*csb.Student, error) {
+	students := make([]*csb.Student, 0)
+	err := iterStudents(ctx, tx, filter, func(student *csb.Student) error {
+		students = append(students, student)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return students, nil
+}
+
+// findStudentsPage returns the page of the students matching the filter requested by the
+// sort, limit, offset and cursor of the filter.
+func findStudentsPage(ctx context.Context, tx *sql.Tx, filter csb.StudentFilter) ([]*csb.Student, csb.Page, error) {
+	p, err := newPage(studentSorts, "pid", filter.Sort, filter.Limit, filter.Offset, filter.Cursor)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	args := []interface{}{}
+	where := studentsWhere(&args, filter)
+
+	var page csb.Page
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM students WHERE `+where, args...).Scan(&page.Total); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	students := make([]*csb.Student, 0, p.Limit+1)
+	if err := queryStudents(ctx, tx, filter, p, func(student *csb.Student) error {
+		students = append(students, student)
+		return nil
+	}); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	// the extra student only tells that there is a next page.
+	if len(students) > p.Limit {
+		students = students[:p.Limit]
+
+		page.NextCursor = p.Cursor(csb.StudentSortKeys[p.Field](students[len(students)-1]))
+	}
+
+	return students, page, nil
+}
+
+// iterStudents calls fn for each student matching the filter in pid order, straight from
+// the cursor. Iteration stops on the first error returned by fn.
+//
+// The sort, limit, offset and cursor of the filter are ignored, all the students are
+// iterated.
+func iterStudents(ctx context.Context, tx *sql.Tx, filter csb.StudentFilter, fn func(*csb.Student) error) error {
+	return queryStudents(ctx, tx, filter, nil, fn)
+}
+
+// queryStudents calls fn for each student matching the filter in the page, all the students
+// in pid order if p is nil.
+func queryStudents(ctx context.Context, tx *sql.Tx, filter csb.StudentFilter, p *page, fn func(*csb.Student) error) error {
+	args := []interface{}{}
+	where := studentsWhere(&args, filter)
+
+	orderBy, limit := "pid ASC", ""
+	if p != nil {
+		where += " AND " + p.where(&args)
+		orderBy = p.orderBy()
+
+		// query one more student to tell if there is a next page.
+		limit = "LIMIT " + bind(&args, p.Limit+1) + " OFFSET " + bind(&args, p.Offset)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			pid,
+			name,
+			current_year,
+			attends_school,
+			created_at,
+			updated_at
+		FROM students
+		WHERE `+where+`
+		ORDER BY `+orderBy+`
+		`+limit+`
+	`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var student csb.Student
+		var currYear sql.NullInt64
+		if err := rows.Scan(
+			&student.PID,
+			&student.Name,
+			&currYear,
+			&student.AttendsSchool,
+			&student.CreatedAt,
+			&student.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		student.CurrentYear = int(currYear.Int64)
+
+		if err := fn(&student); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// studentsWhere builds the where clause for the student filter, binding its arguments to
+// args.
+func studentsWhere(args *[]interface{}, filter csb.StudentFilter) string {
+	where := []string{"1=1"}
+	if v := filter.PID; v != nil {
+		where = append(where, "pid = "+bind(args, *v))
+	}
+	if v := filter.Name; v != nil {
+		where = append(where, "name ILIKE "+bind(args, "%"+*v+"%"))
+	}
+	if v := filter.CurrentYear; v != nil {
+		where = append(where, "current_year = "+bind(args, *v))
+	}
+	if v := filter.AttendsSchool; v != nil {
+		where = append(where, "attends_school = "+bind(args, *v))
+	}
+
+	// students must take all the subjects:
+	//
+	// pid IN (
+	// 	SELECT student_id FROM student_takes WHERE subject_id IN (...)
+	// 	INTERSECT
+	// 	SELECT student_id FROM student_takes WHERE subject_id IN (...)
+	// )
+	if len(filter.Subjects) > 0 {
+		takes := make([]string, 0, len(filter.Subjects))
+		for _, subject := range filter.Subjects {
+			takes = append(takes, "SELECT student_id FROM student_takes WHERE subject_id IN (SELECT id FROM subjects WHERE "+subjectCondition(args, subject)+")")
+		}
+		where = append(where, "pid IN ("+strings.Join(takes, " INTERSECT ")+")")
+	}
+
+	return strings.Join(where, " AND ")
+}
+
+func createStudent(ctx context.Context, tx *sql.Tx, student *csb.Student) error {
+	if err := student.Validate(); err != nil {
+		return err
+	}
+
+	student.CreatedAt = time.Now()
+	student.UpdatedAt = student.CreatedAt
+	currYear := sql.NullInt64{Int64: int64(student.CurrentYear), Valid: student.AttendsSchool}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO students (
+			pid,
+			name,
+			current_year,
+			attends_school,
+			created_at,
+			updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		student.PID,
+		student.Name,
+		currYear,
+		student.AttendsSchool,
+		student.CreatedAt,
+		student.UpdatedAt,
+	)
+	if isUniqueConstraint(err) {
+		return csb.Errorf(csb.ECONFLICT, "student %v already exists", student.PID)
+	} else if err != nil {
+		return err
+	}
+
+	return setStudentSubjects(ctx, tx, student.PID, student.SubjectHistory)
+}
+
+func updateStudent(ctx context.Context, tx *sql.Tx, prev, next *csb.Student) error {
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	// replace the subjects of the academic years reported by engage.
+	if err := setStudentSubjects(ctx, tx, prev.PID, next.SubjectHistory); err != nil {
+		return err
+	}
+	// change in name, current year and current school atendance.
+	currYear := sql.NullInt64{Int64: int64(next.CurrentYear), Valid: next.AttendsSchool}
+	// change updated at.
+	next.UpdatedAt = time.Now()
+
+	_, err := tx.ExecContext(ctx, `
+		UPDATE students SET
+			name = $1,
+			current_year = $2,
+			attends_school = $3,
+			updated_at = $4
+		WHERE pid = $5
+	`,
+		next.Name,
+		currYear,
+		next.AttendsSchool,
+		next.UpdatedAt,
+		prev.PID,
+	)
+	return err
+}
+
+func deleteStudent(ctx context.Context, tx *sql.Tx, pid int) error {
+	if _, err := findStudentByPID(ctx, tx, pid); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM students WHERE pid = $1`, pid)
+	return err
+}
+
+// setStudentSubjects replaces the subjects the student took in each academic year of the
+// history, the academic years missing from the history are left untouched.
+func setStudentSubjects(ctx context.Context, tx *sql.Tx, pid int, history []csb.SubjectsTaken) error {
+	for _, taken := range history {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM student_takes
+			WHERE student_id = $1 AND academic_year = $2
+		`,
+			pid,
+			taken.AcademicYear,
+		); err != nil {
+			return err
+		}
+
+		for _, subject := range taken.Subjects {
+			if err := attachSubjectToStudent(ctx, tx, pid, taken.AcademicYear, subject); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// attachStudentAssociations attaches the included associations of the student.
+func attachStudentAssociations(ctx context.Context, tx *sql.Tx, student *csb.Student, include csb.Include) error {
+	if include.Has("subjects") {
+		if err := attachStudentSubjects(ctx, tx, student); err != nil {
+			return err
+		}
+	}
+	if include.Has("marks") {
+		if err := attachStudentMarks(ctx, tx, student); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attachStudentSubjects attaches all the subjects the student took and the subjects taken
+// in each academic year.
+func attachStudentSubjects(ctx context.Context, tx *sql.Tx, student *csb.Student) (err error) {
+	if student.Subjects, err = findSubjectsByPID(ctx, tx, student.PID); err != nil {
+		return err
+	}
+
+	student.SubjectHistory, err = findSubjectHistoryByPID(ctx, tx, student.PID)
+	return err
+}
+
+func attachStudentMarks(ctx context.Context, tx *sql.Tx, student *csb.Student) (err error) {
+	if student.Marks, err = findMarksByPID(ctx, tx, student.PID); err != nil {
+		return fmt.Errorf("attach student marks: %w", err)
+	}
+	return nil
+}
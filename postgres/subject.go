@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+func findSubjectByName(ctx context.Context, tx *sql.Tx, name string) (csb.Subject, error) {
+	return findSubject(ctx, tx, "name = $1", name)
+}
+
+func findSubjectByID(ctx context.Context, tx *sql.Tx, id int) (csb.Subject, error) {
+	return findSubject(ctx, tx, "id = $1", id)
+}
+
+func findSubjectByEngageCode(ctx context.Context, tx *sql.Tx, code string) (csb.Subject, error) {
+	return findSubject(ctx, tx, "engage_code = $1", code)
+}
+
+// findSubject returns the subject matching the condition on the subjects table.
+//
+// returns ENOTFOUND if no subject matches.
+func findSubject(ctx context.Context, tx *sql.Tx, cond string, arg interface{}) (csb.Subject, error) {
+	row := tx.QueryRowContext(ctx, `
+		SELECT
+			id,
+			engage_code,
+			name
+		FROM subjects
+		WHERE `+cond,
+		arg,
+	)
+
+	var subject csb.Subject
+	err := row.Scan(
+		&subject.ID,
+		&subject.EngageCode,
+		&subject.Name,
+	)
+	if err == sql.ErrNoRows {
+		err = csb.Errorf(csb.ENOTFOUND, "no subject found")
+	}
+
+	return subject, err
+}
+
+func createSubject(ctx context.Context, tx *sql.Tx, subject *csb.Subject) error {
+	if err := subject.Validate(); err != nil {
+		return err
+	}
+
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO subjects (
+			engage_code,
+			name
+		) VALUES ($1, $2)
+		RETURNING id
+	`,
+		subject.EngageCode,
+		subject.Name,
+	).Scan(&subject.ID)
+	if isUniqueConstraint(err) {
+		return csb.Errorf(csb.ECONFLICT, "subject engage code or name already taken")
+	}
+
+	return err
+}
+
+// createEngageSubject adds the subject with the engage code to the catalog. The subject is
+// named after its engage code if it has no name or another subject already has the name.
+func createEngageSubject(ctx context.Context, tx *sql.Tx, code, name string) (csb.Subject, error) {
+	subject := csb.Subject{EngageCode: code, Name: name}
+	if _, err := findSubjectByName(ctx, tx, name); name == "" || err == nil {
+		subject.Name = code
+	} else if csb.ErrorCode(err) != csb.ENOTFOUND {
+		return subject, err
+	}
+
+	return subject, createSubject(ctx, tx, &subject)
+}
+
+// attachSubjectToStudent links the subject to the student in the academic year by its engage
+// code. Subjects with unknown engage codes are added to the catalog.
+func attachSubjectToStudent(ctx context.Context, tx *sql.Tx, pid, academicYear int, subject csb.Subject) error {
+	found, err := findSubjectByEngageCode(ctx, tx, subject.EngageCode)
+	if csb.ErrorCode(err) == csb.ENOTFOUND {
+		found, err = createEngageSubject(ctx, tx, subject.EngageCode, subject.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO student_takes (
+			student_id,
+			subject_id,
+			academic_year
+		) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`,
+		pid,
+		found.ID,
+		academicYear,
+	)
+
+	return err
+}
+
+func findSubjectsByPID(ctx context.Context, tx *sql.Tx, pid int) ([]csb.Subject, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT
+			subjects.id,
+			subjects.engage_code,
+			subjects.name
+		FROM subjects
+		JOIN student_takes ON student_takes.subject_id = subjects.id
+		WHERE student_takes.student_id = $1
+		ORDER BY subjects.name ASC
+	`,
+		pid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := make([]csb.Subject, 0)
+	for rows.Next() {
+		var subject csb.Subject
+		if err := rows.Scan(
+			&subject.ID,
+			&subject.EngageCode,
+			&subject.Name,
+		); err != nil {
+			return nil, err
+		}
+
+		subjects = append(subjects, subject)
+	}
+
+	return subjects, rows.Err()
+}
+
+// findSubjectHistoryByPID returns the subjects the student took in each academic year, ordered
+// by academic year.
+func findSubjectHistoryByPID(ctx context.Context, tx *sql.Tx, pid int) ([]csb.SubjectsTaken, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			student_takes.academic_year,
+			subjects.id,
+			subjects.engage_code,
+			subjects.name
+		FROM subjects
+		JOIN student_takes ON student_takes.subject_id = subjects.id
+		WHERE student_takes.student_id = $1
+		ORDER BY student_takes.academic_year ASC, subjects.name ASC
+	`,
+		pid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]csb.SubjectsTaken, 0)
+	for rows.Next() {
+		var academicYear int
+		var subject csb.Subject
+		if err := rows.Scan(
+			&academicYear,
+			&subject.ID,
+			&subject.EngageCode,
+			&subject.Name,
+		); err != nil {
+			return nil, err
+		}
+
+		if n := len(history); n == 0 || history[n-1].AcademicYear != academicYear {
+			history = append(history, csb.SubjectsTaken{AcademicYear: academicYear})
+		}
+		last := &history[len(history)-1]
+		last.Subjects = append(last.Subjects, subject)
+	}
+
+	return history, rows.Err()
+}
+
+// subjectCondition binds the subject to args and returns a condition on the subjects table
+// matching the subject by the most specific populated field: id, engage code and then name.
+func subjectCondition(args *[]interface{}, subject csb.Subject) string {
+	switch {
+	case subject.ID != 0:
+		return "id = " + bind(args, subject.ID)
+	case subject.EngageCode != "":
+		return "engage_code = " + bind(args, subject.EngageCode)
+	default:
+		return "name = " + bind(args, subject.Name)
+	}
+}
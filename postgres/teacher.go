@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+// resolveTeacher returns the id of the teacher the spelling of the name is an alias of,
+// creating a new teacher if the spelling is unknown.
+func resolveTeacher(ctx context.Context, tx *sql.Tx, name string) (int, error) {
+	name = csb.NormalizeTeacherName(name)
+
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT teacher_id FROM teacher_aliases WHERE LOWER(alias) = LOWER($1)`, name).Scan(&id)
+	if err == nil {
+		return id, nil
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO teachers (
+			name,
+			created_at
+		) VALUES ($1, $2)
+		RETURNING id
+	`,
+		name,
+		time.Now(),
+	).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, createTeacherAlias(ctx, tx, id, name)
+}
+
+func createTeacherAlias(ctx context.Context, tx *sql.Tx, id int, alias string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO teacher_aliases (
+			teacher_id,
+			alias
+		) VALUES ($1, $2)
+	`,
+		id,
+		alias,
+	)
+	if isUniqueConstraint(err) {
+		return csb.Errorf(csb.ECONFLICT, "alias %v belongs to another teacher", alias)
+	}
+
+	return err
+}
@@ -0,0 +1,531 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/trace"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// pollInterval is the interval at which the work queue looks for queued transactions and
+// subscriptions look for new statuses.
+const pollInterval = 500 * time.Millisecond
+
+// leaseDuration is how long a processing transaction stays claimed by an instance without the
+// instance renewing the lease, after which it is claimed again by any instance.
+const leaseDuration = 30 * time.Second
+
+var _ csb.WorkQueue = (*WorkQueue)(nil)
+
+// metrics of the work queues, the transactions are timed from the start of their processing by
+// type and final state, either done, failed or cancelled.
+var (
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "csb_postgres_work_queue_depth",
+		Help: "Number of queued transactions in the postgres work queues, shared by the instances.",
+	})
+
+	activeWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "csb_postgres_work_queue_active_workers",
+		Help: "Number of postgres work queue workers of this instance processing a transaction.",
+	})
+
+	transactionSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "csb_postgres_work_queue_transaction_seconds",
+		Help: "Postgres work queue transaction duration in seconds by type and final state.",
+	}, []string{"type", "state"})
+)
+
+// WorkQueue represents a work queue stored in postgres, shared by every instance of the app.
+//
+// Each instance runs transactions synchronously using only one worker, transactions are
+// claimed by only one instance. The data of transactions published by other instances is
+// decoded into the type registered with the name of its type, an instance only claims the
+// transactions of the types it registered.
+//
+// The claim of a processing transaction is leased and renewed while the handler runs, the
+// transactions of crashed instances are claimed again once their lease expires. A transaction
+// can so be handed to handlers more than once.
+type WorkQueue struct {
+	// db for persistance.
+	db *DB
+
+	// handler handels the message synchronously.
+	handler func(*csb.Transaction) error
+
+	// types are the registered types of the data by the name of the type.
+	typesMu sync.RWMutex
+	types   map[string]reflect.Type
+
+	// transactions published by this instance, which are still queued or processing.
+	transactionsMu sync.Mutex
+	transactions   map[int64]*csb.Transaction
+
+	// depth is the last amount of queued transactions counted by the work queue.
+	depth int
+
+	done chan struct{}
+	once sync.Once // used to close done only once.
+}
+
+// NewWorkQueue creates a new postgres work queue, the data of the transactions published by
+// the api is registered.
+func NewWorkQueue(db *DB, handler func(*csb.Transaction) error) *WorkQueue {
+	w := &WorkQueue{
+		db:           db,
+		handler:      handler,
+		types:        make(map[string]reflect.Type),
+		transactions: make(map[int64]*csb.Transaction),
+		done:         make(chan struct{}),
+	}
+	w.Register(csb.RefreshStudents{}, csb.SyncSubjects{})
+
+	go w.listen()
+
+	return w
+}
+
+// Register registers the types of the values as types of the data of the transactions, the
+// data of the claimed transactions is decoded in a value of the registered type with the same
+// name. Registering a pointer decodes the data in a pointer.
+func (w *WorkQueue) Register(values ...any) {
+	w.typesMu.Lock()
+	defer w.typesMu.Unlock()
+
+	for _, v := range values {
+		w.types[(&csb.Transaction{Data: v}).Type()] = reflect.TypeOf(v)
+	}
+}
+
+// registeredTypes returns the names of the registered types.
+func (w *WorkQueue) registeredTypes() []string {
+	w.typesMu.RLock()
+	defer w.typesMu.RUnlock()
+
+	names := make([]string, 0, len(w.types))
+	for name := range w.types {
+		names = append(names, name)
+	}
+	return names
+}
+
+// decode decodes the data in a value of the registered type with the name.
+func (w *WorkQueue) decode(name string, data []byte) (any, error) {
+	w.typesMu.RLock()
+	t, ok := w.types[name]
+	w.typesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("decode: unregistered transaction type: %v", name)
+	}
+
+	if t.Kind() == reflect.Pointer {
+		v := reflect.New(t.Elem())
+		if err := json.Unmarshal(data, v.Interface()); err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+
+	v := reflect.New(t)
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// listen claims queued transactions and hands them in to the handler.
+func (w *WorkQueue) listen() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	defer w.countQueued(0)
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		var n int
+		if err := w.db.db.QueryRow(`SELECT COUNT(*) FROM transactions WHERE state = $1`, csb.Queued).Scan(&n); err == nil {
+			w.countQueued(n)
+		}
+
+		// drain the queue before waiting for the next tick.
+		for {
+			transaction, err := w.claim()
+			if err != nil || transaction == nil {
+				break
+			}
+
+			stop := w.renewLease(transaction.Id)
+			activeWorkers.Inc()
+			start := time.Now()
+			_, span := trace.Start(transaction.Ctx, "work_queue.transaction")
+			span.SetAttribute("id", strconv.FormatInt(transaction.Id, 10))
+			span.SetAttribute("type", transaction.Type())
+			status := csb.Status{State: csb.Done}
+			if err := w.handler(transaction); err != nil {
+				status.Error = err
+			}
+			span.Finish(status.Error)
+			transactionSeconds.WithLabelValues(transaction.Type(), finalState(transaction, status.Error)).Observe(time.Since(start).Seconds())
+			activeWorkers.Dec()
+			stop()
+
+			w.setStatus(transaction.Id, status)
+			w.forget(transaction.Id)
+		}
+	}
+}
+
+// countQueued updates the depth of the work queues with the amount of queued transactions
+// counted by the work queue.
+func (w *WorkQueue) countQueued(n int) {
+	queueDepth.Add(float64(n - w.depth))
+	w.depth = n
+}
+
+// finalState returns the final state of the processed transaction as a metric label.
+func finalState(transaction *csb.Transaction, err error) string {
+	switch {
+	case err == nil:
+		return "done"
+	case transaction.Ctx.Err() != nil:
+		return "cancelled"
+	default:
+		return "failed"
+	}
+}
+
+// claim marks the oldest queued transaction of a registered type as processing, leased to
+// the instance, and returns it. Processing transactions whose lease expired are claimed again.
+// nil is returned if no transaction is claimable.
+//
+// transactions whose data doesnt decode are done with the error.
+func (w *WorkQueue) claim() (*csb.Transaction, error) {
+	ctx := context.Background()
+
+	tx, err := w.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// other instances skip the locked transaction.
+	now := time.Now()
+	var id int64
+	var name, traceID, actor string
+	var data []byte
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, type, data, trace_id, actor
+		FROM transactions
+		WHERE (state = $1 OR (state = $2 AND lease_until < $3)) AND type = ANY($4)
+		ORDER BY id ASC
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`,
+		csb.Queued,
+		csb.Processing,
+		now,
+		pq.Array(w.registeredTypes()),
+	).Scan(&id, &name, &data, &traceID, &actor)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE transactions SET state = $1, lease_until = $2, updated_at = $3 WHERE id = $4
+	`,
+		csb.Processing,
+		now.Add(leaseDuration),
+		now,
+		id,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	w.transactionsMu.Lock()
+	transaction, ok := w.transactions[id]
+	w.transactionsMu.Unlock()
+	if ok {
+		return transaction, nil
+	}
+
+	v, err := w.decode(name, data)
+	if err != nil {
+		w.setStatus(id, csb.Status{State: csb.Done, Error: err})
+		return nil, err
+	}
+
+	// the transaction continues the trace of the request which published it.
+	ctx = trace.NewContextWithTraceID(ctx, traceID)
+	if actor != "" {
+		ctx = csb.NewContextWithActor(ctx, actor)
+	}
+	return &csb.Transaction{
+		Id:   id,
+		Data: v,
+		Ctx:  ctx,
+	}, nil
+}
+
+// renewLease renews the lease of the processing transaction with id = id until the returned
+// func is called.
+func (w *WorkQueue) renewLease(id int64) func() {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(leaseDuration / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			w.db.db.Exec(`
+				UPDATE transactions SET lease_until = $1 WHERE id = $2 AND state = $3
+			`,
+				time.Now().Add(leaseDuration),
+				id,
+				csb.Processing,
+			)
+		}
+	}()
+
+	return func() { close(stop) }
+}
+
+// Publish stores the transaction as queued, the data of the transaction is stored as json
+// along side the name of its type and the trace id and actor of its context.
+//
+// If the work queue is closed, the call is no-op.
+func (w *WorkQueue) Publish(transaction *csb.Transaction) error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+	}
+
+	data, err := json.Marshal(transaction.Data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := w.db.db.QueryRowContext(transaction.Ctx, `
+		INSERT INTO transactions (
+			state,
+			type,
+			data,
+			trace_id,
+			actor,
+			created_at,
+			updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`,
+		csb.Queued,
+		transaction.Type(),
+		data,
+		trace.TraceID(transaction.Ctx),
+		csb.ActorFromContext(transaction.Ctx),
+		now,
+		now,
+	).Scan(&transaction.Id); err != nil {
+		return err
+	}
+
+	w.transactionsMu.Lock()
+	w.transactions[transaction.Id] = transaction
+	w.transactionsMu.Unlock()
+
+	go w.bind(transaction)
+
+	return nil
+}
+
+// bind cancels the transaction if its context is done before it gets a chance to run.
+func (w *WorkQueue) bind(transaction *csb.Transaction) {
+	select {
+	case <-w.done:
+		return
+	case <-transaction.Ctx.Done():
+	}
+
+	// only queued transactions are cancelled, processing transactions report the error of
+	// the context from the handler.
+	_, err := w.db.db.Exec(`
+		UPDATE transactions SET state = $1, error = $2, updated_at = $3 WHERE id = $4 AND state = $5
+	`,
+		csb.Cancelled,
+		transaction.Ctx.Err().Error(),
+		time.Now(),
+		transaction.Id,
+		csb.Queued,
+	)
+	if err == nil {
+		w.forget(transaction.Id)
+	}
+}
+
+// setStatus stores the status of the transaction with id = id.
+func (w *WorkQueue) setStatus(id int64, status csb.Status) error {
+	var msg sql.NullString
+	if status.Error != nil {
+		msg = sql.NullString{String: status.Error.Error(), Valid: true}
+	}
+
+	_, err := w.db.db.Exec(`
+		UPDATE transactions SET state = $1, error = $2, updated_at = $3 WHERE id = $4
+	`,
+		status.State,
+		msg,
+		time.Now(),
+		id,
+	)
+	return err
+}
+
+// forget drops the transaction published by this instance with id = id.
+func (w *WorkQueue) forget(id int64) {
+	w.transactionsMu.Lock()
+	defer w.transactionsMu.Unlock()
+
+	delete(w.transactions, id)
+}
+
+// Subscribe subscribes to the transaction with id = id, the transaction can be published by
+// any instance.
+//
+// If the work queue is closed the call is no-op.
+//
+// If the transcation doesent exist ENOTFOUND is returned.
+func (w *WorkQueue) Subscribe(ctx context.Context, id int64) (csb.Subscription, error) {
+	select {
+	case <-w.done:
+		return nil, nil
+	default:
+	}
+
+	status, err := w.findStatus(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &Subscription{
+		w:     w,
+		id:    id,
+		c:     make(chan csb.Status, 1),
+		close: make(chan struct{}),
+	}
+	sub.c <- status // c has buffer of 1, no point to handle blocking.
+
+	go sub.poll(status)
+
+	return sub, nil
+}
+
+// findStatus returns the status of the transaction with id = id.
+//
+// returns ENOTFOUND if the transaction doesnt exist.
+func (w *WorkQueue) findStatus(ctx context.Context, id int64) (csb.Status, error) {
+	var status csb.Status
+	var msg sql.NullString
+	err := w.db.db.QueryRowContext(ctx, `
+		SELECT state, error FROM transactions WHERE id = $1
+	`,
+		id,
+	).Scan(&status.State, &msg)
+	if err == sql.ErrNoRows {
+		return csb.Status{}, csb.Errorf(csb.ENOTFOUND, "subscribe: no transaction was found with id: %v", id)
+	} else if err != nil {
+		return csb.Status{}, err
+	}
+
+	if msg.Valid {
+		status.Error = errors.New(msg.String)
+	}
+	return status, nil
+}
+
+// Close closes the work queue, it is the callers responsability to cancel the context
+// of the current transaction.
+func (w *WorkQueue) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+// Subscription represents a subscription to a transaction.
+type Subscription struct {
+	w  *WorkQueue // parent work queue.
+	id int64
+	c  chan csb.Status
+
+	close chan struct{}
+	once  sync.Once // used to close close only once.
+}
+
+// poll forwards the new statuses of the transaction until the transaction is done or
+// cancelled.
+func (s *Subscription) poll(curr csb.Status) {
+	defer close(s.c)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for curr.State != csb.Done && curr.State != csb.Cancelled {
+		select {
+		case <-s.close:
+			return
+		case <-s.w.done:
+			return
+		case <-ticker.C:
+		}
+
+		status, err := s.w.findStatus(context.Background(), s.id)
+		if err != nil || status.State == curr.State {
+			continue
+		}
+		curr = status
+
+		select {
+		case <-s.close:
+			return
+		case s.c <- curr:
+		}
+	}
+}
+
+// C returns a stream of status updates, the channel always has a status update
+// when C is called indicating the current status of the message.
+//
+// When the channel is closed the previous status will indicate why.
+func (s *Subscription) C() <-chan csb.Status {
+	return s.c
+}
+
+// Close closes the subscription.
+func (s *Subscription) Close() error {
+	s.once.Do(func() { close(s.close) })
+	return nil
+}
@@ -0,0 +1,116 @@
+package csb
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// RetentionAnonymize replaces the pupil id and the name of the students with a surrogate,
+	// keeping their marks, subjects and ranks for the statistics.
+	RetentionAnonymize = "anonymize"
+	// RetentionDelete permanently deletes the students along side all their data.
+	RetentionDelete = "delete"
+)
+
+// RetentionPolicy represents how long the data of the students who left the school is kept.
+type RetentionPolicy struct {
+	// Years is the amount of years the data of a student is kept after they leave the school.
+	Years int `json:"years"`
+	// Action is taken on the data of the students once the years passed, either
+	// RetentionAnonymize or RetentionDelete.
+	Action string `json:"action"`
+}
+
+func (p *RetentionPolicy) Validate() error {
+	if p.Years < 0 {
+		return Errorf(EINVALID, "validate: retention policy years cannot be negative")
+	}
+	if p.Action != RetentionAnonymize && p.Action != RetentionDelete {
+		return Errorf(EINVALID, "validate: retention policy has invalid action: %v", p.Action)
+	}
+
+	return nil
+}
+
+// RetentionReport represents the students a retention policy was applied to.
+type RetentionReport struct {
+	Policy RetentionPolicy `json:"policy"`
+	// Erasures are the erasures of the students the action was taken on.
+	Erasures []*ErasureRequest `json:"erasures"`
+}
+
+// ErasureRequest represents a record of the data removed about a student, either requested by
+// the student or taken by a retention policy.
+type ErasureRequest struct {
+	// ID of the erasure.
+	ID int `json:"id"`
+
+	// PID is the pupil id the data was held on.
+	PID int `json:"pid"`
+	// Action taken on the data, either RetentionAnonymize or RetentionDelete. Erasures
+	// requested by the students are always deletes.
+	Action string `json:"action"`
+	// Reason of the erasure.
+	Reason string `json:"reason"`
+	// Removed counts the removed records by kind: students, subjects, marks, alert_rules,
+	// alerts, ranks and audit_summaries.
+	Removed map[string]int `json:"removed"`
+
+	// Timestamp of the erasure.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (r *ErasureRequest) Validate() error {
+	if r.PID == 0 {
+		return Errorf(EINVALID, "validate: erasure request missing pid field")
+	}
+	if r.Reason == "" {
+		return Errorf(EINVALID, "validate: erasure request missing reason field")
+	}
+
+	return nil
+}
+
+// StudentData represents all the data held on a student, as exported for a subject access
+// request.
+type StudentData struct {
+	// Student along side their subjects, subject history and marks.
+	Student    *Student     `json:"student"`
+	AlertRules []*AlertRule `json:"alert_rules"`
+	Alerts     []*Alert     `json:"alerts"`
+	Ranks      []*Rank      `json:"ranks"`
+	// Erasures are the previous erasures of data held on the student.
+	Erasures []*ErasureRequest `json:"erasures"`
+	// AuditEntries are the entries of the audit log about the student and their marks along
+	// side their summaries, oldest first.
+	AuditEntries []*AuditEntry `json:"audit_entries"`
+
+	// Timestamp of the export.
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// PrivacyService represents a service handling the retention of the data of former students
+// and the data protection requests of the students.
+type PrivacyService interface {
+	// ApplyRetention takes the action of the policy on the students who left the school more
+	// than policy.Years ago, each student is recorded as an erasure.
+	//
+	// returns EINVALID if the policy isnt valid.
+	ApplyRetention(ctx context.Context, policy RetentionPolicy) (*RetentionReport, error)
+
+	// ExportStudentData returns all the data held on the student with pid = pid.
+	//
+	// returns ENOTFOUND if the student doesnt exist.
+	ExportStudentData(ctx context.Context, pid int) (*StudentData, error)
+
+	// CreateErasureRequest permanently deletes all the data held on the student with
+	// pid = req.PID and records the removed data on the request.
+	//
+	// returns ENOTFOUND if the student doesnt exist.
+	CreateErasureRequest(ctx context.Context, req *ErasureRequest) error
+
+	// FindErasureRequests returns the recorded erasures, the erasures of the student with
+	// pid = pid if pid isnt nil.
+	FindErasureRequests(ctx context.Context, pid *int) ([]*ErasureRequest, error)
+}
@@ -13,6 +13,12 @@ type RankingFilter struct {
 	// Filter the marks. (roughly the same as csb.MarksFilter)
 	Subjects []Subject `json:"subjects"`
 	Periods  Period    `json:"periods"`
+
+	// GradeScale is the name of the grade scale family used to derive the grade of each rank.
+	GradeScale *string `json:"grade_scale"`
+	// WeightProfile references the weight profile used to score the ranks, the score is a
+	// plain average of the marks if nil.
+	WeightProfile *WeightProfileRef `json:"weight_profile"`
 }
 
 // Rank represents a ranking record in the CSB Open API. It is similar to a normal engage
@@ -24,10 +30,18 @@ type Rank struct {
 	ID int `json:"id"`
 
 	// Score represents the averge of the report calculated from the marks gained on the
-	// provided subjects on the period. Note that the period doesent have to be full.
+	// provided subjects on the period, weighted by the weight profile if one was used.
+	// Note that the period doesent have to be full.
 	//
 	// to find out more on how the reports are generated / calculated go to: https://github.com/CSB-Open-API/.github/blob/main/generating_reports.md
 	Score int `json:"score"`
+	// WeightProfile and WeightProfileVersion record the weight profile which produced the
+	// score, empty if the score is a plain average.
+	WeightProfile        string `json:"weight_profile,omitempty"`
+	WeightProfileVersion int    `json:"weight_profile_version,omitempty"`
+	// Grade is the grade derived from the score, only populated if a grade scale was
+	// requested.
+	Grade string `json:"grade,omitempty"`
 	// Postion is an optional field which is populated if the rank was created in a "competitive"
 	// enviroment. This means that the rank was populated by the GenerateRankingsReport method.
 	Postion int `json:"position,omitempty"`
@@ -64,7 +78,8 @@ type RankingService interface {
 	// generally looking back at ones marks.
 	//
 	// The period doesnt need to be full and the subjects can be nil / empty (all subject will be taken).
-	CreateBackupRank(ctx context.Context, pid int, period Period, subjects []Subject) (Rank, error)
+	// The score is weighted by the referenced weight profile, if any.
+	CreateBackupRank(ctx context.Context, pid int, period Period, subjects []Subject, profile *WeightProfileRef) (Rank, error)
 
 	// DeleteRank deletes a rank with the provided id.
 	DeleteRank(ctx context.Context, id int) error
@@ -0,0 +1,216 @@
+package csb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	// ResearchDrop leaves the teachers out of a research dataset.
+	ResearchDrop = "drop"
+	// ResearchPseudonymize replaces the teachers of a research dataset with pseudonyms.
+	ResearchPseudonymize = "pseudonymize"
+)
+
+// ResearchExport represents a request for a research dataset of marks, without names and
+// with the students replaced by pseudonyms.
+type ResearchExport struct {
+	// Filter scopes the exported marks, the include, sort and page fields are ignored. The
+	// filters on identifiers, the id, pid, teacher and teacher id, arent allowed since they
+	// would tell the pseudonym of the identifier.
+	Filter MarksFilter `json:"filter"`
+	// Teachers is either ResearchDrop or ResearchPseudonymize, defaults to ResearchDrop.
+	Teachers string `json:"teachers"`
+	// MinGroupSize is the least amount of students in a group of marks with the same year
+	// group, subject and period. The year group of the marks in smaller groups is dropped,
+	// the marks whose group is still smaller without the year group are left out.
+	//
+	// MinGroupSize is required and at least 2, groups of one student single the student out.
+	MinGroupSize int `json:"min_group_size"`
+}
+
+func (e *ResearchExport) Validate() error {
+	if e.Teachers != "" && e.Teachers != ResearchDrop && e.Teachers != ResearchPseudonymize {
+		return Errorf(EINVALID, "validate: research export has invalid teachers: %v", e.Teachers)
+	}
+	if e.MinGroupSize < 2 {
+		return Errorf(EINVALID, "validate: research export min group size has to be at least 2")
+	}
+	if f := e.Filter; f.ID != nil || f.PID != nil || f.Teacher != nil || f.TeacherID != nil {
+		return Errorf(EINVALID, "validate: research export cannot filter on the id, pid or teacher of the marks")
+	}
+
+	return nil
+}
+
+// ResearchMark represents a mark in a research dataset.
+type ResearchMark struct {
+	// Student is the pseudonym of the student.
+	Student string `json:"student"`
+	// Year is the year group of the student at the academic year of the mark, nil if the
+	// student left the school or the year group was coarsened.
+	Year *int `json:"year"`
+	// Subject of the mark.
+	Subject Subject `json:"subject"`
+	// Teacher is the pseudonym of the teacher, empty if the teachers are dropped.
+	Teacher    string `json:"teacher,omitempty"`
+	Percentage int    `json:"percentage"`
+	Period     Period `json:"period"`
+}
+
+// Pseudonymizer replaces identifiers with keyed pseudonyms. The same identifier always maps to
+// the same pseudonym under the same key, so the pseudonyms are stable across exports and cant
+// be reversed without the key.
+type Pseudonymizer struct {
+	key []byte
+}
+
+// NewPseudonymizer creates a new pseudonymizer with the provided key.
+func NewPseudonymizer(key string) *Pseudonymizer {
+	return &Pseudonymizer{key: []byte(key)}
+}
+
+// Pseudonym returns the pseudonym of the identifier of the provided kind, identifiers of
+// different kinds dont share pseudonyms.
+func (p *Pseudonymizer) Pseudonym(kind string, id int) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(kind + ":" + strconv.Itoa(id)))
+
+	return kind + "_" + hex.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// researchGroup is the group of a mark. The year is the year group of the student at the
+// academic year of the mark, 0 for the students who left the school and for the coarsened
+// groups.
+type researchGroup struct {
+	year         int
+	subjectID    int
+	academicYear int
+	term         int
+	importance   string
+}
+
+// ResearchDataset turns marks into research marks.
+//
+// If the groups are coarsened, all the marks have to be counted before the first is
+// converted. Marks which werent counted are taken as part of a small group.
+type ResearchDataset struct {
+	export ResearchExport
+	p      *Pseudonymizer
+	// years holds the current year group of the students attending the school, by pid.
+	years map[int]int
+
+	// students of each group, with and without the year group.
+	groups  map[researchGroup]map[int]struct{}
+	coarse  map[researchGroup]map[int]struct{}
+	counted bool
+}
+
+// NewResearchDataset creates a new research dataset for the export with the current year
+// group of the students attending the school by pid.
+func NewResearchDataset(export ResearchExport, p *Pseudonymizer, years map[int]int) *ResearchDataset {
+	return &ResearchDataset{
+		export: export,
+		p:      p,
+		years:  years,
+		groups: make(map[researchGroup]map[int]struct{}),
+		coarse: make(map[researchGroup]map[int]struct{}),
+	}
+}
+
+// Coarsens reports wether the small groups are coarsened, requiring the marks to be counted.
+func (d *ResearchDataset) Coarsens() bool {
+	return d.export.MinGroupSize > 1
+}
+
+// Count adds the student of the mark to the group of the mark.
+func (d *ResearchDataset) Count(mark *Mark) error {
+	addToGroup(d.groups, d.group(mark, true), mark.StudentID)
+	return nil
+}
+
+// Convert returns the research mark of the mark, nil if the mark is left out.
+func (d *ResearchDataset) Convert(mark *Mark) *ResearchMark {
+	out := &ResearchMark{
+		Student:    d.p.Pseudonym("student", mark.StudentID),
+		Subject:    mark.Subject,
+		Percentage: mark.Percentage,
+		Period:     mark.Period,
+	}
+	if year := d.yearIn(mark); year != 0 {
+		out.Year = &year
+	}
+	if d.export.Teachers == ResearchPseudonymize && mark.TeacherID != 0 {
+		out.Teacher = d.p.Pseudonym("teacher", mark.TeacherID)
+	}
+
+	if !d.Coarsens() {
+		return out
+	}
+	if !d.counted {
+		d.countCoarse()
+	}
+
+	if len(d.groups[d.group(mark, true)]) >= d.export.MinGroupSize {
+		return out
+	}
+	out.Year = nil
+	if len(d.coarse[d.group(mark, false)]) >= d.export.MinGroupSize {
+		return out
+	}
+	return nil
+}
+
+// countCoarse counts the students of the small groups without their year group.
+func (d *ResearchDataset) countCoarse() {
+	for group, students := range d.groups {
+		if len(students) >= d.export.MinGroupSize {
+			continue
+		}
+
+		group.year = 0
+		for pid := range students {
+			addToGroup(d.coarse, group, pid)
+		}
+	}
+	d.counted = true
+}
+
+func (d *ResearchDataset) group(mark *Mark, withYear bool) researchGroup {
+	group := researchGroup{
+		subjectID:    mark.SubjectID,
+		academicYear: mark.Period.AcademicYear,
+	}
+	if withYear {
+		group.year = d.yearIn(mark)
+	}
+	if mark.Period.Term != nil {
+		group.term = *mark.Period.Term
+	}
+	if mark.Period.Importance != nil {
+		group.importance = *mark.Period.Importance
+	}
+
+	return group
+}
+
+// yearIn returns the year group of the student of the mark at the academic year of the mark,
+// 0 if the student left the school.
+func (d *ResearchDataset) yearIn(mark *Mark) int {
+	year, ok := d.years[mark.StudentID]
+	if !ok {
+		return 0
+	}
+
+	student := Student{CurrentYear: year, AttendsSchool: true}
+	return student.YearIn(mark.Period.AcademicYear)
+}
+
+func addToGroup(groups map[researchGroup]map[int]struct{}, group researchGroup, pid int) {
+	if groups[group] == nil {
+		groups[group] = make(map[int]struct{})
+	}
+	groups[group][pid] = struct{}{}
+}
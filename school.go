@@ -0,0 +1,26 @@
+package csb
+
+import "regexp"
+
+// schoolIDRegexp matches the valid school ids, which are used in the routes of the schools.
+var schoolIDRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// School represents a tenant of the api. Each school has its own engage instance and its own
+// database, so the pids of different schools never collide.
+type School struct {
+	// ID of the school, lower case letters, digits and dashes.
+	ID string `json:"id"`
+	// Name of the school.
+	Name string `json:"name"`
+}
+
+func (s *School) Validate() error {
+	if !schoolIDRegexp.MatchString(s.ID) {
+		return Errorf(EINVALID, "validate: school has invalid id: %q", s.ID)
+	}
+	if s.Name == "" {
+		return Errorf(EINVALID, "validate: school has no name")
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package csb
+
+import "context"
+
+// StudentSearch represents a request to the SearchStudents service.
+type StudentSearch struct {
+	// Query is matched against the names of the students regardless of diacritics and word
+	// order. Every word is matched as a prefix and small typos are tolerated.
+	Query string `json:"query"`
+	// AttendsSchool filters wether the students currently attend school.
+	AttendsSchool *bool `json:"attends_school"`
+	// Limit caps the number of results, defaults to 20.
+	Limit int `json:"limit"`
+}
+
+func (s *StudentSearch) Validate() error {
+	if s.Query == "" {
+		return Errorf(EINVALID, "validate: student search missing query field")
+	}
+	if s.Limit < 0 {
+		return Errorf(EINVALID, "validate: student search limit cannot be negative")
+	}
+	return nil
+}
+
+// StudentMatch represents a student matched by a search.
+type StudentMatch struct {
+	Student *Student `json:"student"`
+	// Score is the relevance of the match, higher is more relevant.
+	Score float64 `json:"score"`
+}
+
+// SearchService represents a service searching the students.
+type SearchService interface {
+	// SearchStudents finds the students matching the search, ordered by relevance.
+	//
+	// returns EINVALID if the query has no words.
+	SearchStudents(ctx context.Context, search StudentSearch) ([]*StudentMatch, error)
+}
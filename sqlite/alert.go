@@ -0,0 +1,491 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.AlertService = (*AlertService)(nil)
+
+// AlertService stores alert rules and the alerts raised by them.
+//
+// The rules are evaluated by createDiff whenever new marks are inserted.
+type AlertService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewAlertService creates a new alert service with the provided database.
+func NewAlertService(db *DB) *AlertService {
+	return &AlertService{
+		db: db,
+	}
+}
+
+// FindAlertRuleByID returns an alert rule based on the passed id.
+//
+// returns ENOTFOUND if the rule isnt found.
+func (s *AlertService) FindAlertRuleByID(ctx context.Context, id int) (*csb.AlertRule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findAlertRuleByID(ctx, tx, id)
+}
+
+// FindAlertRules returns a range of alert rules based on filter.
+func (s *AlertService) FindAlertRules(ctx context.Context, filter csb.AlertRuleFilter) ([]*csb.AlertRule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findAlertRules(ctx, tx, filter)
+}
+
+// CreateAlertRule creates a new alert rule.
+func (s *AlertService) CreateAlertRule(ctx context.Context, rule *csb.AlertRule) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createAlertRule(ctx, tx, rule); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteAlertRule permanently deletes an alert rule and the alerts raised by it.
+//
+// returns ENOTFOUND if the rule isnt found.
+func (s *AlertService) DeleteAlertRule(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteAlertRule(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FindAlerts returns a range of alerts based on filter, the rule and mark of each alert
+// are attached.
+func (s *AlertService) FindAlerts(ctx context.Context, filter csb.AlertFilter) ([]*csb.Alert, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	alerts, err := findAlerts(ctx, tx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, alert := range alerts {
+		if err := attachAlertAssociations(ctx, tx, alert); err != nil {
+			return nil, err
+		}
+	}
+
+	return alerts, nil
+}
+
+func findAlertRuleByID(ctx context.Context, tx *Tx, id int) (*csb.AlertRule, error) {
+	row := tx.QueryRowContext(ctx, `
+		SELECT
+			id,
+			kind,
+			threshold,
+			student_id,
+			year,
+			subject_id,
+			created_at
+		FROM alert_rules
+		WHERE id = ?
+	`,
+		id,
+	)
+
+	rule, err := scanAlertRule(row)
+	if err == sql.ErrNoRows {
+		return nil, csb.Errorf(csb.ENOTFOUND, "alert rule not found")
+	}
+
+	return rule, err
+}
+
+func findAlertRules(ctx context.Context, tx *Tx, filter csb.AlertRuleFilter) ([]*csb.AlertRule, error) {
+	// prepare where clause.
+	where, args := []string{"1=1"}, []interface{}{}
+	if v := filter.Kind; v != nil {
+		where = append(where, "kind = ?")
+		args = append(args, *v)
+	}
+	if v := filter.StudentID; v != nil {
+		where = append(where, "student_id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Year; v != nil {
+		where = append(where, "year = ?")
+		args = append(args, *v)
+	}
+	if v := filter.SubjectID; v != nil {
+		where = append(where, "subject_id = ?")
+		args = append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			kind,
+			threshold,
+			student_id,
+			year,
+			subject_id,
+			created_at
+		FROM alert_rules
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY id ASC
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]*csb.AlertRule, 0)
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func createAlertRule(ctx context.Context, tx *Tx, rule *csb.AlertRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	rule.CreatedAt = time.Now()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO alert_rules (
+			kind,
+			threshold,
+			student_id,
+			year,
+			subject_id,
+			created_at
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`,
+		rule.Kind,
+		rule.Threshold,
+		rule.StudentID,
+		rule.Year,
+		rule.SubjectID,
+		rule.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rule.ID = int(id)
+
+	return nil
+}
+
+func deleteAlertRule(ctx context.Context, tx *Tx, id int) error {
+	if _, err := findAlertRuleByID(ctx, tx, id); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = ?`, id)
+	return err
+}
+
+func findAlerts(ctx context.Context, tx *Tx, filter csb.AlertFilter) ([]*csb.Alert, error) {
+	// prepare where clause.
+	where, args := []string{"1=1"}, []interface{}{}
+	if v := filter.RuleID; v != nil {
+		where = append(where, "alerts.rule_id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.PID; v != nil {
+		where = append(where, "alerts.student_id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Year; v != nil {
+		where = append(where, "students.current_year = ?")
+		args = append(args, *v)
+	}
+	if v := filter.SubjectID; v != nil {
+		where = append(where, "marks.subject_id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Since; v != nil {
+		where = append(where, "alerts.created_at >= ?")
+		args = append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			alerts.id,
+			alerts.rule_id,
+			alerts.mark_id,
+			alerts.student_id,
+			alerts.previous_percentage,
+			alerts.created_at
+		FROM alerts
+		JOIN students ON students.pid = alerts.student_id
+		JOIN marks ON marks.id = alerts.mark_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY alerts.created_at DESC, alerts.id DESC
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := make([]*csb.Alert, 0)
+	for rows.Next() {
+		var alert csb.Alert
+		var previous sql.NullInt64
+		if err := rows.Scan(
+			&alert.ID,
+			&alert.RuleID,
+			&alert.MarkID,
+			&alert.StudentID,
+			&previous,
+			&alert.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		alert.Previous = nullIntPtr(previous)
+		alerts = append(alerts, &alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+// createAlert creates the alert, its ID is left 0 if the rule already raised an alert for the
+// mark.
+func createAlert(ctx context.Context, tx *Tx, alert *csb.Alert) error {
+	alert.CreatedAt = time.Now()
+
+	// a rule matches a mark only once, ignore re-evaluations of the same mark.
+	res, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO alerts (
+			rule_id,
+			mark_id,
+			student_id,
+			previous_percentage,
+			created_at
+		) VALUES (?, ?, ?, ?, ?)
+	`,
+		alert.RuleID,
+		alert.MarkID,
+		alert.StudentID,
+		alert.Previous,
+		alert.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	// the last insert id isnt updated by an ignored insert.
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	alert.ID = int(id)
+
+	return nil
+}
+
+// evaluateAlerts evaluates all the alert rules in scope of the mark and raises an alert for
+// each rule the mark breaks. The mark must already be stored.
+func evaluateAlerts(ctx context.Context, tx *Tx, mark *csb.Mark) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			kind,
+			threshold,
+			student_id,
+			year,
+			subject_id,
+			created_at
+		FROM alert_rules
+		WHERE (student_id IS NULL OR student_id = ?)
+		AND (subject_id IS NULL OR subject_id = ?)
+		AND (year IS NULL OR year = (SELECT current_year FROM students WHERE pid = ?))
+	`,
+		mark.StudentID,
+		mark.SubjectID,
+		mark.StudentID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// read all the rules before running any other query on the transaction.
+	rules := make([]*csb.AlertRule, 0)
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		rules = append(rules, rule)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// previous is loaded lazily, only drop rules need it.
+	var previous *int
+	var previousLoaded bool
+	for _, rule := range rules {
+		alert := &csb.Alert{
+			RuleID:    rule.ID,
+			MarkID:    mark.ID,
+			StudentID: mark.StudentID,
+		}
+
+		switch rule.Kind {
+		case csb.AlertBelowThreshold:
+			if mark.Percentage >= rule.Threshold {
+				continue
+			}
+
+		case csb.AlertDrop:
+			if !previousLoaded {
+				if previous, err = findPreviousPercentage(ctx, tx, mark); err != nil {
+					return err
+				}
+				previousLoaded = true
+			}
+
+			if previous == nil || *previous-mark.Percentage <= rule.Threshold {
+				continue
+			}
+			alert.Previous = previous
+		}
+
+		if err := createAlert(ctx, tx, alert); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findPreviousPercentage finds the percentage of the latest mark on the same subject
+// recieved in a period before the marks period.
+//
+// importance has no order, so marks in the same term as the mark are never considered
+// previous.
+func findPreviousPercentage(ctx context.Context, tx *Tx, mark *csb.Mark) (*int, error) {
+	row := tx.QueryRowContext(ctx, `
+		SELECT percentage
+		FROM marks
+		WHERE student_id = ?
+		AND subject_id = ?
+		AND (academic_year < ? OR (academic_year = ? AND term < ?))
+		ORDER BY academic_year DESC, term DESC, id DESC
+		LIMIT 1
+	`,
+		mark.StudentID,
+		mark.SubjectID,
+		mark.Period.AcademicYear,
+		mark.Period.AcademicYear,
+		mark.Period.Term,
+	)
+
+	var percentage int
+	if err := row.Scan(&percentage); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &percentage, nil
+}
+
+func attachAlertAssociations(ctx context.Context, tx *Tx, alert *csb.Alert) (err error) {
+	if alert.Rule, err = findAlertRuleByID(ctx, tx, alert.RuleID); err != nil {
+		return err
+	}
+
+	alert.Mark, err = findMarkByID(ctx, tx, alert.MarkID)
+	return err
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAlertRule(s scanner) (*csb.AlertRule, error) {
+	var rule csb.AlertRule
+	var studentID, year, subjectID sql.NullInt64
+	if err := s.Scan(
+		&rule.ID,
+		&rule.Kind,
+		&rule.Threshold,
+		&studentID,
+		&year,
+		&subjectID,
+		&rule.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	rule.StudentID = nullIntPtr(studentID)
+	rule.Year = nullIntPtr(year)
+	rule.SubjectID = nullIntPtr(subjectID)
+	return &rule, nil
+}
+
+// nullIntPtr converts a nullable integer column to an optional int.
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+
+	i := int(v.Int64)
+	return &i
+}
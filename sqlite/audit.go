@@ -0,0 +1,299 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.AuditService = (*AuditService)(nil)
+
+// AuditService reads the audit log written by the other services in the transactions of the
+// changes.
+type AuditService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewAuditService creates a new audit service with the provided database.
+func NewAuditService(db *DB) *AuditService {
+	return &AuditService{
+		db: db,
+	}
+}
+
+// FindAuditEntries returns the audit entries matching the filter, newest first. Filters
+// without a limit return csb.DefaultPageSize entries and limits are capped to csb.MaxPageSize.
+func (s *AuditService) FindAuditEntries(ctx context.Context, filter csb.AuditFilter) ([]*csb.AuditEntry, error) {
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, csb.Errorf(csb.EINVALID, "limit and offset cannot be negative")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findAuditEntries(ctx, tx, filter)
+}
+
+// studentSummary is the summary of a student in the audit log, without their name.
+type studentSummary struct {
+	CurrentYear   int  `json:"current_year"`
+	AttendsSchool bool `json:"attends_school"`
+}
+
+// markSummary is the summary of a mark in the audit log.
+type markSummary struct {
+	StudentID     int        `json:"student_id"`
+	SubjectID     int        `json:"subject_id"`
+	TeacherID     int        `json:"teacher_id"`
+	Percentage    int        `json:"percentage"`
+	Period        csb.Period `json:"period"`
+	ImportBatchID *int       `json:"import_batch_id,omitempty"`
+}
+
+func summarizeStudent(student *csb.Student) studentSummary {
+	summary := studentSummary{AttendsSchool: student.AttendsSchool}
+	if student.AttendsSchool {
+		summary.CurrentYear = student.CurrentYear
+	}
+	return summary
+}
+
+func summarizeMark(mark *csb.Mark) markSummary {
+	return markSummary{
+		StudentID:     mark.StudentID,
+		SubjectID:     mark.SubjectID,
+		TeacherID:     mark.TeacherID,
+		Percentage:    mark.Percentage,
+		Period:        mark.Period,
+		ImportBatchID: mark.ImportBatchID,
+	}
+}
+
+// createAuditEntry records the change of the target by the actor of the context. Nil
+// summaries are left empty, updates which dont change the summary arent recorded.
+func createAuditEntry(ctx context.Context, tx *Tx, operation, target string, targetID int, before, after interface{}) error {
+	entry := csb.AuditEntry{
+		Actor:     csb.ActorFromContext(ctx),
+		Operation: operation,
+		Target:    target,
+		TargetID:  targetID,
+		CreatedAt: time.Now(),
+	}
+
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+	if operation == csb.AuditUpdate && bytes.Equal(entry.Before, entry.After) {
+		return nil
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_log (
+			actor,
+			operation,
+			target,
+			target_id,
+			created_at
+		) VALUES (?, ?, ?, ?, ?)
+	`,
+		entry.Actor,
+		entry.Operation,
+		entry.Target,
+		entry.TargetID,
+		entry.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if entry.Before == nil && entry.After == nil {
+		return nil
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	// the summaries are kept out of the append-only log, the erasure of the student they are
+	// about clears them.
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_summaries (
+			entry_id,
+			student_pid,
+			before,
+			after
+		) VALUES (?, ?, ?, ?)
+	`,
+		id,
+		summaryStudentPID(target, targetID, before, after),
+		nullRawMessage(entry.Before),
+		nullRawMessage(entry.After),
+	)
+	return err
+}
+
+// summaryStudentPID returns the pupil id of the student the summaries of the target are about,
+// null if the summaries arent about a student.
+func summaryStudentPID(target string, targetID int, summaries ...interface{}) sql.NullInt64 {
+	if target == csb.AuditStudent {
+		return sql.NullInt64{Int64: int64(targetID), Valid: true}
+	}
+
+	for _, summary := range summaries {
+		if summary, ok := summary.(markSummary); ok {
+			return sql.NullInt64{Int64: int64(summary.StudentID), Valid: true}
+		}
+	}
+	return sql.NullInt64{}
+}
+
+// clearAuditSummaries clears the audit summaries about the student with pid = pid, the entries
+// of the log stay.
+func clearAuditSummaries(ctx context.Context, tx *Tx, pid int) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM audit_summaries WHERE student_pid = ?`, pid)
+	return err
+}
+
+func findAuditEntries(ctx context.Context, tx *Tx, filter csb.AuditFilter) ([]*csb.AuditEntry, error) {
+	// prepare where clause.
+	where, args := []string{"1=1"}, []interface{}{}
+	if v := filter.Actor; v != nil {
+		where = append(where, "actor = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Operation; v != nil {
+		where = append(where, "operation = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Target; v != nil {
+		where = append(where, "target = ?")
+		args = append(args, *v)
+	}
+	if v := filter.TargetID; v != nil {
+		where = append(where, "target_id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Since; v != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, *v)
+	}
+	if v := filter.Until; v != nil {
+		where = append(where, "created_at <= ?")
+		args = append(args, *v)
+	}
+
+	limit := filter.Limit
+	switch {
+	case limit == 0:
+		limit = csb.DefaultPageSize
+	case limit > csb.MaxPageSize:
+		limit = csb.MaxPageSize
+	}
+	args = append(args, limit, filter.Offset)
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			audit_log.id,
+			audit_log.actor,
+			audit_log.operation,
+			audit_log.target,
+			audit_log.target_id,
+			audit_summaries.before,
+			audit_summaries.after,
+			audit_log.created_at
+		FROM audit_log
+		LEFT JOIN audit_summaries ON audit_summaries.entry_id = audit_log.id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY audit_log.created_at DESC, audit_log.id DESC
+		LIMIT ? OFFSET ?
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAuditEntries(rows)
+}
+
+// findAuditEntriesByPID returns all the audit entries about the student with pid = pid, the
+// entries targeting the student and the entries with summaries about them, oldest first.
+func findAuditEntriesByPID(ctx context.Context, tx *Tx, pid int) ([]*csb.AuditEntry, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			audit_log.id,
+			audit_log.actor,
+			audit_log.operation,
+			audit_log.target,
+			audit_log.target_id,
+			audit_summaries.before,
+			audit_summaries.after,
+			audit_log.created_at
+		FROM audit_log
+		LEFT JOIN audit_summaries ON audit_summaries.entry_id = audit_log.id
+		WHERE (audit_log.target = ? AND audit_log.target_id = ?) OR audit_summaries.student_pid = ?
+		ORDER BY audit_log.created_at ASC, audit_log.id ASC
+	`,
+		csb.AuditStudent,
+		pid,
+		pid,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAuditEntries(rows)
+}
+
+func scanAuditEntries(rows *sql.Rows) ([]*csb.AuditEntry, error) {
+	defer rows.Close()
+
+	entries := make([]*csb.AuditEntry, 0)
+	for rows.Next() {
+		var entry csb.AuditEntry
+		var before, after sql.NullString
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.Actor,
+			&entry.Operation,
+			&entry.Target,
+			&entry.TargetID,
+			&before,
+			&after,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			entry.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			entry.After = json.RawMessage(after.String)
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func nullRawMessage(v json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(v), Valid: v != nil}
+}
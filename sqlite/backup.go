@@ -0,0 +1,376 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/trace"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
+	gosqlite "github.com/mattn/go-sqlite3"
+)
+
+// backupPrefix and backupSuffix surround the creation time of each backup file name.
+const (
+	backupPrefix     = "csb-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102T150405.000Z"
+)
+
+var _ csb.BackupService = (*BackupService)(nil)
+
+// BackupService takes online backups of the database with the sqlite backup api into a
+// directory, keeping only the newest backups.
+type BackupService struct {
+	// db for persistance.
+	db *DB
+	// dir is the directory of the backups.
+	dir string
+	// keep is the amount of backups kept, all the backups are kept if keep isnt positive.
+	keep int
+
+	// only take one backup at a time.
+	mu sync.Mutex
+}
+
+// NewBackupService creates a new backup service backing up the database to dir and keeping the
+// newest keep backups.
+func NewBackupService(db *DB, dir string, keep int) *BackupService {
+	return &BackupService{
+		db:   db,
+		dir:  dir,
+		keep: keep,
+	}
+}
+
+// Schedule takes a backup every interval until the context is done. Failed backups are
+// logged and retried on the next interval.
+func (s *BackupService) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.CreateBackup(ctx); err != nil {
+				trace.LogError(ctx, "scheduled backup failed", err, trace.Fields{
+					"component": "sqlite",
+					"dir":       s.dir,
+				})
+			}
+		}
+	}
+}
+
+// CreateBackup copies the database page by page with the sqlite backup api. Under WAL the
+// copy reads a single snapshot of the database, the writers arent blocked while the backup
+// runs.
+//
+// The backup is written to a temporary file first, so a failed backup never shows up in
+// the backups. The oldest backups over the kept amount are deleted.
+func (s *BackupService) CreateBackup(ctx context.Context) (*csb.Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	name := backupPrefix + time.Now().UTC().Format(backupTimeFormat) + backupSuffix
+	path := filepath.Join(s.dir, name)
+	if err := s.db.backup(ctx, path+".tmp"); err != nil {
+		os.Remove(path + ".tmp")
+		return nil, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, err
+	}
+
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+
+	return statBackup(path)
+}
+
+// FindBackups returns the kept backups, newest first.
+func (s *BackupService) FindBackups(ctx context.Context) ([]*csb.Backup, error) {
+	names, err := s.backupNames()
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]*csb.Backup, 0, len(names))
+	for _, name := range names {
+		backup, err := statBackup(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+// OpenBackup opens the backup with name = name for reading.
+//
+// returns ENOTFOUND if the backup doesnt exist.
+func (s *BackupService) OpenBackup(ctx context.Context, name string) (*csb.Backup, io.ReadCloser, error) {
+	// only the names of backups are valid, not paths.
+	if !isBackupName(name) {
+		return nil, nil, csb.Errorf(csb.ENOTFOUND, "backup not found")
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, csb.Errorf(csb.ENOTFOUND, "backup not found")
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	backup, err := statBackup(f.Name())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return backup, f, nil
+}
+
+// rotate deletes the oldest backups over the kept amount.
+func (s *BackupService) rotate() error {
+	if s.keep <= 0 {
+		return nil
+	}
+
+	names, err := s.backupNames()
+	if err != nil {
+		return err
+	}
+
+	for len(names) > s.keep {
+		if err := os.Remove(filepath.Join(s.dir, names[len(names)-1])); err != nil {
+			return err
+		}
+		names = names[:len(names)-1]
+	}
+
+	return nil
+}
+
+// backupNames returns the file names of the backups, newest first.
+func (s *BackupService) backupNames() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isBackupName(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	// the creation time in the names sorts lexicographically.
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	return names, nil
+}
+
+// backup copies the database to a new database at path.
+func (db *DB) backup(ctx context.Context, path string) error {
+	src, err := db.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	destDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer destDB.Close()
+
+	dest, err := destDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	return dest.Raw(func(destConn interface{}) error {
+		return src.Raw(func(srcConn interface{}) error {
+			b, err := destConn.(*gosqlite.SQLiteConn).Backup("main", srcConn.(*gosqlite.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+
+			// copy all the pages in one step so the backup reads a single snapshot.
+			if _, err := b.Step(-1); err != nil {
+				b.Close()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+}
+
+// Restore replaces the database at dsn with the backup at path. The database must not be
+// open while it is restored.
+//
+// The schema version of the backup is checked against the migrations at migrationsPath
+// before the backup is swapped in, older backups are migrated on the next Open. The
+// replaced database is kept next to it with a .pre-restore suffix.
+//
+// returns EINVALID if the backup isnt a migrated database, is dirty or is newer than the
+// migrations.
+func Restore(path, dsn, migrationsPath string) error {
+	dbPath := dsnPath(dsn)
+	if dbPath == "" || dbPath == ":memory:" {
+		return errors.New("db should be persistent")
+	}
+
+	// check a copy of the backup, checking the version creates the version table if it is
+	// missing.
+	tmp := dbPath + ".restore"
+	if err := copyFile(path, tmp); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	version, dirty, err := schemaVersion(tmp)
+	if err != nil {
+		return err
+	}
+	latest, err := latestMigration(migrationsPath)
+	if err != nil {
+		return err
+	}
+	switch {
+	case version < 0:
+		return csb.Errorf(csb.EINVALID, "backup has no schema version")
+	case dirty:
+		return csb.Errorf(csb.EINVALID, "backup has dirty schema version: %v", version)
+	case uint(version) > latest:
+		return csb.Errorf(csb.EINVALID, "backup schema version %v is newer than the migrations: %v", version, latest)
+	}
+
+	// move the replaced database along side its wal, a stale wal would be replayed on the
+	// restored database.
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		err := os.Rename(dbPath+suffix, dbPath+".pre-restore"+suffix)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return os.Rename(tmp, dbPath)
+}
+
+// schemaVersion returns the migration version of the database at path, -1 if the database
+// was never migrated.
+func schemaVersion(path string) (int, bool, error) {
+	dbSQL, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, false, err
+	}
+	defer dbSQL.Close()
+
+	driver, err := sqlite3.WithInstance(dbSQL, &sqlite3.Config{})
+	if err != nil {
+		return 0, false, err
+	}
+
+	return driver.Version()
+}
+
+// latestMigration returns the version of the newest migration at migrationsPath.
+func latestMigration(migrationsPath string) (uint, error) {
+	src, err := source.Open(migrationsPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("first migration: %w", err)
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		} else if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// dsnPath returns the path of the database file of the dsn, without the file: scheme and
+// the query parameters.
+func dsnPath(dsn string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	return path
+}
+
+func statBackup(path string) (*csb.Backup, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(info.Name(), backupPrefix), backupSuffix))
+	if err != nil {
+		createdAt = info.ModTime()
+	}
+
+	return &csb.Backup{
+		Name:      info.Name(),
+		Size:      info.Size(),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// isBackupName reports wether name is the file name of a backup.
+func isBackupName(name string) bool {
+	if filepath.Base(name) != name || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return false
+	}
+
+	_, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix))
+	return err == nil
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
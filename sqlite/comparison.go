@@ -0,0 +1,346 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.ComparisonService = (*ComparisonService)(nil)
+
+// ComparisonService compares the marks of students, on top of the mark service.
+type ComparisonService struct {
+	// db for persistance.
+	db *DB
+	// markService is used to find the marks over period ranges.
+	markService csb.MarkService
+}
+
+// NewComparisonService creates a new comparison service with the provided database and mark
+// service.
+func NewComparisonService(db *DB, markService csb.MarkService) *ComparisonService {
+	return &ComparisonService{
+		db:          db,
+		markService: markService,
+	}
+}
+
+// CompareStudents compares the marks of the students over the period range. Every subject
+// any of the students has marks on is compared, subjects only one student has marks on are
+// returned unshared.
+func (s *ComparisonService) CompareStudents(ctx context.Context, filter csb.HeadToHeadFilter) (*csb.HeadToHead, error) {
+	if len(filter.PIDs) < 2 {
+		return nil, csb.Errorf(csb.EINVALID, "comparison needs at least 2 students, but got: %v", len(filter.PIDs))
+	}
+
+	seen := make(map[int]struct{}, len(filter.PIDs))
+	for _, pid := range filter.PIDs {
+		if _, ok := seen[pid]; ok {
+			return nil, csb.Errorf(csb.EINVALID, "duplicate student: %v", pid)
+		}
+		seen[pid] = struct{}{}
+	}
+
+	if err := s.findStudents(ctx, filter.PIDs); err != nil {
+		return nil, err
+	}
+
+	// align the marks by subject and period, the index of the student in the filter is
+	// the index of their percentages.
+	subjects := make(map[int]*subjectAlignment)
+	for i, pid := range filter.PIDs {
+		marks, err := s.markService.FindMarksByPeriodRange(ctx, filter.From, filter.To, csb.MarksFilter{
+			PID:      &pid,
+			Subjects: filter.Subjects,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mark := range marks {
+			a, ok := subjects[mark.SubjectID]
+			if !ok {
+				a = &subjectAlignment{
+					subject: mark.Subject,
+					periods: make(map[string]*csb.PeriodComparison),
+				}
+				subjects[mark.SubjectID] = a
+			}
+
+			key := periodKey(mark.Period)
+			p, ok := a.periods[key]
+			if !ok {
+				p = &csb.PeriodComparison{
+					Period:      mark.Period,
+					Percentages: make([]*int, len(filter.PIDs)),
+				}
+				a.periods[key] = p
+			}
+			percentage := mark.Percentage
+			p.Percentages[i] = &percentage
+		}
+	}
+
+	out := &csb.HeadToHead{
+		PIDs:     filter.PIDs,
+		Subjects: make([]*csb.SubjectComparison, 0, len(subjects)),
+	}
+	for _, a := range subjects {
+		out.Subjects = append(out.Subjects, a.compare(filter.PIDs))
+	}
+	sort.Slice(out.Subjects, func(i, j int) bool { return out.Subjects[i].Subject.Name < out.Subjects[j].Subject.Name })
+
+	return out, nil
+}
+
+// CompareYears lines up the marks of the student in the two academic years by term and
+// importance. Marks in periods without a match in the other academic year are kept with a
+// nil delta.
+func (s *ComparisonService) CompareYears(ctx context.Context, filter csb.YearOverYearFilter) (*csb.YearOverYear, error) {
+	previous := filter.AcademicYear - 1
+	if filter.Previous != nil {
+		previous = *filter.Previous
+	}
+	if previous >= filter.AcademicYear {
+		return nil, csb.Errorf(csb.EINVALID, "previous academic year must be before %v, but got: %v", filter.AcademicYear, previous)
+	}
+	if filter.Importance != nil && filter.Term == nil {
+		return nil, csb.Errorf(csb.EINVALID, "cannot compare importance without term")
+	}
+
+	if err := s.findStudents(ctx, []int{filter.PID}); err != nil {
+		return nil, err
+	}
+
+	marks, err := s.markService.FindMarksByPeriodRange(
+		ctx,
+		csb.Period{AcademicYear: previous},
+		csb.Period{AcademicYear: filter.AcademicYear},
+		csb.MarksFilter{PID: &filter.PID, Subjects: filter.Subjects},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// line up the marks of both academic years by subject, term and importance.
+	subjects := make(map[int]*csb.SubjectMovement)
+	periods := make(map[int]map[string]*csb.PeriodMovement)
+	for _, mark := range marks {
+		if mark.Period.AcademicYear != previous && mark.Period.AcademicYear != filter.AcademicYear {
+			continue
+		}
+		if filter.Term != nil && *mark.Period.Term != *filter.Term {
+			continue
+		}
+		if filter.Importance != nil && *mark.Period.Importance != *filter.Importance {
+			continue
+		}
+
+		m, ok := subjects[mark.SubjectID]
+		if !ok {
+			m = &csb.SubjectMovement{Subject: mark.Subject}
+			subjects[mark.SubjectID] = m
+			periods[mark.SubjectID] = make(map[string]*csb.PeriodMovement)
+		}
+
+		key := fmt.Sprintf("%v:%v", *mark.Period.Term, *mark.Period.Importance)
+		p, ok := periods[mark.SubjectID][key]
+		if !ok {
+			p = &csb.PeriodMovement{Term: *mark.Period.Term, Importance: *mark.Period.Importance}
+			periods[mark.SubjectID][key] = p
+			m.Periods = append(m.Periods, p)
+		}
+
+		percentage := mark.Percentage
+		if mark.Period.AcademicYear == previous {
+			p.Previous = &percentage
+		} else {
+			p.Current = &percentage
+		}
+	}
+
+	out := &csb.YearOverYear{
+		StudentID:    filter.PID,
+		AcademicYear: filter.AcademicYear,
+		Previous:     previous,
+		Subjects:     make([]*csb.SubjectMovement, 0, len(subjects)),
+	}
+	var overallSum, overallCount int
+	for _, m := range subjects {
+		var hasPrevious, hasCurrent bool
+		var sum, count int
+		for _, p := range m.Periods {
+			hasPrevious = hasPrevious || p.Previous != nil
+			hasCurrent = hasCurrent || p.Current != nil
+
+			if p.Previous != nil && p.Current != nil {
+				delta := *p.Current - *p.Previous
+				p.Delta = &delta
+				sum += delta
+				count++
+			}
+		}
+		sort.Slice(m.Periods, func(i, j int) bool {
+			if m.Periods[i].Term != m.Periods[j].Term {
+				return m.Periods[i].Term < m.Periods[j].Term
+			}
+			return m.Periods[i].Importance < m.Periods[j].Importance
+		})
+
+		switch {
+		case hasPrevious && hasCurrent:
+			m.Continuity = csb.SubjectContinued
+		case hasCurrent:
+			m.Continuity = csb.SubjectStarted
+		default:
+			m.Continuity = csb.SubjectDropped
+		}
+
+		if count > 0 {
+			delta := float64(sum) / float64(count)
+			m.Delta = &delta
+			overallSum += sum
+			overallCount += count
+		}
+
+		out.Subjects = append(out.Subjects, m)
+	}
+	sort.Slice(out.Subjects, func(i, j int) bool { return out.Subjects[i].Subject.Name < out.Subjects[j].Subject.Name })
+
+	for _, m := range out.Subjects {
+		if m.Delta == nil {
+			continue
+		}
+
+		if *m.Delta > 0 && (out.BiggestImprovement == nil || *m.Delta > *out.BiggestImprovement.Delta) {
+			out.BiggestImprovement = m
+		}
+		if *m.Delta < 0 && (out.BiggestDecline == nil || *m.Delta < *out.BiggestDecline.Delta) {
+			out.BiggestDecline = m
+		}
+	}
+	if overallCount > 0 {
+		overall := float64(overallSum) / float64(overallCount)
+		out.Overall = &overall
+	}
+
+	return out, nil
+}
+
+func (s *ComparisonService) findStudents(ctx context.Context, pids []int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, pid := range pids {
+		if _, err := findStudentByPID(ctx, tx, pid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subjectAlignment accumulates the percentages of the students on a subject by period.
+type subjectAlignment struct {
+	subject csb.Subject
+	periods map[string]*csb.PeriodComparison
+}
+
+func (a *subjectAlignment) compare(pids []int) *csb.SubjectComparison {
+	out := &csb.SubjectComparison{
+		Subject:  a.subject,
+		Averages: make([]*float64, len(pids)),
+		Leaders:  make([]int, 0),
+		Periods:  make([]*csb.PeriodComparison, 0, len(a.periods)),
+	}
+
+	sums, counts := make([]int, len(pids)), make([]int, len(pids))
+	for _, p := range a.periods {
+		p.Differences = make([]*int, len(pids))
+		values := make([]*float64, len(pids))
+		for i, v := range p.Percentages {
+			if v == nil {
+				continue
+			}
+			sums[i] += *v
+			counts[i]++
+
+			if first := p.Percentages[0]; first != nil {
+				diff := *v - *first
+				p.Differences[i] = &diff
+			}
+			f := float64(*v)
+			values[i] = &f
+		}
+		p.Leaders = leaders(pids, values)
+
+		out.Periods = append(out.Periods, p)
+	}
+	sort.Slice(out.Periods, func(i, j int) bool { return lessPeriod(out.Periods[i].Period, out.Periods[j].Period) })
+
+	for i := range pids {
+		if counts[i] > 0 {
+			avg := float64(sums[i]) / float64(counts[i])
+			out.Averages[i] = &avg
+		}
+	}
+	out.Leaders = leaders(pids, out.Averages)
+	out.Shared = len(out.Leaders) > 0
+
+	return out
+}
+
+// leaders returns the pids with the highest value. If less than 2 values are present no
+// leaders are returned.
+func leaders(pids []int, values []*float64) []int {
+	out := make([]int, 0)
+
+	present := 0
+	var best float64
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		present++
+
+		switch {
+		case len(out) == 0 || *v > best:
+			best, out = *v, append(out[:0], pids[i])
+		case *v == best:
+			out = append(out, pids[i])
+		}
+	}
+
+	if present < 2 {
+		return out[:0]
+	}
+	return out
+}
+
+func periodKey(p csb.Period) string {
+	key := fmt.Sprint(p.AcademicYear)
+	if p.Term != nil {
+		key += fmt.Sprintf(":%v", *p.Term)
+	}
+	if p.Importance != nil {
+		key += ":" + *p.Importance
+	}
+	return key
+}
+
+// lessPeriod orders full periods chronologically, periods in the same term are ordered by
+// importance.
+func lessPeriod(a, b csb.Period) bool {
+	if a.AcademicYear != b.AcademicYear {
+		return a.AcademicYear < b.AcademicYear
+	}
+	if *a.Term != *b.Term {
+		return *a.Term < *b.Term
+	}
+	return *a.Importance < *b.Importance
+}
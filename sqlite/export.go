@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"context"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.ExportService = (*ExportService)(nil)
+
+// ExportService streams students and marks straight from the sqlite cursor.
+type ExportService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewExportService creates a new export service with the provided database.
+func NewExportService(db *DB) *ExportService {
+	return &ExportService{
+		db: db,
+	}
+}
+
+// ExportStudents calls fn for each student matching the filter in pid order.
+func (s *ExportService) ExportStudents(ctx context.Context, filter csb.StudentFilter, fn func(*csb.Student) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return iterStudents(ctx, tx, filter, func(student *csb.Student) (err error) {
+		if student.Subjects, err = findSubjectsByPID(ctx, tx, student.PID); err != nil {
+			return err
+		}
+
+		return fn(student)
+	})
+}
+
+// ExportMarks calls fn for each mark matching the filter in id order.
+func (s *ExportService) ExportMarks(ctx context.Context, filter csb.MarksFilter, fn func(*csb.Mark) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return iterMarks(ctx, tx, filter, fn)
+}
+
+// ExportStudentYears returns the current year group of the students attending the school by
+// pid.
+func (s *ExportService) ExportStudentYears(ctx context.Context) (map[int]int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			pid,
+			current_year
+		FROM students
+		WHERE attends_school = 1 AND current_year IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	years := make(map[int]int)
+	for rows.Next() {
+		var pid, year int
+		if err := rows.Scan(
+			&pid,
+			&year,
+		); err != nil {
+			return nil, err
+		}
+
+		years[pid] = year
+	}
+
+	return years, rows.Err()
+}
@@ -0,0 +1,258 @@
+package sqlite
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.ForecastService = (*ForecastService)(nil)
+
+// minForecastPoints is the least amount of terms a trend can be fitted on, the prediction
+// interval needs at least one degree of freedom.
+const minForecastPoints = 3
+
+// tQuantiles are the 97.5% quantiles of the student t distribution by degrees of freedom,
+// used for the 95% prediction intervals. Past the table the normal quantile is close enough.
+var tQuantiles = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// ForecastService projects the marks of the students from their local history.
+type ForecastService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewForecastService creates a new forecast service with the provided database.
+func NewForecastService(db *DB) *ForecastService {
+	return &ForecastService{
+		db: db,
+	}
+}
+
+// ForecastStudent fits a least squares line per subject and overall on the marks of the
+// student. The terms of the history are numbered in order, so terms without any marks dont
+// count as a step, and marks of different importances in the same term are averaged.
+//
+// The next term is the term after the latest term of the history, the academic year rolls
+// over after the highest term seen in the history.
+func (s *ForecastService) ForecastStudent(ctx context.Context, filter csb.ForecastFilter) (*csb.Forecast, error) {
+	if filter.From != nil {
+		if err := filter.From.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := findStudentByPID(ctx, tx, filter.PID); err != nil {
+		return nil, err
+	}
+
+	marks := make([]*csb.Mark, 0)
+	if err := iterMarks(ctx, tx, csb.MarksFilter{
+		PID:      &filter.PID,
+		Subjects: filter.Subjects,
+	}, func(mark *csb.Mark) error {
+		if filter.From == nil || !before(mark.Period, *filter.From) {
+			marks = append(marks, mark)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// number the terms of the history.
+	terms := make([]forecastTerm, 0)
+	seen := make(map[forecastTerm]struct{})
+	maxTerm := 0
+	for _, mark := range marks {
+		t := forecastTerm{mark.Period.AcademicYear, *mark.Period.Term}
+		if _, ok := seen[t]; !ok {
+			seen[t] = struct{}{}
+			terms = append(terms, t)
+		}
+		if t.term > maxTerm {
+			maxTerm = t.term
+		}
+	}
+	if len(terms) < minForecastPoints {
+		return nil, csb.Errorf(csb.EINVALID, "forecast needs at least %v terms of marks, but got: %v", minForecastPoints, len(terms))
+	}
+	sort.Slice(terms, func(i, j int) bool { return terms[i].less(terms[j]) })
+	index := make(map[forecastTerm]int, len(terms))
+	for i, t := range terms {
+		index[t] = i
+	}
+
+	last := terms[len(terms)-1]
+	next := csb.Period{AcademicYear: last.academicYear + 1, Term: new(int)}
+	*next.Term = 1
+	if last.term < maxTerm {
+		next.AcademicYear, *next.Term = last.academicYear, last.term+1
+	}
+
+	// average the percentages of each term, overall and per subject.
+	overall := newTermSeries()
+	subjects := make(map[int]*termSeries)
+	order := make([]csb.Subject, 0)
+	for _, mark := range marks {
+		x := index[forecastTerm{mark.Period.AcademicYear, *mark.Period.Term}]
+		overall.add(x, mark.Percentage)
+
+		series, ok := subjects[mark.SubjectID]
+		if !ok {
+			series = newTermSeries()
+			subjects[mark.SubjectID] = series
+			order = append(order, mark.Subject)
+		}
+		series.add(x, mark.Percentage)
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].Name < order[j].Name })
+
+	x0 := float64(len(terms))
+	forecast := &csb.Forecast{
+		StudentID: filter.PID,
+		Next:      next,
+		Overall:   overall.fit(x0),
+		Subjects:  make([]*csb.Trend, 0, len(order)),
+	}
+	for _, subject := range order {
+		trend := subjects[subject.ID].fit(x0)
+		if trend == nil {
+			continue
+		}
+
+		subject := subject
+		trend.Subject = &subject
+		forecast.Subjects = append(forecast.Subjects, trend)
+	}
+
+	return forecast, nil
+}
+
+// forecastTerm identifies a term of the history.
+type forecastTerm struct {
+	academicYear int
+	term         int
+}
+
+func (t forecastTerm) less(o forecastTerm) bool {
+	if t.academicYear != o.academicYear {
+		return t.academicYear < o.academicYear
+	}
+	return t.term < o.term
+}
+
+// termSeries accumulates the percentages of each term.
+type termSeries struct {
+	sums   map[int]float64
+	counts map[int]int
+}
+
+func newTermSeries() *termSeries {
+	return &termSeries{
+		sums:   make(map[int]float64),
+		counts: make(map[int]int),
+	}
+}
+
+func (s *termSeries) add(x, percentage int) {
+	s.sums[x] += float64(percentage)
+	s.counts[x]++
+}
+
+// fit fits a least squares line on the term averages and projects it at x0.
+//
+// returns nil if the series has less than minForecastPoints terms.
+func (s *termSeries) fit(x0 float64) *csb.Trend {
+	n := len(s.sums)
+	if n < minForecastPoints {
+		return nil
+	}
+
+	xs, ys := make([]float64, 0, n), make([]float64, 0, n)
+	for x, sum := range s.sums {
+		xs = append(xs, float64(x))
+		ys = append(ys, sum/float64(s.counts[x]))
+	}
+
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx, my = mx/float64(n), my/float64(n)
+
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		sxy += (xs[i] - mx) * (ys[i] - my)
+	}
+	slope := sxy / sxx
+	intercept := my - slope*mx
+
+	var sse float64
+	for i := range xs {
+		r := ys[i] - (intercept + slope*xs[i])
+		sse += r * r
+	}
+	// standard error of the residuals.
+	se := math.Sqrt(sse / float64(n-2))
+	t := tQuantile(n - 2)
+
+	projected := intercept + slope*x0
+	half := t * se * math.Sqrt(1+1/float64(n)+(x0-mx)*(x0-mx)/sxx)
+
+	trend := &csb.Trend{
+		Points:    n,
+		Slope:     slope,
+		Projected: clampPercentage(projected),
+		Lower:     clampPercentage(projected - half),
+		Upper:     clampPercentage(projected + half),
+		Trend:     csb.TrendStable,
+	}
+
+	// the slope is significant if its confidence interval excludes 0.
+	if math.Abs(slope) > t*se/math.Sqrt(sxx) {
+		switch {
+		case slope > 0:
+			trend.Trend = csb.TrendAccelerating
+		case slope < 0:
+			trend.Trend = csb.TrendDeclining
+		}
+	}
+
+	return trend
+}
+
+// tQuantile returns the 97.5% quantile of the student t distribution with df degrees of
+// freedom, at least 1.
+func tQuantile(df int) float64 {
+	if df > len(tQuantiles) {
+		return 1.96
+	}
+	return tQuantiles[df-1]
+}
+
+// before reports wether the period p is before the period o, comparing only the populated
+// fields of o.
+func before(p, o csb.Period) bool {
+	if p.AcademicYear != o.AcademicYear || o.Term == nil {
+		return p.AcademicYear < o.AcademicYear
+	}
+	return *p.Term < *o.Term
+}
+
+func clampPercentage(v float64) float64 {
+	return math.Max(0, math.Min(100, v))
+}
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"math"
+	"testing"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+func TestTQuantile(t *testing.T) {
+	for _, tt := range []struct {
+		df   int
+		want float64
+	}{
+		{1, 12.706},
+		{2, 4.303},
+		{10, 2.228},
+		{30, 2.042},
+		{31, 1.96},
+		{120, 1.96},
+	} {
+		if got := tQuantile(tt.df); got != tt.want {
+			t.Errorf("tQuantile(%v) = %v, want %v", tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestTermSeriesFit(t *testing.T) {
+	type mark struct{ x, percentage int }
+
+	for _, tt := range []struct {
+		name  string
+		marks []mark
+		x0    float64
+		// want is nil if no trend is fitted, its bounds are only checked if they are set.
+		want *csb.Trend
+	}{
+		{
+			name:  "TooFewTerms",
+			marks: []mark{{1, 50}, {2, 60}, {2, 70}},
+			x0:    3,
+		},
+		{
+			name:  "Rising",
+			marks: []mark{{1, 50}, {2, 60}, {3, 70}},
+			x0:    4,
+			want:  &csb.Trend{Points: 3, Slope: 10, Projected: 80, Lower: 80, Upper: 80, Trend: csb.TrendAccelerating},
+		},
+		{
+			name:  "Falling",
+			marks: []mark{{1, 70}, {2, 60}, {3, 50}},
+			x0:    4,
+			want:  &csb.Trend{Points: 3, Slope: -10, Projected: 40, Lower: 40, Upper: 40, Trend: csb.TrendDeclining},
+		},
+		{
+			name:  "Flat",
+			marks: []mark{{1, 60}, {2, 60}, {3, 60}},
+			x0:    4,
+			want:  &csb.Trend{Points: 3, Slope: 0, Projected: 60, Lower: 60, Upper: 60, Trend: csb.TrendStable},
+		},
+		{
+			// the marks of a term are averaged before the fit.
+			name:  "TermAverages",
+			marks: []mark{{1, 40}, {1, 60}, {2, 60}, {3, 65}, {3, 75}},
+			x0:    4,
+			want:  &csb.Trend{Points: 3, Slope: 10, Projected: 80, Lower: 80, Upper: 80, Trend: csb.TrendAccelerating},
+		},
+		{
+			// a rising slope within the noise isnt significant with 1 degree of freedom.
+			name:  "NoisyRising",
+			marks: []mark{{1, 50}, {2, 70}, {3, 55}},
+			x0:    4,
+			want:  &csb.Trend{Points: 3, Slope: 2.5, Projected: 190.0 / 3, Trend: csb.TrendStable},
+		},
+		{
+			name:  "SteadyRising",
+			marks: []mark{{1, 40}, {2, 51}, {3, 59}, {4, 71}, {5, 80}},
+			x0:    6,
+			want:  &csb.Trend{Points: 5, Slope: 10, Projected: 90.2, Trend: csb.TrendAccelerating},
+		},
+		{
+			name:  "Clamped",
+			marks: []mark{{1, 80}, {2, 90}, {3, 100}},
+			x0:    4,
+			want:  &csb.Trend{Points: 3, Slope: 10, Projected: 100, Lower: 100, Upper: 100, Trend: csb.TrendAccelerating},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			series := newTermSeries()
+			for _, m := range tt.marks {
+				series.add(m.x, m.percentage)
+			}
+
+			got := series.fit(tt.x0)
+			switch {
+			case tt.want == nil && got == nil:
+				return
+			case tt.want == nil || got == nil:
+				t.Fatalf("fit() = %+v, want %+v", got, tt.want)
+			}
+
+			if got.Points != tt.want.Points || got.Trend != tt.want.Trend {
+				t.Errorf("fit() = %+v, want %+v", got, tt.want)
+			}
+			if !closeTo(got.Slope, tt.want.Slope) || !closeTo(got.Projected, tt.want.Projected) {
+				t.Errorf("fit() slope and projection = %v, %v, want %v, %v", got.Slope, got.Projected, tt.want.Slope, tt.want.Projected)
+			}
+			if tt.want.Lower != 0 && (!closeTo(got.Lower, tt.want.Lower) || !closeTo(got.Upper, tt.want.Upper)) {
+				t.Errorf("fit() interval = [%v, %v], want [%v, %v]", got.Lower, got.Upper, tt.want.Lower, tt.want.Upper)
+			}
+			if got.Lower > got.Projected || got.Upper < got.Projected {
+				t.Errorf("fit() interval [%v, %v] doesnt contain the projection %v", got.Lower, got.Upper, got.Projected)
+			}
+		})
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
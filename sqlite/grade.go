@@ -0,0 +1,349 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/mattn/go-sqlite3"
+)
+
+var _ csb.GradeService = (*GradeService)(nil)
+
+// scaleSpecificity orders the scales of a family from the most specific to the least.
+const scaleSpecificity = `
+	(grade_scales.subject_id IS NOT NULL) * 4 +
+	(grade_scales.year IS NOT NULL) * 2 +
+	(grade_scales.academic_year IS NOT NULL) DESC,
+	grade_scales.id DESC`
+
+// GradeService stores the grade scales used to derive grades from percentages.
+type GradeService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewGradeService creates a new grade service with the provided database.
+func NewGradeService(db *DB) *GradeService {
+	return &GradeService{
+		db: db,
+	}
+}
+
+// FindGradeScaleByID returns a grade scale based on the passed id.
+//
+// returns ENOTFOUND if the scale isnt found.
+func (s *GradeService) FindGradeScaleByID(ctx context.Context, id int) (*csb.GradeScale, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findGradeScaleByID(ctx, tx, id)
+}
+
+// FindGradeScales returns a range of grade scales based on filter.
+func (s *GradeService) FindGradeScales(ctx context.Context, filter csb.GradeScaleFilter) ([]*csb.GradeScale, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findGradeScales(ctx, tx, filter)
+}
+
+// CreateGradeScale creates a new grade scale.
+//
+// returns ECONFLICT if the family already has a scale with the same scope.
+func (s *GradeService) CreateGradeScale(ctx context.Context, scale *csb.GradeScale) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createGradeScale(ctx, tx, scale); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteGradeScale permanently deletes a grade scale.
+//
+// returns ENOTFOUND if the scale isnt found.
+func (s *GradeService) DeleteGradeScale(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := findGradeScaleByID(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM grade_scales WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func findGradeScaleByID(ctx context.Context, tx *Tx, id int) (*csb.GradeScale, error) {
+	row := tx.QueryRowContext(ctx, `
+		SELECT
+			id,
+			name,
+			subject_id,
+			year,
+			academic_year,
+			created_at
+		FROM grade_scales
+		WHERE id = ?
+	`,
+		id,
+	)
+
+	scale, err := scanGradeScale(row)
+	if err == sql.ErrNoRows {
+		return nil, csb.Errorf(csb.ENOTFOUND, "grade scale not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := attachGradeBoundaries(ctx, tx, scale); err != nil {
+		return nil, err
+	}
+	return scale, nil
+}
+
+func findGradeScales(ctx context.Context, tx *Tx, filter csb.GradeScaleFilter) ([]*csb.GradeScale, error) {
+	// prepare where clause.
+	where, args := []string{"1=1"}, []interface{}{}
+	if v := filter.Name; v != nil {
+		where = append(where, "name = ?")
+		args = append(args, *v)
+	}
+	if v := filter.SubjectID; v != nil {
+		where = append(where, "subject_id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Year; v != nil {
+		where = append(where, "year = ?")
+		args = append(args, *v)
+	}
+	if v := filter.AcademicYear; v != nil {
+		where = append(where, "academic_year = ?")
+		args = append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			name,
+			subject_id,
+			year,
+			academic_year,
+			created_at
+		FROM grade_scales
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY name ASC, id ASC
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	scales := make([]*csb.GradeScale, 0)
+	for rows.Next() {
+		scale, err := scanGradeScale(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		scales = append(scales, scale)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, scale := range scales {
+		if err := attachGradeBoundaries(ctx, tx, scale); err != nil {
+			return nil, err
+		}
+	}
+
+	return scales, nil
+}
+
+// findGradeScaleInScope returns the most specific scale of the family in scope of the
+// subject, year group and academic year. nil scope arguments only match unscoped scales.
+//
+// returns ENOTFOUND if the family has no scale in scope.
+func findGradeScaleInScope(ctx context.Context, tx *Tx, name string, subjectID, year, academicYear *int) (*csb.GradeScale, error) {
+	row := tx.QueryRowContext(ctx, `
+		SELECT id
+		FROM grade_scales
+		WHERE name = ?
+		AND (subject_id IS NULL OR subject_id = ?)
+		AND (year IS NULL OR year = ?)
+		AND (academic_year IS NULL OR academic_year = ?)
+		ORDER BY `+scaleSpecificity+`
+		LIMIT 1
+	`,
+		name,
+		subjectID,
+		year,
+		academicYear,
+	)
+
+	var id int
+	if err := row.Scan(&id); err == sql.ErrNoRows {
+		return nil, csb.Errorf(csb.ENOTFOUND, "no %v grade scale in scope", name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return findGradeScaleByID(ctx, tx, id)
+}
+
+func createGradeScale(ctx context.Context, tx *Tx, scale *csb.GradeScale) error {
+	if err := scale.Validate(); err != nil {
+		return err
+	}
+
+	scale.Sort()
+	scale.CreatedAt = time.Now()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO grade_scales (
+			name,
+			subject_id,
+			year,
+			academic_year,
+			created_at
+		) VALUES (?, ?, ?, ?, ?)
+	`,
+		scale.Name,
+		scale.SubjectID,
+		scale.Year,
+		scale.AcademicYear,
+		scale.CreatedAt,
+	)
+	if isUniqueConstraint(err) {
+		return csb.Errorf(csb.ECONFLICT, "%v already has a grade scale with the same scope", scale.Name)
+	} else if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	scale.ID = int(id)
+
+	for _, b := range scale.Boundaries {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO grade_boundaries (
+				scale_id,
+				grade,
+				min_percentage
+			) VALUES (?, ?, ?)
+		`,
+			scale.ID,
+			b.Grade,
+			b.MinPercentage,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func attachGradeBoundaries(ctx context.Context, tx *Tx, scale *csb.GradeScale) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			grade,
+			min_percentage
+		FROM grade_boundaries
+		WHERE scale_id = ?
+		ORDER BY min_percentage DESC
+	`,
+		scale.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	scale.Boundaries = make([]csb.GradeBoundary, 0)
+	for rows.Next() {
+		var b csb.GradeBoundary
+		if err := rows.Scan(&b.Grade, &b.MinPercentage); err != nil {
+			return err
+		}
+
+		scale.Boundaries = append(scale.Boundaries, b)
+	}
+
+	return rows.Err()
+}
+
+// markGradeExpr returns an expression evaluating to the grade of the row of the marks table
+// on the most specific scale of the family in scope of the mark, the year group of the student
+// is the one of the academic year of the mark. The expression evaluates to NULL if the family
+// has no scale in scope or the percentage is under all boundaries.
+func markGradeExpr(name string) (string, []interface{}) {
+	return `(
+		SELECT grade_boundaries.grade
+		FROM grade_boundaries
+		WHERE grade_boundaries.scale_id = (
+			SELECT grade_scales.id
+			FROM grade_scales
+			WHERE grade_scales.name = ?
+			AND (grade_scales.subject_id IS NULL OR grade_scales.subject_id = marks.subject_id)
+			AND (grade_scales.year IS NULL OR grade_scales.year = (SELECT current_year - (? - marks.academic_year) FROM students WHERE pid = marks.student_id))
+			AND (grade_scales.academic_year IS NULL OR grade_scales.academic_year = marks.academic_year)
+			ORDER BY ` + scaleSpecificity + `
+			LIMIT 1
+		)
+		AND grade_boundaries.min_percentage <= marks.percentage
+		ORDER BY grade_boundaries.min_percentage DESC
+		LIMIT 1
+	)`, []interface{}{name, csb.CurrentAcademicYear}
+}
+
+func scanGradeScale(s scanner) (*csb.GradeScale, error) {
+	var scale csb.GradeScale
+	var subjectID, year, academicYear sql.NullInt64
+	if err := s.Scan(
+		&scale.ID,
+		&scale.Name,
+		&subjectID,
+		&year,
+		&academicYear,
+		&scale.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	scale.SubjectID = nullIntPtr(subjectID)
+	scale.Year = nullIntPtr(year)
+	scale.AcademicYear = nullIntPtr(academicYear)
+	return &scale, nil
+}
+
+// isUniqueConstraint reports wether err is a sqlite unique constraint violation.
+func isUniqueConstraint(err error) bool {
+	e, ok := err.(sqlite3.Error)
+	return ok && e.ExtendedCode == sqlite3.ErrConstraintUnique
+}
@@ -0,0 +1,305 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.ImportService = (*ImportService)(nil)
+
+// ImportService imports marks which never reached engage in batches.
+type ImportService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewImportService creates a new import service with the provided database.
+func NewImportService(db *DB) *ImportService {
+	return &ImportService{
+		db: db,
+	}
+}
+
+// ImportMarks validates every row and imports all of them in one transaction tagged with a
+// new import batch. Imported marks are evaluated against the alert rules like any other new
+// mark.
+//
+// If any row is invalid or the import is a dry run, nothing is written.
+func (s *ImportService) ImportMarks(ctx context.Context, imp csb.MarkImport) (*csb.ImportReport, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &csb.ImportReport{
+		DryRun: imp.DryRun,
+		Errors: make([]csb.ImportRowError, 0),
+	}
+
+	// validate all the rows before inserting anything.
+	marks := make([]*csb.Mark, 0, len(imp.Rows))
+	seen := make(map[string]int, len(imp.Rows))
+	for _, row := range imp.Rows {
+		mark, err := validateImportRow(ctx, tx, row)
+		if err != nil {
+			if !isRowError(err) {
+				return nil, err
+			}
+
+			report.Errors = append(report.Errors, csb.ImportRowError{Line: row.Line, Message: rowErrorMessage(err)})
+			continue
+		}
+
+		// marks in the same period can only have different subjects.
+		key := fmt.Sprintf("%v:%v:%v:%v:%v", mark.StudentID, mark.SubjectID, mark.Period.AcademicYear, *mark.Period.Term, *mark.Period.Importance)
+		if line, ok := seen[key]; ok {
+			report.Errors = append(report.Errors, csb.ImportRowError{Line: row.Line, Message: fmt.Sprintf("duplicate of line %v", line)})
+			continue
+		}
+		seen[key] = row.Line
+
+		marks = append(marks, mark)
+	}
+	report.Valid = len(marks)
+
+	if imp.DryRun || len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	batch := &csb.ImportBatch{Rows: len(marks)}
+	if err := createImportBatch(ctx, tx, batch); err != nil {
+		return nil, err
+	}
+
+	for _, mark := range marks {
+		mark.ImportBatchID = &batch.ID
+		if err := createMark(ctx, tx, mark); err != nil {
+			return nil, err
+		}
+
+		if err := evaluateAlerts(ctx, tx, mark); err != nil {
+			return nil, err
+		}
+	}
+	report.Batch = batch
+
+	return report, tx.Commit()
+}
+
+// FindImportBatchByID returns an import batch based on the passed id.
+//
+// returns ENOTFOUND if the batch isnt found.
+func (s *ImportService) FindImportBatchByID(ctx context.Context, id int) (*csb.ImportBatch, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findImportBatchByID(ctx, tx, id)
+}
+
+// FindImportBatches returns all the import batches, newest first.
+func (s *ImportService) FindImportBatches(ctx context.Context) ([]*csb.ImportBatch, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			row_count,
+			created_at,
+			rolled_back_at
+		FROM import_batches
+		ORDER BY id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := make([]*csb.ImportBatch, 0)
+	for rows.Next() {
+		batch, err := scanImportBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		batches = append(batches, batch)
+	}
+
+	return batches, rows.Err()
+}
+
+// RollbackImportBatch permanently deletes the marks imported with the batch. The batch
+// itself is kept to record the rollback.
+//
+// returns ENOTFOUND if the batch isnt found and ECONFLICT if it was already rolled back.
+func (s *ImportService) RollbackImportBatch(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	batch, err := findImportBatchByID(ctx, tx, id)
+	if err != nil {
+		return err
+	} else if batch.RolledBackAt != nil {
+		return csb.Errorf(csb.ECONFLICT, "import batch already rolled back")
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM marks WHERE import_batch_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if err := createAuditEntry(ctx, tx, csb.AuditDelete, csb.AuditImportBatch, id, map[string]int64{"marks": n}, nil); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE import_batches SET rolled_back_at = ? WHERE id = ?`, time.Now(), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// validateImportRow resolves the student and subject of the row and validates the resulting
+// mark.
+func validateImportRow(ctx context.Context, tx *Tx, row csb.MarkImportRow) (*csb.Mark, error) {
+	if _, err := findStudentByPID(ctx, tx, row.PID); err != nil {
+		return nil, err
+	}
+
+	// the subject is either an engage code or a name.
+	subject, err := findSubjectByEngageCode(ctx, tx, row.Subject)
+	if csb.ErrorCode(err) == csb.ENOTFOUND {
+		subject, err = findSubjectByName(ctx, tx, row.Subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mark := &csb.Mark{
+		StudentID:  row.PID,
+		SubjectID:  subject.ID,
+		Subject:    subject,
+		Teacher:    row.Teacher,
+		Percentage: row.Percentage,
+		Period:     row.Period,
+	}
+	if err := mark.Validate(); err != nil {
+		return nil, err
+	}
+	if mark.Percentage < 0 || mark.Percentage > 100 {
+		return nil, csb.Errorf(csb.EINVALID, "percentage must be between 0 and 100 inclusive, but got: %v", mark.Percentage)
+	}
+
+	existing, err := findMarks(ctx, tx, csb.MarksFilter{
+		PID:      &mark.StudentID,
+		Periods:  []csb.Period{mark.Period},
+		Subjects: []csb.Subject{subject},
+	})
+	if err != nil {
+		return nil, err
+	} else if len(existing) > 0 {
+		return nil, csb.Errorf(csb.ECONFLICT, "student already has a %v mark in this period", subject.Name)
+	}
+
+	return mark, nil
+}
+
+func findImportBatchByID(ctx context.Context, tx *Tx, id int) (*csb.ImportBatch, error) {
+	row := tx.QueryRowContext(ctx, `
+		SELECT
+			id,
+			row_count,
+			created_at,
+			rolled_back_at
+		FROM import_batches
+		WHERE id = ?
+	`,
+		id,
+	)
+
+	batch, err := scanImportBatch(row)
+	if err == sql.ErrNoRows {
+		return nil, csb.Errorf(csb.ENOTFOUND, "import batch not found")
+	}
+
+	return batch, err
+}
+
+func createImportBatch(ctx context.Context, tx *Tx, batch *csb.ImportBatch) error {
+	batch.CreatedAt = time.Now()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO import_batches (
+			row_count,
+			created_at
+		) VALUES (?, ?)
+	`,
+		batch.Rows,
+		batch.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	batch.ID = int(id)
+
+	return nil
+}
+
+func scanImportBatch(s scanner) (*csb.ImportBatch, error) {
+	var batch csb.ImportBatch
+	var rolledBackAt sql.NullTime
+	if err := s.Scan(
+		&batch.ID,
+		&batch.Rows,
+		&batch.CreatedAt,
+		&rolledBackAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if rolledBackAt.Valid {
+		batch.RolledBackAt = &rolledBackAt.Time
+	}
+	return &batch, nil
+}
+
+// isRowError reports wether err was caused by the row itself and not by the database.
+func isRowError(err error) bool {
+	switch csb.ErrorCode(err) {
+	case csb.EINVALID, csb.ENOTFOUND, csb.ECONFLICT:
+		return true
+	}
+	return false
+}
+
+// rowErrorMessage returns the human-readable message of a row error.
+func rowErrorMessage(err error) string {
+	var e *csb.Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	return err.Error()
+}
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 
 	csb "github.com/Lambels/CSB-Open-API"
@@ -33,11 +34,15 @@ func NewMarkService(db *DB, fallback bool, client *engage.Client, periodService
 	}
 }
 
-// FindMarkByID returns a marked based on the passed id.
+// FindMarkByID returns a marked based on the passed id with the included associations.
 //
 // returns ENOTFOUND if the mark isnt found.
-func (s *MarkService) FindMarkByID(ctx context.Context, id int) (*csb.Mark, error) {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+func (s *MarkService) FindMarkByID(ctx context.Context, id int, include csb.Include) (*csb.Mark, error) {
+	if err := include.Validate(csb.MarkAssociations...); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -46,7 +51,7 @@ func (s *MarkService) FindMarkByID(ctx context.Context, id int) (*csb.Mark, erro
 	mark, err := findMarkByID(ctx, tx, id)
 	if err != nil {
 		return nil, err
-	} else if err := attachMarkAssociations(ctx, tx, mark); err != nil {
+	} else if err := attachMarkAssociations(ctx, tx, mark, include); err != nil {
 		return nil, err
 	}
 
@@ -57,7 +62,7 @@ func (s *MarkService) FindMarkByID(ctx context.Context, id int) (*csb.Mark, erro
 //
 // find marks only fetches local marks. To get all marks, use refresh handler.
 func (s *MarkService) FindMarksByPID(ctx context.Context, pid int) ([]*csb.Mark, error) {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +87,7 @@ func (s *MarkService) FindMarksByPID(ctx context.Context, pid int) ([]*csb.Mark,
 //
 // If the period isnt full, the request will simply provide the local data.
 func (s *MarkService) FindMarksByPeriod(ctx context.Context, pid int, period csb.Period) (marks []*csb.Mark, err error) {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +126,7 @@ func (s *MarkService) FindMarksByPeriod(ctx context.Context, pid int, period csb
 //
 // All the data will be fetched from local storage.
 func (s *MarkService) FindMarksByPeriodRange(ctx context.Context, from, to csb.Period, filter csb.MarksFilter) (_ []*csb.Mark, err error) {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +140,7 @@ func (s *MarkService) FindMarksByPeriodRange(ctx context.Context, from, to csb.P
 		mark, err := findMarkByID(ctx, tx, *filter.ID)
 		if err != nil {
 			return nil, err
-		} else if err := attachMarkAssociations(ctx, tx, mark); err != nil {
+		} else if err := attachMarkAssociations(ctx, tx, mark, csb.Include{"student"}); err != nil {
 			return nil, err
 		}
 
@@ -156,33 +161,38 @@ func (s *MarkService) FindMarksByPeriodRange(ctx context.Context, from, to csb.P
 	return marks, nil
 }
 
-// FindMarks returns a range of marks based on filter.
-func (s *MarkService) FindMarks(ctx context.Context, filter csb.MarksFilter) ([]*csb.Mark, error) {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+// FindMarks returns a page of marks based on filter with the included associations. Pages
+// without a limit have csb.DefaultPageSize marks and limits are capped to csb.MaxPageSize.
+func (s *MarkService) FindMarks(ctx context.Context, filter csb.MarksFilter) ([]*csb.Mark, csb.Page, error) {
+	if err := filter.Include.Validate(csb.MarkAssociations...); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, csb.Page{}, err
 	}
 	defer tx.Rollback()
 
-	marks, err := findMarks(ctx, tx, filter)
+	marks, page, err := findMarksPage(ctx, tx, filter)
 	if err != nil {
-		return nil, err
+		return nil, csb.Page{}, err
 	}
 
 	for _, mark := range marks {
-		if err := attachMarkAssociations(ctx, tx, mark); err != nil {
-			return nil, err
+		if err := attachMarkAssociations(ctx, tx, mark, filter.Include); err != nil {
+			return nil, csb.Page{}, err
 		}
 	}
 
-	return marks, nil
+	return marks, page, nil
 }
 
 // DeleteMark permanently deletes a mark with the specified id.
 //
 // returns ENOTFOUND if the mark isnt found.
 func (s *MarkService) DeleteMark(ctx context.Context, id int) error {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -202,7 +212,7 @@ func (s *MarkService) DeleteMark(ctx context.Context, id int) error {
 func (s *MarkService) RefreshMarks(ctx context.Context, pid int, from, to csb.Period) error {
 	// asign student manualy since we are again potentially dealing with allot of marks
 	// and dont want to spam engage or the local database.
-	tx, err := s.db.db.BeginTx(ctx, nil)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -225,7 +235,7 @@ func (s *MarkService) RefreshMarks(ctx context.Context, pid int, from, to csb.Pe
 			return err
 		}
 
-		marksEngage, err := s.findMarksByFullPeriodEngage(ctx, tx, pid, period)
+		marksEngage, err := s.findMarksByFullPeriodEngage(ctx, pid, period)
 		if err != nil {
 			return err
 		} else if err := attachMarksSubjectsWithStudent(ctx, tx, pid, marksEngage); err != nil { // attach subjects to engage marks to be able to make a shallow difference in createDiff. (SubjectID field needed)
@@ -244,10 +254,10 @@ func (s *MarkService) RefreshMarks(ctx context.Context, pid int, from, to csb.Pe
 		periods = periods[1:]
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func findMarkByID(ctx context.Context, tx *sql.Tx, id int) (*csb.Mark, error) {
+func findMarkByID(ctx context.Context, tx *Tx, id int) (*csb.Mark, error) {
 	m, err := findMarks(ctx, tx, csb.MarksFilter{ID: &id})
 	if err != nil {
 		return nil, err
@@ -258,7 +268,7 @@ func findMarkByID(ctx context.Context, tx *sql.Tx, id int) (*csb.Mark, error) {
 	return m[0], nil
 }
 
-func findMarksByPID(ctx context.Context, tx *sql.Tx, pid int) ([]*csb.Mark, error) {
+func findMarksByPID(ctx context.Context, tx *Tx, pid int) ([]*csb.Mark, error) {
 	m, err := findMarks(ctx, tx, csb.MarksFilter{PID: &pid})
 	if err != nil {
 		return nil, err
@@ -267,46 +277,16 @@ func findMarksByPID(ctx context.Context, tx *sql.Tx, pid int) ([]*csb.Mark, erro
 	return m, nil
 }
 
-func (s *MarkService) findMarksByFullPeriodEngage(ctx context.Context, tx *sql.Tx, pid int, period csb.Period) ([]*csb.Mark, error) {
+func (s *MarkService) findMarksByFullPeriodEngage(ctx context.Context, pid int, period csb.Period) ([]*csb.Mark, error) {
 	engageTerm, err := s.periodService.PeriodToEngageTerm(ctx, pid, period)
 	if err != nil {
 		return nil, err
 	}
 
-	bufResp, err := s.c.GetMarksheetRender(
-		ctx,
-		pid,
-		[]int{period.AcademicYear},
-		[]string{engageTerm},
-		[]string{*period.Importance},
-		nil,
-	)
-
-	out := make([]*csb.Mark, 0)
-
-	// first itteration, do not accept any error.
-	mark, n, err := engage.GetMarkFromRender(bufResp)
-	if err != nil {
-		return nil, err
-	}
-	out = append(out, mark)
-	bufResp = bufResp[n:]
-
-	for {
-		mark, n, err := engage.GetMarkFromRender(bufResp)
-		switch csb.ErrorCode(err) {
-		case "":
-			out = append(out, mark)
-			bufResp = bufResp[n:]
-		case csb.ENOTFOUND: // sentinel error, treated as EOF.
-			return out, nil
-		default: // non EOF error not accepted.
-			return nil, err
-		}
-	}
+	return s.c.FindMarks(ctx, pid, period, engageTerm)
 }
 
-func findMarksByFullPeriod(ctx context.Context, tx *sql.Tx, pid int, period csb.Period) ([]*csb.Mark, error) {
+func findMarksByFullPeriod(ctx context.Context, tx *Tx, pid int, period csb.Period) ([]*csb.Mark, error) {
 	m, err := findMarks(ctx, tx, csb.MarksFilter{PID: &pid, Periods: []csb.Period{period}})
 	if err != nil {
 		return nil, err
@@ -315,14 +295,14 @@ func findMarksByFullPeriod(ctx context.Context, tx *sql.Tx, pid int, period csb.
 	return m, nil
 }
 
-func (s *MarkService) findMarksByFullPeriodFallback(ctx context.Context, tx *sql.Tx, pid int, period csb.Period) (marks []*csb.Mark, err error) {
+func (s *MarkService) findMarksByFullPeriodFallback(ctx context.Context, tx *Tx, pid int, period csb.Period) (marks []*csb.Mark, err error) {
 	marks, err = findMarksByFullPeriod(ctx, tx, pid, period)
 	if err != nil {
 		return nil, err
 	}
 
 	if s.fallback {
-		engageMarks, err := s.findMarksByFullPeriodEngage(ctx, tx, pid, period)
+		engageMarks, err := s.findMarksByFullPeriodEngage(ctx, pid, period)
 		if err != nil {
 			return nil, err
 		} else if err := attachMarksSubjectsWithStudent(ctx, tx, pid, engageMarks); err != nil {
@@ -338,55 +318,298 @@ func (s *MarkService) findMarksByFullPeriodFallback(ctx context.Context, tx *sql
 	return marks, nil
 }
 
-func findMarks(ctx context.Context, tx *sql.Tx, filter csb.MarksFilter) ([]*csb.Mark, error) {
+func findMarks(ctx context.Context, tx *Tx, filter csb.MarksFilter) ([]*csb.Mark, error) {
+	marks := make([]*csb.Mark, 0)
+	err := iterMarks(ctx, tx, filter, func(mark *csb.Mark) error {
+		marks = append(marks, mark)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	return marks, nil
 }
 
-func deleteMark(ctx context.Context, tx *sql.Tx, id int) error {
-	if _, err := findMarkByID(ctx, tx, id); err != nil {
+// findMarksPage returns the page of the marks matching the filter requested by the sort,
+// limit, offset and cursor of the filter.
+func findMarksPage(ctx context.Context, tx *Tx, filter csb.MarksFilter) ([]*csb.Mark, csb.Page, error) {
+	p, err := newPage(markSorts, "id", filter.Sort, filter.Limit, filter.Offset, filter.Cursor)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	where, args, err := marksWhere(filter)
+	if err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	var page csb.Page
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM marks WHERE `+where, args...).Scan(&page.Total); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	marks := make([]*csb.Mark, 0, p.Limit+1)
+	if err := queryMarks(ctx, tx, filter, p, func(mark *csb.Mark) error {
+		marks = append(marks, mark)
+		return nil
+	}); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	// the extra mark only tells that there is a next page.
+	if len(marks) > p.Limit {
+		marks = marks[:p.Limit]
+
+		page.NextCursor = p.Cursor(csb.MarkSortKeys[p.Field](marks[len(marks)-1]))
+	}
+
+	return marks, page, nil
+}
+
+// iterMarks calls fn for each mark matching the filter in id order, straight from the
+// cursor. The subject of each mark is populated. Iteration stops on the first error returned
+// by fn.
+//
+// The sort, limit, offset and cursor of the filter are ignored, all the marks are iterated.
+func iterMarks(ctx context.Context, tx *Tx, filter csb.MarksFilter, fn func(*csb.Mark) error) error {
+	return queryMarks(ctx, tx, filter, nil, fn)
+}
+
+// queryMarks calls fn for each mark matching the filter in the page, all the marks in id
+// order if p is nil.
+func queryMarks(ctx context.Context, tx *Tx, filter csb.MarksFilter, p *page, fn func(*csb.Mark) error) error {
+	where, whereArgs, err := marksWhere(filter)
+	if err != nil {
 		return err
 	}
 
-	_, err := tx.ExecContext(ctx, `DELETE FROM marks WHERE id = ?`, id)
-	return err
+	orderBy, limit := "marks.id ASC", ""
+	if p != nil {
+		cond, condArgs := p.where()
+		where += " AND " + cond
+		whereArgs = append(whereArgs, condArgs...)
+		orderBy = p.orderBy()
+
+		// query one more mark to tell if there is a next page.
+		limit = "LIMIT ? OFFSET ?"
+		whereArgs = append(whereArgs, p.Limit+1, p.Offset)
+	}
+
+	// derive the grade of each mark only if a grade scale was requested.
+	grade, args := "NULL", []interface{}{}
+	if v := filter.GradeScale; v != nil {
+		grade, args = markGradeExpr(*v)
+	}
+	args = append(args, whereArgs...)
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			marks.id,
+			marks.student_id,
+			marks.subject_id,
+			subjects.engage_code,
+			subjects.name,
+			marks.teacher,
+			marks.teacher_id,
+			marks.percentage,
+			`+grade+`,
+			marks.academic_year,
+			marks.term,
+			marks.importance,
+			marks.import_batch_id,
+			marks.created_at
+		FROM marks
+		JOIN subjects ON subjects.id = marks.subject_id
+		WHERE `+where+`
+		ORDER BY `+orderBy+`
+		`+limit+`
+	`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mark csb.Mark
+		var term int
+		var importance string
+		var grade sql.NullString
+		var teacherID, importBatchID sql.NullInt64
+		if err := rows.Scan(
+			&mark.ID,
+			&mark.StudentID,
+			&mark.SubjectID,
+			&mark.Subject.EngageCode,
+			&mark.Subject.Name,
+			&mark.Teacher,
+			&teacherID,
+			&mark.Percentage,
+			&grade,
+			&mark.Period.AcademicYear,
+			&term,
+			&importance,
+			&importBatchID,
+			&mark.CreatedAt,
+		); err != nil {
+			return err
+		}
+		mark.Subject.ID = mark.SubjectID
+		mark.Period.Term, mark.Period.Importance = &term, &importance
+		mark.TeacherID = int(teacherID.Int64)
+		mark.Grade = grade.String
+		mark.ImportBatchID = nullIntPtr(importBatchID)
+
+		if err := fn(&mark); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// marksWhere builds the where clause and its arguments for the marks filter.
+//
+// returns EINVALID if the filter has a grade but no grade scale.
+func marksWhere(filter csb.MarksFilter) (string, []interface{}, error) {
+	where, args := []string{"1=1"}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where = append(where, "marks.id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.PID; v != nil {
+		where = append(where, "marks.student_id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Teacher; v != nil {
+		where = append(where, "marks.teacher_id IN (SELECT teacher_id FROM teacher_aliases WHERE alias = ?)")
+		args = append(args, csb.NormalizeTeacherName(*v))
+	}
+	if v := filter.TeacherID; v != nil {
+		where = append(where, "marks.teacher_id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.MinPercentage; v != nil {
+		where = append(where, "marks.percentage >= ?")
+		args = append(args, *v)
+	}
+	if v := filter.MaxPercentage; v != nil {
+		where = append(where, "marks.percentage <= ?")
+		args = append(args, *v)
+	}
+	if v := filter.Grade; v != nil {
+		if filter.GradeScale == nil {
+			return "", nil, csb.Errorf(csb.EINVALID, "cannot filter on grade without a grade scale")
+		}
+
+		grade, gradeArgs := markGradeExpr(*filter.GradeScale)
+		where = append(where, grade+" = ?")
+		args = append(append(args, gradeArgs...), *v)
+	}
+
+	// only filter on the populated period fields.
+	if len(filter.Periods) > 0 {
+		periods := make([]string, 0, len(filter.Periods))
+		for _, period := range filter.Periods {
+			cond := "marks.academic_year = ?"
+			args = append(args, period.AcademicYear)
+			if period.Term != nil {
+				cond += " AND marks.term = ?"
+				args = append(args, *period.Term)
+			}
+			if period.Importance != nil {
+				cond += " AND marks.importance = ?"
+				args = append(args, *period.Importance)
+			}
+			periods = append(periods, "("+cond+")")
+		}
+		where = append(where, "("+strings.Join(periods, " OR ")+")")
+	}
+
+	if len(filter.Subjects) > 0 {
+		subjects := make([]string, 0, len(filter.Subjects))
+		for _, subject := range filter.Subjects {
+			cond, arg := subjectCondition(subject)
+			subjects = append(subjects, "("+cond+")")
+			args = append(args, arg)
+		}
+		where = append(where, "marks.subject_id IN (SELECT id FROM subjects WHERE "+strings.Join(subjects, " OR ")+")")
+	}
+
+	return strings.Join(where, " AND "), args, nil
+}
+
+func deleteMark(ctx context.Context, tx *Tx, id int) error {
+	mark, err := findMarkByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM marks WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return createAuditEntry(ctx, tx, csb.AuditDelete, csb.AuditMark, id, summarizeMark(mark), nil)
 }
 
-func createMark(ctx context.Context, tx *sql.Tx, mark *csb.Mark) error {
+func createMark(ctx context.Context, tx *Tx, mark *csb.Mark) error {
 	if err := mark.Validate(); err != nil {
 		return err
 	}
 
+	teacherID, err := resolveTeacher(ctx, tx, mark.Teacher)
+	if err != nil {
+		return err
+	}
+	mark.TeacherID = teacherID
 	mark.CreatedAt = time.Now()
 
-	_, err := tx.ExecContext(ctx, `
+	res, err := tx.ExecContext(ctx, `
 		INSERT INTO marks (
 			student_id,
 			subject_id,
 			teacher,
+			teacher_id,
 			percentage,
 			academic_year,
 			term,
 			importance,
-			created_at,
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			import_batch_id,
+			created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		mark.StudentID,
 		mark.SubjectID,
 		mark.Teacher,
+		mark.TeacherID,
 		mark.Percentage,
 		mark.Period.AcademicYear,
 		mark.Period.Term,
 		mark.Period.Importance,
+		mark.ImportBatchID,
 		mark.CreatedAt,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	mark.ID = int(id)
+
+	return createAuditEntry(ctx, tx, csb.AuditCreate, csb.AuditMark, mark.ID, nil, summarizeMark(mark))
 }
 
-func createDiff(ctx context.Context, tx *sql.Tx, local, engage []*csb.Mark) error {
+func createDiff(ctx context.Context, tx *Tx, local, engage []*csb.Mark) error {
 	// marks in the same period can only have different subjects, do a shallow difference
 	// check on only the subjects to save cpu usage.
 	//
-	// also just add new marks, it isnt often that marks get deleted or updated.
+	// also just add new marks, it isnt often that marks get deleted or updated. every new mark
+	// is evaluated against the alert rules.
 	diff := make(map[int]struct{}, len(local))
 	for _, markLocal := range local {
 		diff[markLocal.SubjectID] = struct{}{}
@@ -397,22 +620,30 @@ func createDiff(ctx context.Context, tx *sql.Tx, local, engage []*csb.Mark) erro
 			if err := createMark(ctx, tx, markEngage); err != nil {
 				return err
 			}
+
+			if err := evaluateAlerts(ctx, tx, markEngage); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
-func attachMarkAssociations(ctx context.Context, tx *sql.Tx, mark *csb.Mark) (err error) {
-	mark.Student, err = findStudentByPID(ctx, tx, mark.StudentID)
-	if err != nil {
-		return err
+// attachMarkAssociations attaches the included associations of the mark. The subject is
+// already read with the mark.
+func attachMarkAssociations(ctx context.Context, tx *Tx, mark *csb.Mark, include csb.Include) (err error) {
+	if !include.Has("student") {
+		return nil
 	}
 
-	return attachMarkSubject(ctx, tx, mark)
+	if mark.Student, err = findStudentByPID(ctx, tx, mark.StudentID); err != nil {
+		return err
+	}
+	return attachStudentAssociations(ctx, tx, mark.Student, include.Nested("student"))
 }
 
-func attachMarksSubjectsWithStudent(ctx context.Context, tx *sql.Tx, pid int, marks []*csb.Mark) (err error) {
+func attachMarksSubjectsWithStudent(ctx context.Context, tx *Tx, pid int, marks []*csb.Mark) (err error) {
 	student, err := findStudentByPID(ctx, tx, pid)
 	if err != nil {
 		return err
@@ -428,7 +659,7 @@ func attachMarksSubjectsWithStudent(ctx context.Context, tx *sql.Tx, pid int, ma
 	return nil
 }
 
-func attachMarkSubject(ctx context.Context, tx *sql.Tx, mark *csb.Mark) (err error) {
+func attachMarkSubject(ctx context.Context, tx *Tx, mark *csb.Mark) (err error) {
 	switch {
 	// have name, want engage code + id.
 	case mark.Subject.Name != "":
@@ -0,0 +1,484 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/trace"
+)
+
+// anonymizedName replaces the name of the anonymized students.
+const anonymizedName = "Anonymized"
+
+// maxSurrogateAttempts caps the random surrogate pupil ids drawn for an anonymized student
+// before giving up on conflicts.
+const maxSurrogateAttempts = 10
+
+var _ csb.PrivacyService = (*PrivacyService)(nil)
+
+// PrivacyService handles the retention of the data of former students and the data protection
+// requests of the students.
+type PrivacyService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewPrivacyService creates a new privacy service with the provided database.
+func NewPrivacyService(db *DB) *PrivacyService {
+	return &PrivacyService{
+		db: db,
+	}
+}
+
+// Schedule applies the policies in order every interval until the context is done. Failed
+// applications are logged and retried on the next interval.
+func (s *PrivacyService) Schedule(ctx context.Context, interval time.Duration, policies ...csb.RetentionPolicy) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, policy := range policies {
+				if _, err := s.ApplyRetention(ctx, policy); err != nil {
+					trace.LogError(ctx, "scheduled retention failed", err, trace.Fields{
+						"component": "sqlite",
+						"action":    policy.Action,
+						"years":     policy.Years,
+					})
+					break
+				}
+			}
+		}
+	}
+}
+
+// ApplyRetention takes the action of the policy on the students who left the school more than
+// policy.Years ago, all the students are handled in one transaction.
+//
+// Anonymized students get a random negative surrogate pupil id and their name is replaced,
+// their alert rules and alerts are deleted. The anonymized students are only deleted by a
+// policy deleting the data.
+func (s *PrivacyService) ApplyRetention(ctx context.Context, policy csb.RetentionPolicy) (*csb.RetentionReport, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	pids, err := findExpiredPIDs(ctx, tx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &csb.RetentionReport{Policy: policy, Erasures: make([]*csb.ErasureRequest, 0, len(pids))}
+	for _, pid := range pids {
+		req := &csb.ErasureRequest{
+			PID:    pid,
+			Action: policy.Action,
+			Reason: fmt.Sprintf("retention: left the school more than %v years ago", policy.Years),
+		}
+
+		switch policy.Action {
+		case csb.RetentionAnonymize:
+			err = anonymizeStudent(ctx, tx, req)
+		default:
+			err = eraseStudent(ctx, tx, req)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		report.Erasures = append(report.Erasures, req)
+	}
+
+	return report, tx.Commit()
+}
+
+// ExportStudentData returns all the data held on the student with pid = pid.
+//
+// returns ENOTFOUND if the student isnt found.
+func (s *PrivacyService) ExportStudentData(ctx context.Context, pid int) (*csb.StudentData, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	data := &csb.StudentData{ExportedAt: time.Now()}
+	if data.Student, err = findStudentByPID(ctx, tx, pid); err != nil {
+		return nil, err
+	} else if err := attachStudentAssociations(ctx, tx, data.Student, csb.Include{"subjects", "marks"}); err != nil {
+		return nil, err
+	}
+
+	if data.AlertRules, err = findAlertRules(ctx, tx, csb.AlertRuleFilter{StudentID: &pid}); err != nil {
+		return nil, err
+	}
+	if data.Alerts, err = findAlerts(ctx, tx, csb.AlertFilter{PID: &pid}); err != nil {
+		return nil, err
+	}
+	if data.Ranks, err = findRanksByPID(ctx, tx, pid); err != nil {
+		return nil, err
+	}
+	if data.Erasures, err = findErasureRequests(ctx, tx, &pid); err != nil {
+		return nil, err
+	}
+	if data.AuditEntries, err = findAuditEntriesByPID(ctx, tx, pid); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// CreateErasureRequest permanently deletes all the data held on the student with
+// pid = req.PID and records the removed data on the request. The student isnt added back by
+// later refreshes.
+//
+// returns ENOTFOUND if the student isnt found.
+func (s *PrivacyService) CreateErasureRequest(ctx context.Context, req *csb.ErasureRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	req.Action = csb.RetentionDelete
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := eraseStudent(ctx, tx, req); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FindErasureRequests returns the recorded erasures, newest first.
+func (s *PrivacyService) FindErasureRequests(ctx context.Context, pid *int) ([]*csb.ErasureRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findErasureRequests(ctx, tx, pid)
+}
+
+// findExpiredPIDs returns the pupil ids of the students who left the school more than
+// policy.Years ago. Anonymized students are only returned to policies deleting the data.
+func findExpiredPIDs(ctx context.Context, tx *Tx, policy csb.RetentionPolicy) ([]int, error) {
+	where := []string{"attends_school = 0", "left_at IS NOT NULL", "left_at <= ?"}
+	if policy.Action == csb.RetentionAnonymize {
+		where = append(where, "pid > 0")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT pid
+		FROM students
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY pid ASC
+	`,
+		time.Now().AddDate(-policy.Years, 0, 0),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pids := make([]int, 0)
+	for rows.Next() {
+		var pid int
+		if err := rows.Scan(&pid); err != nil {
+			return nil, err
+		}
+
+		pids = append(pids, pid)
+	}
+
+	return pids, rows.Err()
+}
+
+// eraseStudent deletes the student along side all their data and records the erasure.
+//
+// returns ENOTFOUND if the student isnt found.
+func eraseStudent(ctx context.Context, tx *Tx, req *csb.ErasureRequest) (err error) {
+	if _, err := findStudentByPID(ctx, tx, req.PID); err != nil {
+		return err
+	}
+
+	if req.Removed, err = countStudentData(ctx, tx, req.PID); err != nil {
+		return err
+	}
+	// the data of the student is deleted in cascade.
+	if err := deleteStudent(ctx, tx, req.PID); err != nil {
+		return err
+	}
+	// along side the summary of the deletion just recorded.
+	if err := clearAuditSummaries(ctx, tx, req.PID); err != nil {
+		return err
+	}
+
+	return createErasureRequest(ctx, tx, req)
+}
+
+// anonymizeStudent replaces the pupil id of the student with a negative surrogate and their
+// name with anonymizedName, deleting their alert rules, alerts and audit summaries. The erasure
+// is recorded with the replaced pupil id only, so the surrogate cant be traced back.
+//
+// returns ENOTFOUND if the student isnt found.
+func anonymizeStudent(ctx context.Context, tx *Tx, req *csb.ErasureRequest) error {
+	if _, err := findStudentByPID(ctx, tx, req.PID); err != nil {
+		return err
+	}
+
+	counts, err := countStudentData(ctx, tx, req.PID)
+	if err != nil {
+		return err
+	}
+	req.Removed = map[string]int{
+		"alert_rules":     counts["alert_rules"],
+		"alerts":          counts["alerts"],
+		"audit_summaries": counts["audit_summaries"],
+	}
+
+	surrogate, err := newSurrogatePID(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	// the pupil id of the student and their data changes in separate statements, check the
+	// foreign keys on commit.
+	if _, err := tx.ExecContext(ctx, `PRAGMA defer_foreign_keys = on;`); err != nil {
+		return err
+	}
+	for _, query := range []string{
+		`DELETE FROM alerts WHERE student_id = ?`,
+		`DELETE FROM alert_rules WHERE student_id = ?`,
+		// the search index drops the student once their pupil id changes.
+		`UPDATE students SET name = '` + anonymizedName + `' WHERE pid = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, req.PID); err != nil {
+			return err
+		}
+	}
+	for _, query := range []string{
+		`UPDATE students SET pid = ? WHERE pid = ?`,
+		`UPDATE student_takes SET student_id = ? WHERE student_id = ?`,
+		`UPDATE marks SET student_id = ? WHERE student_id = ?`,
+		`UPDATE ranks SET student_id = ? WHERE student_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, surrogate, req.PID); err != nil {
+			return err
+		}
+	}
+	// the summaries tie the marks, which keep their ids, to the replaced pupil id.
+	if err := clearAuditSummaries(ctx, tx, req.PID); err != nil {
+		return err
+	}
+	if err := createAuditEntry(ctx, tx, csb.AuditAnonymize, csb.AuditStudent, req.PID, nil, nil); err != nil {
+		return err
+	}
+
+	return createErasureRequest(ctx, tx, req)
+}
+
+// newSurrogatePID returns a random negative pupil id which no student has. The surrogates are
+// random so the order of the anonymizations cant be read from them.
+func newSurrogatePID(ctx context.Context, tx *Tx) (int, error) {
+	for i := 0; i < maxSurrogateAttempts; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt32))
+		if err != nil {
+			return 0, err
+		}
+		surrogate := -int(n.Int64()) - 1
+
+		var taken bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM students WHERE pid = ?)`, surrogate).Scan(&taken); err != nil {
+			return 0, err
+		}
+		if !taken {
+			return surrogate, nil
+		}
+	}
+
+	return 0, csb.Errorf(csb.ECONFLICT, "couldnt find a free surrogate pupil id")
+}
+
+// countStudentData counts the records held on the student by kind.
+func countStudentData(ctx context.Context, tx *Tx, pid int) (map[string]int, error) {
+	counts := make(map[string]int)
+	for kind, query := range map[string]string{
+		"students":    `SELECT COUNT(*) FROM students WHERE pid = ?`,
+		"subjects":    `SELECT COUNT(*) FROM student_takes WHERE student_id = ?`,
+		"marks":       `SELECT COUNT(*) FROM marks WHERE student_id = ?`,
+		"alert_rules": `SELECT COUNT(*) FROM alert_rules WHERE student_id = ?`,
+		"alerts":      `SELECT COUNT(*) FROM alerts WHERE student_id = ?`,
+		"ranks":       `SELECT COUNT(*) FROM ranks WHERE student_id = ?`,
+		// the summary of the deletion itself isnt counted, it is cleared right away.
+		"audit_summaries": `SELECT COUNT(*) FROM audit_summaries WHERE student_pid = ?`,
+	} {
+		var n int
+		if err := tx.QueryRowContext(ctx, query, pid).Scan(&n); err != nil {
+			return nil, err
+		}
+		counts[kind] = n
+	}
+
+	return counts, nil
+}
+
+// isErased reports wether any data held on the student with pid = pid was erased.
+func isErased(ctx context.Context, tx *Tx, pid int) (bool, error) {
+	var erased bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM erasure_requests WHERE pid = ?)`, pid).Scan(&erased)
+	return erased, err
+}
+
+func createErasureRequest(ctx context.Context, tx *Tx, req *csb.ErasureRequest) error {
+	removed, err := json.Marshal(req.Removed)
+	if err != nil {
+		return err
+	}
+	req.CreatedAt = time.Now()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO erasure_requests (
+			pid,
+			action,
+			reason,
+			removed,
+			created_at
+		) VALUES (?, ?, ?, ?, ?)
+	`,
+		req.PID,
+		req.Action,
+		req.Reason,
+		string(removed),
+		req.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	req.ID = int(id)
+
+	return nil
+}
+
+func findErasureRequests(ctx context.Context, tx *Tx, pid *int) ([]*csb.ErasureRequest, error) {
+	where, args := []string{"1=1"}, []interface{}{}
+	if pid != nil {
+		where = append(where, "pid = ?")
+		args = append(args, *pid)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			pid,
+			action,
+			reason,
+			removed,
+			created_at
+		FROM erasure_requests
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY created_at DESC, id DESC
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reqs := make([]*csb.ErasureRequest, 0)
+	for rows.Next() {
+		var req csb.ErasureRequest
+		var removed string
+		if err := rows.Scan(
+			&req.ID,
+			&req.PID,
+			&req.Action,
+			&req.Reason,
+			&removed,
+			&req.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(removed), &req.Removed); err != nil {
+			return nil, err
+		}
+
+		reqs = append(reqs, &req)
+	}
+
+	return reqs, rows.Err()
+}
+
+// findRanksByPID returns the stored ranks of the student with their subjects, newest first.
+func findRanksByPID(ctx context.Context, tx *Tx, pid int) ([]*csb.Rank, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			student_id,
+			score,
+			weight_profile,
+			weight_profile_version,
+			academic_year,
+			term,
+			importance,
+			generated_at
+		FROM ranks
+		WHERE student_id = ?
+		ORDER BY generated_at DESC, id DESC
+	`,
+		pid,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := make([]*csb.Rank, 0)
+	for rows.Next() {
+		rank, err := scanRank(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		ranks = append(ranks, rank)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	// attach the subjects once the rows are closed.
+	for _, rank := range ranks {
+		if err := attachRankSubjects(ctx, tx, rank); err != nil {
+			return nil, err
+		}
+	}
+
+	return ranks, nil
+}
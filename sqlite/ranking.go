@@ -0,0 +1,474 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.RankingService = (*RankingService)(nil)
+
+// RankingService generates ranks from the stored marks and keeps the backup ranks.
+type RankingService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewRankingService creates a new ranking service with the provided database.
+func NewRankingService(db *DB) *RankingService {
+	return &RankingService{
+		db: db,
+	}
+}
+
+// GenerateRankingsReport ranks all the students with marks on the filtered subjects and period.
+// Students with the same score share the same position and the position after them is skipped.
+//
+// The generated ranks arent stored.
+func (s *RankingService) GenerateRankingsReport(ctx context.Context, filter csb.RankingFilter) ([]csb.Rank, error) {
+	if err := filter.Periods.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	profile, err := findRankWeightProfile(ctx, tx, filter.WeightProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	students, err := findStudents(ctx, tx, csb.StudentFilter{CurrentYear: filter.Year})
+	if err != nil {
+		return nil, err
+	}
+	byPID := make(map[int]*csb.Student, len(students))
+	for _, student := range students {
+		byPID[student.PID] = student
+	}
+
+	// group the marks of the filtered students.
+	marks := make(map[int][]*csb.Mark)
+	if err := iterMarks(ctx, tx, csb.MarksFilter{
+		Periods:  []csb.Period{filter.Periods},
+		Subjects: filter.Subjects,
+	}, func(mark *csb.Mark) error {
+		if _, ok := byPID[mark.StudentID]; ok {
+			marks[mark.StudentID] = append(marks[mark.StudentID], mark)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ranks := make([]csb.Rank, 0, len(marks))
+	for _, student := range students {
+		if len(marks[student.PID]) == 0 {
+			continue
+		}
+
+		rank := newRank(marks[student.PID], profile)
+		rank.StudentID, rank.Student = student.PID, student
+		rank.Period = filter.Periods
+		rank.GeneratedAt = now
+
+		ranks = append(ranks, rank)
+	}
+
+	sort.SliceStable(ranks, func(i, j int) bool { return ranks[i].Score > ranks[j].Score })
+	for i := range ranks {
+		if i > 0 && ranks[i].Score == ranks[i-1].Score {
+			ranks[i].Postion = ranks[i-1].Postion
+		} else {
+			ranks[i].Postion = i + 1
+		}
+	}
+
+	if v := filter.GradeScale; v != nil {
+		for i := range ranks {
+			if err := gradeRank(ctx, tx, *v, &ranks[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return ranks, nil
+}
+
+// ViewEvolution returns the backup ranks of the student with the same period and subjects,
+// oldest first. If offset is positive only the offset latest ranks are returned.
+//
+// If no subjects are provided the ranks on any subjects are returned.
+//
+// returns ENOTFOUND if the student isnt found.
+func (s *RankingService) ViewEvolution(ctx context.Context, pid, offset int, period csb.Period, subjects []csb.Subject) ([]csb.Rank, error) {
+	if err := period.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := findStudentByPID(ctx, tx, pid); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			student_id,
+			score,
+			weight_profile,
+			weight_profile_version,
+			academic_year,
+			term,
+			importance,
+			generated_at
+		FROM ranks
+		WHERE student_id = ?
+		AND academic_year = ?
+		AND term IS ?
+		AND importance IS ?
+		ORDER BY generated_at DESC, id DESC
+	`,
+		pid,
+		period.AcademicYear,
+		period.Term,
+		period.Importance,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := make([]csb.Rank, 0)
+	for rows.Next() {
+		rank, err := scanRank(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		ranks = append(ranks, *rank)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]csb.Rank, 0, len(ranks))
+	for _, rank := range ranks {
+		if offset > 0 && len(out) == offset {
+			break
+		}
+
+		if err := attachRankSubjects(ctx, tx, &rank); err != nil {
+			return nil, err
+		}
+		if len(subjects) > 0 && !sameSubjects(rank.Subjects, subjects) {
+			continue
+		}
+
+		out = append(out, rank)
+	}
+
+	// oldest first.
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// CreateBackupRank scores the marks of the student on the subjects and period and stores the
+// rank.
+//
+// returns ENOTFOUND if the student or weight profile isnt found and EINVALID if the student
+// has no marks to rank.
+func (s *RankingService) CreateBackupRank(ctx context.Context, pid int, period csb.Period, subjects []csb.Subject, ref *csb.WeightProfileRef) (csb.Rank, error) {
+	if err := period.Validate(); err != nil {
+		return csb.Rank{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return csb.Rank{}, err
+	}
+	defer tx.Rollback()
+
+	student, err := findStudentByPID(ctx, tx, pid)
+	if err != nil {
+		return csb.Rank{}, err
+	}
+
+	profile, err := findRankWeightProfile(ctx, tx, ref)
+	if err != nil {
+		return csb.Rank{}, err
+	}
+
+	marks, err := findMarks(ctx, tx, csb.MarksFilter{
+		PID:      &pid,
+		Periods:  []csb.Period{period},
+		Subjects: subjects,
+	})
+	if err != nil {
+		return csb.Rank{}, err
+	} else if len(marks) == 0 {
+		return csb.Rank{}, csb.Errorf(csb.EINVALID, "student has no marks to rank")
+	}
+
+	rank := newRank(marks, profile)
+	rank.StudentID, rank.Student = pid, student
+	rank.Period = period
+	if err := createRank(ctx, tx, &rank); err != nil {
+		return csb.Rank{}, err
+	}
+
+	return rank, tx.Commit()
+}
+
+// DeleteRank permanently deletes a backup rank.
+//
+// returns ENOTFOUND if the rank isnt found.
+func (s *RankingService) DeleteRank(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM ranks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return csb.Errorf(csb.ENOTFOUND, "rank not found")
+	}
+
+	return tx.Commit()
+}
+
+// findRankWeightProfile returns the referenced weight profile, nil if there is no reference.
+func findRankWeightProfile(ctx context.Context, tx *Tx, ref *csb.WeightProfileRef) (*csb.WeightProfile, error) {
+	if ref == nil {
+		return nil, nil
+	}
+
+	return findWeightProfile(ctx, tx, *ref)
+}
+
+// newRank scores the marks, the score is the average of the percentages weighted by the
+// profile or the plain average if there is no profile. The subjects of the rank are the
+// subjects of the marks.
+func newRank(marks []*csb.Mark, profile *csb.WeightProfile) csb.Rank {
+	var rank csb.Rank
+	var sum, total float64
+	seen := make(map[int]struct{})
+	for _, mark := range marks {
+		w := 1.0
+		if profile != nil {
+			w = profile.Weight(mark)
+		}
+		sum += w * float64(mark.Percentage)
+		total += w
+
+		if _, ok := seen[mark.SubjectID]; !ok {
+			seen[mark.SubjectID] = struct{}{}
+			rank.Subjects = append(rank.Subjects, mark.Subject)
+		}
+	}
+	sort.Slice(rank.Subjects, func(i, j int) bool { return rank.Subjects[i].ID < rank.Subjects[j].ID })
+
+	// all the marks can be weighted out.
+	if total > 0 {
+		rank.Score = int(math.Round(sum / total))
+	}
+	if profile != nil {
+		rank.WeightProfile, rank.WeightProfileVersion = profile.Name, profile.Version
+	}
+
+	return rank
+}
+
+// gradeRank grades the rank on the most specific scale of the family in scope of the year
+// group of the student in the academic year of the rank. The rank is left ungraded if the
+// family has no scale in scope.
+func gradeRank(ctx context.Context, tx *Tx, name string, rank *csb.Rank) error {
+	var year *int
+	if rank.Student != nil {
+		if y := rank.Student.YearIn(rank.Period.AcademicYear); y != 0 {
+			year = &y
+		}
+	}
+
+	scale, err := findGradeScaleInScope(ctx, tx, name, nil, year, &rank.Period.AcademicYear)
+	if csb.ErrorCode(err) == csb.ENOTFOUND {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	rank.Grade = scale.Grade(rank.Score)
+	return nil
+}
+
+func createRank(ctx context.Context, tx *Tx, rank *csb.Rank) error {
+	rank.GeneratedAt = time.Now()
+
+	// plain averages have no profile.
+	var profile, version interface{}
+	if rank.WeightProfile != "" {
+		profile, version = rank.WeightProfile, rank.WeightProfileVersion
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO ranks (
+			student_id,
+			score,
+			weight_profile,
+			weight_profile_version,
+			academic_year,
+			term,
+			importance,
+			generated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		rank.StudentID,
+		rank.Score,
+		profile,
+		version,
+		rank.Period.AcademicYear,
+		rank.Period.Term,
+		rank.Period.Importance,
+		rank.GeneratedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rank.ID = int(id)
+
+	for _, subject := range rank.Subjects {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO rank_subjects (
+				rank_id,
+				subject_id
+			) VALUES (?, ?)
+		`,
+			rank.ID,
+			subject.ID,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func attachRankSubjects(ctx context.Context, tx *Tx, rank *csb.Rank) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			subjects.id,
+			subjects.engage_code,
+			subjects.name
+		FROM subjects
+		JOIN rank_subjects ON rank_subjects.subject_id = subjects.id
+		WHERE rank_subjects.rank_id = ?
+		ORDER BY subjects.id ASC
+	`,
+		rank.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	rank.Subjects = make([]csb.Subject, 0)
+	for rows.Next() {
+		var subject csb.Subject
+		if err := rows.Scan(
+			&subject.ID,
+			&subject.EngageCode,
+			&subject.Name,
+		); err != nil {
+			return err
+		}
+
+		rank.Subjects = append(rank.Subjects, subject)
+	}
+
+	return rows.Err()
+}
+
+// sameSubjects reports wether the stored subjects are exactly the requested subjects, the
+// requested subjects are matched by their most specific populated field.
+func sameSubjects(stored, requested []csb.Subject) bool {
+	if len(stored) != len(requested) {
+		return false
+	}
+
+	for _, r := range requested {
+		found := false
+		for _, s := range stored {
+			switch {
+			case r.ID != 0:
+				found = r.ID == s.ID
+			case r.EngageCode != "":
+				found = r.EngageCode == s.EngageCode
+			default:
+				found = r.Name == s.Name
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func scanRank(s scanner) (*csb.Rank, error) {
+	var rank csb.Rank
+	var profile sql.NullString
+	var version, term sql.NullInt64
+	var importance sql.NullString
+	if err := s.Scan(
+		&rank.ID,
+		&rank.StudentID,
+		&rank.Score,
+		&profile,
+		&version,
+		&rank.Period.AcademicYear,
+		&term,
+		&importance,
+		&rank.GeneratedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	rank.WeightProfile, rank.WeightProfileVersion = profile.String, int(version.Int64)
+	rank.Period.Term = nullIntPtr(term)
+	if importance.Valid {
+		rank.Period.Importance = &importance.String
+	}
+	return &rank, nil
+}
@@ -0,0 +1,390 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.SearchService = (*SearchService)(nil)
+
+// defaultSearchLimit is the number of results of a search without a limit.
+const defaultSearchLimit = 20
+
+// maxSearchTerms caps the number of indexed terms compared with each word to correct typos.
+const maxSearchTerms = 5000
+
+// diacritics folds the accented letters of a query the same way the tokenizer of the search
+// index does, so typo corrections arent spent on diacritics. The names are folded the same
+// way without the search index.
+var diacritics = strings.NewReplacer(
+	"ă", "a", "â", "a", "á", "a", "à", "a", "ä", "a",
+	"é", "e", "è", "e", "ë", "e",
+	"î", "i", "í", "i",
+	"ó", "o", "ö", "o", "ő", "o",
+	"ú", "u", "ü", "u", "ű", "u",
+	"ș", "s", "ş", "s",
+	"ț", "t", "ţ", "t",
+	"ç", "c",
+)
+
+// SearchService searches the students through the full text search index of their names, or
+// through their names if the index isnt available.
+type SearchService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewSearchService creates a new search service with the provided database.
+func NewSearchService(db *DB) *SearchService {
+	return &SearchService{
+		db: db,
+	}
+}
+
+// SearchStudents matches every word of the query as a prefix of the words in the names of
+// the students, in any order. Words which arent a prefix of any indexed word are replaced
+// with the indexed words within their typo tolerance. The students are ranked by bm25.
+//
+// Without the search index, the words are matched anywhere in the names without typo
+// corrections.
+func (s *SearchService) SearchStudents(ctx context.Context, search csb.StudentSearch) ([]*csb.StudentMatch, error) {
+	if err := search.Validate(); err != nil {
+		return nil, err
+	}
+
+	words := searchWords(search.Query)
+	if len(words) == 0 {
+		return nil, csb.Errorf(csb.EINVALID, "search query has no words")
+	}
+	limit := search.Limit
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var matches []*csb.StudentMatch
+	if s.db.fts {
+		matches, err = findIndexedMatches(ctx, tx, words, search.AttendsSchool, limit)
+	} else {
+		matches, err = findNameMatches(ctx, tx, words, search.AttendsSchool, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, match := range matches {
+		if match.Student, err = findStudentByPID(ctx, tx, match.Student.PID); err != nil {
+			return nil, err
+		}
+		if err := attachStudentSubjects(ctx, tx, match.Student); err != nil {
+			return nil, err
+		}
+	}
+
+	return matches, nil
+}
+
+// findIndexedMatches finds the students through the search index, ranked by bm25.
+func findIndexedMatches(ctx context.Context, tx *Tx, words []string, attendsSchool *bool, limit int) ([]*csb.StudentMatch, error) {
+	match := make([]string, 0, len(words))
+	for _, word := range words {
+		expr, err := matchWord(ctx, tx, word)
+		if err != nil {
+			return nil, err
+		}
+		match = append(match, expr)
+	}
+
+	// prepare where clause.
+	where, args := []string{"students_search MATCH ?"}, []interface{}{strings.Join(match, " AND ")}
+	if v := attendsSchool; v != nil {
+		where = append(where, "students.attends_school = ?")
+		args = append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			students_search.rowid,
+			-bm25(students_search)
+		FROM students_search
+		JOIN students ON students.pid = students_search.rowid
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY bm25(students_search) ASC, students.pid ASC
+		LIMIT ?
+	`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanMatches(rows)
+}
+
+// findNameMatches finds the students whose names contain every word, without the search
+// index. The names are folded like the words in go since sqlite only lowers ascii letters,
+// the words arent corrected and all the matches have the same score.
+func findNameMatches(ctx context.Context, tx *Tx, words []string, attendsSchool *bool, limit int) ([]*csb.StudentMatch, error) {
+	// prepare where clause.
+	where, args := []string{"pid > 0"}, []interface{}{}
+	if v := attendsSchool; v != nil {
+		where = append(where, "attends_school = ?")
+		args = append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			pid,
+			name
+		FROM students
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY name ASC, pid ASC
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]*csb.StudentMatch, 0)
+	for rows.Next() && len(matches) < limit {
+		var pid int
+		var name string
+		if err := rows.Scan(
+			&pid,
+			&name,
+		); err != nil {
+			return nil, err
+		}
+
+		if containsWords(foldName(name), words) {
+			matches = append(matches, &csb.StudentMatch{Student: &csb.Student{PID: pid}})
+		}
+	}
+
+	return matches, rows.Err()
+}
+
+// containsWords reports wether the name contains every word.
+func containsWords(name string, words []string) bool {
+	for _, word := range words {
+		if !strings.Contains(name, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func scanMatches(rows *sql.Rows) ([]*csb.StudentMatch, error) {
+	defer rows.Close()
+
+	matches := make([]*csb.StudentMatch, 0)
+	for rows.Next() {
+		var pid int
+		var match csb.StudentMatch
+		if err := rows.Scan(
+			&pid,
+			&match.Score,
+		); err != nil {
+			return nil, err
+		}
+
+		match.Student = &csb.Student{PID: pid}
+		matches = append(matches, &match)
+	}
+
+	return matches, rows.Err()
+}
+
+// createSearchIndex creates the search index of the students if sqlite has fts5, it reports
+// wether the index is available. The index is filled with the existing students when it is
+// created and kept up to date by triggers.
+func createSearchIndex(db *sql.DB) (bool, error) {
+	var exists bool
+	if err := db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'students_search')
+	`).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS students_search USING fts5(
+			name,
+			tokenize = 'unicode61 remove_diacritics 2'
+		)
+	`); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, query := range []string{
+		// the terms of the search index, used to correct typos.
+		`CREATE VIRTUAL TABLE IF NOT EXISTS students_search_terms USING fts5vocab(students_search, row)`,
+		`CREATE TRIGGER IF NOT EXISTS students_search_insert AFTER INSERT ON students
+		BEGIN
+			INSERT INTO students_search (rowid, name) VALUES (new.pid, new.name);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS students_search_update AFTER UPDATE OF name ON students
+		BEGIN
+			UPDATE students_search SET name = new.name WHERE rowid = old.pid;
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS students_search_delete AFTER DELETE ON students
+		BEGIN
+			DELETE FROM students_search WHERE rowid = old.pid;
+		END`,
+		// the pupil id only changes when the student is anonymized, they arent searchable.
+		`CREATE TRIGGER IF NOT EXISTS students_search_anonymize AFTER UPDATE OF pid ON students
+		BEGIN
+			DELETE FROM students_search WHERE rowid = old.pid;
+		END`,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return false, err
+		}
+	}
+
+	if !exists {
+		if _, err := tx.Exec(`
+			INSERT INTO students_search (rowid, name)
+			SELECT pid, name FROM students WHERE pid > 0
+		`); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// searchWords splits the query in lower case words without diacritics.
+func searchWords(query string) []string {
+	return strings.FieldsFunc(foldName(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// foldName lowers the name and removes its diacritics.
+func foldName(name string) string {
+	return diacritics.Replace(strings.ToLower(name))
+}
+
+// matchWord returns the match expression of the word. If no term starts with the word, the
+// terms and term prefixes within the typo tolerance of the word are matched instead. At most
+// maxSearchTerms terms long enough to be within the typo tolerance are compared.
+func matchWord(ctx context.Context, tx *Tx, word string) (string, error) {
+	prefix := `"` + word + `"*`
+
+	// the terms starting with the word sort between the word and the word followed by the
+	// last code point.
+	var ok bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM students_search_terms WHERE term >= ? AND term < ?)
+	`,
+		word,
+		word+string(unicode.MaxRune),
+	).Scan(&ok); err != nil {
+		return "", err
+	}
+	tolerance := typoTolerance(word)
+	if ok || tolerance == 0 {
+		return prefix, nil
+	}
+
+	n := len([]rune(word))
+	rows, err := tx.QueryContext(ctx, `
+		SELECT term
+		FROM students_search_terms
+		WHERE length(term) >= ?
+		LIMIT ?
+	`,
+		n-tolerance,
+		maxSearchTerms,
+	)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	alternatives := []string{prefix}
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return "", err
+		}
+
+		runes := []rune(term)
+		if len(runes) > n {
+			runes = runes[:n]
+		}
+		if editDistance(word, term) <= tolerance || editDistance(word, string(runes)) <= tolerance {
+			alternatives = append(alternatives, `"`+term+`"`)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", nil
+}
+
+// typoTolerance returns the number of edits a word can be away from a term, short words
+// have to be exact.
+func typoTolerance(word string) int {
+	switch n := len([]rune(word)); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance returns the number of single rune insertions, deletions, substitutions or
+// transpositions of adjacent runes between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// d[i][j] is the distance between the first i runes of a and the first j runes of b.
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(min(d[i-1][j]+1, d[i][j-1]+1), d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
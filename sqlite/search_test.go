@@ -0,0 +1,74 @@
+package sqlite
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"ion", "", 3},
+		{"", "ion", 3},
+		{"popescu", "popescu", 0},
+		{"popescu", "popexcu", 1},
+		{"popescu", "popscu", 1},
+		{"popescu", "popesscu", 1},
+		{"maria", "mraia", 1},
+		{"marai", "maria", 1},
+		{"stefan", "ștefan", 1},
+		{"ionescu", "ionesu", 1},
+		{"ionescu", "ioensuc", 2},
+		{"kitten", "sitting", 3},
+	} {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestTypoTolerance(t *testing.T) {
+	for _, tt := range []struct {
+		word string
+		want int
+	}{
+		{"", 0},
+		{"ion", 0},
+		{"ștf", 0},
+		{"pope", 1},
+		{"ștefan", 1},
+		{"popescu", 2},
+		{"ionescuuu", 2},
+	} {
+		if got := typoTolerance(tt.word); got != tt.want {
+			t.Errorf("typoTolerance(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestSearchWords(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"", nil},
+		{"Ștefan Mărgărit", []string{"stefan", "margarit"}},
+		{"  ION-popa, 11 ", []string{"ion", "popa", "11"}},
+		{"--", nil},
+	} {
+		got := searchWords(tt.query)
+		if len(got) != len(tt.want) {
+			t.Errorf("searchWords(%q) = %q, want %q", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("searchWords(%q) = %q, want %q", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
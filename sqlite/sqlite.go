@@ -3,9 +3,14 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	_ "embed"
 	"errors"
+	"time"
 
+	"github.com/Lambels/CSB-Open-API/trace"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
@@ -15,10 +20,21 @@ import (
 //go:embed subjects_data.sql
 var data string
 
+// txSeconds times the transactions of the services by outcome, either commit, rollback or
+// error if the commit failed.
+var txSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "csb_sqlite_tx_seconds",
+	Help: "SQLite transaction duration in seconds by outcome.",
+}, []string{"outcome"})
+
 type DB struct {
 	DSN            string
 	MigrationsPath string
 	db             *sql.DB
+
+	// fts reports wether the full text search index of the students is available, it
+	// requires sqlite built with the sqlite_fts5 tag.
+	fts bool
 }
 
 func NewDB(dsn string, migrationsPath string) *DB {
@@ -28,6 +44,9 @@ func NewDB(dsn string, migrationsPath string) *DB {
 	}
 }
 
+// Open opens the database and runs the migrations. The search index of the students needs
+// sqlite with FTS5, build with the sqlite_fts5 tag. Without it the students are searched
+// without the index.
 func (db *DB) Open() error {
 	if db.DSN == "" {
 		return errors.New("database dsn required.")
@@ -65,13 +84,80 @@ func (db *DB) Open() error {
 		return err
 	}
 
-	if err := m.Up(); err != nil {
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+
+	if err := placeSubjectsTaken(dbSQL); err != nil {
+		return err
+	}
+
+	if db.fts, err = createSearchIndex(dbSQL); err != nil {
 		return err
 	}
 
 	return db.populateSubjects()
 }
 
+// Close closes the database.
+func (db *DB) Close() error {
+	if db.db == nil {
+		return nil
+	}
+	return db.db.Close()
+}
+
+// BeginTx starts a transaction, it is timed and traced from the call until it is either
+// committed or rolled back.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	start := time.Now()
+	_, span := trace.Start(ctx, "sqlite.tx")
+	tx, err := db.db.BeginTx(ctx, opts)
+	if err != nil {
+		span.Finish(err)
+		return nil, err
+	}
+
+	return &Tx{
+		Tx:    tx,
+		start: start,
+		span:  span,
+	}, nil
+}
+
+// Tx wraps sql.Tx to time and trace the transaction.
+type Tx struct {
+	*sql.Tx
+	start time.Time
+	span  *trace.Span
+}
+
+// Commit commits the transaction.
+func (tx *Tx) Commit() error {
+	err := tx.Tx.Commit()
+	if err != nil {
+		tx.observe("error", err)
+	} else {
+		tx.observe("commit", nil)
+	}
+	return err
+}
+
+// Rollback aborts the transaction, it isnt timed again if the transaction is done.
+func (tx *Tx) Rollback() error {
+	err := tx.Tx.Rollback()
+	if err != sql.ErrTxDone {
+		tx.observe("rollback", err)
+	}
+	return err
+}
+
+func (tx *Tx) observe(outcome string, err error) {
+	txSeconds.WithLabelValues(outcome).Observe(time.Since(tx.start).Seconds())
+	tx.span.SetAttribute("outcome", outcome)
+	tx.span.Finish(err)
+}
+
 func (db *DB) populateSubjects() error {
 	conn, err := db.db.Conn(context.Background())
 	if err != nil {
@@ -86,9 +172,15 @@ func (db *DB) populateSubjects() error {
 	defer resp.Close()
 
 	var n int
-	if err := resp.Scan(&n); err != nil {
+	if resp.Next() {
+		if err := resp.Scan(&n); err != nil {
+			return err
+		}
+	}
+	if err := resp.Err(); err != nil {
 		return err
 	}
+	resp.Close()
 
 	if n != 0 {
 		return nil
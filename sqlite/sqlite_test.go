@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Lambels/CSB-Open-API/csbtest"
+)
+
+func TestConformance(t *testing.T) {
+	csbtest.TestStudentService(t, newServices)
+	csbtest.TestMarkService(t, newServices)
+}
+
+// TestReopen checks that a migrated database opens again.
+func TestReopen(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "csb.db")
+	for i := 0; i < 2; i++ {
+		db := NewDB(dsn, "file://../db/migrations")
+		if err := db.Open(); err != nil {
+			t.Fatalf("open %v: %v", i, err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newServices opens a new database in a temporary directory.
+func newServices(t *testing.T, e *csbtest.Engage) csbtest.Services {
+	db := NewDB(filepath.Join(t.TempDir(), "csb.db"), "file://../db/migrations")
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return csbtest.Services{
+		Students: NewStudentService(db, e.Client(), false),
+		Marks:    NewMarkService(db, false, e.Client(), e.PeriodService()),
+	}
+}
@@ -0,0 +1,238 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.StatisticsService = (*StatisticsService)(nil)
+
+var (
+	// defaultPercentiles are calculated if the filter doesnt ask for any.
+	defaultPercentiles = []float64{25, 50, 75}
+	// defaultBucketSize is the width of the histogram buckets if the filter doesnt set one.
+	defaultBucketSize = 10
+)
+
+// StatisticsService calculates statistics over the stored marks.
+type StatisticsService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewStatisticsService creates a new statistics service with the provided database.
+func NewStatisticsService(db *DB) *StatisticsService {
+	return &StatisticsService{
+		db: db,
+	}
+}
+
+// MarkStatistics calculates the statistics of the marks scoped by the marks and students
+// filters, grouped by filter.GroupBy. The groups are ordered by their group fields.
+func (s *StatisticsService) MarkStatistics(ctx context.Context, filter csb.StatisticsFilter) ([]*csb.Statistics, error) {
+	for _, v := range filter.GroupBy {
+		switch v {
+		case csb.GroupBySubject, csb.GroupByTeacher, csb.GroupByYear, csb.GroupByTerm, csb.GroupByImportance:
+		default:
+			return nil, csb.Errorf(csb.EINVALID, "unknown group by field: %v", v)
+		}
+	}
+	if len(filter.Percentiles) == 0 {
+		filter.Percentiles = defaultPercentiles
+	}
+	for _, p := range filter.Percentiles {
+		if p < 0 || p > 100 {
+			return nil, csb.Errorf(csb.EINVALID, "percentile must be between 0 and 100 inclusive, but got: %v", p)
+		}
+	}
+	if filter.BucketSize == 0 {
+		filter.BucketSize = defaultBucketSize
+	}
+	if filter.BucketSize < 1 || filter.BucketSize > 100 {
+		return nil, csb.Errorf(csb.EINVALID, "bucket size must be between 1 and 100 inclusive, but got: %v", filter.BucketSize)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	marksWhere, args, err := marksWhere(filter.Marks)
+	if err != nil {
+		return nil, err
+	}
+	studentsWhere, studentsArgs := studentsWhere(filter.Students)
+	// the year group of the student at the academic year of the mark comes first.
+	args = append([]interface{}{csb.CurrentAcademicYear}, append(args, studentsArgs...)...)
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			marks.percentage,
+			subjects.id,
+			subjects.engage_code,
+			subjects.name,
+			IFNULL(teachers.name, marks.teacher),
+			students.current_year - (? - marks.academic_year),
+			marks.academic_year,
+			marks.term,
+			marks.importance
+		FROM marks
+		JOIN subjects ON subjects.id = marks.subject_id
+		JOIN students ON students.pid = marks.student_id
+		LEFT JOIN teachers ON teachers.id = marks.teacher_id
+		WHERE `+marksWhere+`
+		AND marks.student_id IN (SELECT pid FROM students WHERE `+studentsWhere+`)
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// group the percentages by the group key.
+	groups := make(map[string]*statisticsGroup)
+	keys := make([]string, 0)
+	for rows.Next() {
+		var percentage int
+		var subject csb.Subject
+		var teacher, importance string
+		var year, academicYear, term int
+		var yearGroup *int
+		if err := rows.Scan(
+			&percentage,
+			&subject.ID,
+			&subject.EngageCode,
+			&subject.Name,
+			&teacher,
+			&yearGroup,
+			&academicYear,
+			&term,
+			&importance,
+		); err != nil {
+			return nil, err
+		}
+		if yearGroup != nil {
+			year = *yearGroup
+		}
+
+		var group csb.StatisticsGroup
+		key := make([]string, 0, len(filter.GroupBy))
+		for _, v := range filter.GroupBy {
+			switch v {
+			case csb.GroupBySubject:
+				group.Subject = &subject
+				key = append(key, subject.Name)
+			case csb.GroupByTeacher:
+				group.Teacher = &teacher
+				key = append(key, teacher)
+			case csb.GroupByYear:
+				group.Year = yearGroup
+				key = append(key, fmt.Sprintf("%03d", year))
+			case csb.GroupByTerm:
+				group.AcademicYear, group.Term = &academicYear, &term
+				key = append(key, fmt.Sprintf("%04d-%d", academicYear, term))
+			case csb.GroupByImportance:
+				group.Importance = &importance
+				key = append(key, importance)
+			}
+		}
+
+		k := strings.Join(key, "\x00")
+		g, ok := groups[k]
+		if !ok {
+			g = &statisticsGroup{group: group}
+			groups[k] = g
+			keys = append(keys, k)
+		}
+		g.percentages = append(g.percentages, percentage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	out := make([]*csb.Statistics, 0, len(keys))
+	for _, k := range keys {
+		g := groups[k]
+		stats := newStatistics(g.percentages, filter.Percentiles, filter.BucketSize)
+		stats.Group = g.group
+
+		out = append(out, stats)
+	}
+
+	return out, nil
+}
+
+// statisticsGroup accumulates the percentages of a group.
+type statisticsGroup struct {
+	group       csb.StatisticsGroup
+	percentages []int
+}
+
+// newStatistics calculates the statistics of the percentages, percentages must not be empty.
+func newStatistics(percentages []int, percentiles []float64, bucketSize int) *csb.Statistics {
+	sort.Ints(percentages)
+	n := len(percentages)
+
+	stats := &csb.Statistics{
+		Count:       n,
+		Min:         percentages[0],
+		Max:         percentages[n-1],
+		Median:      percentile(percentages, 50),
+		Percentiles: make([]csb.Percentile, len(percentiles)),
+	}
+
+	var sum float64
+	for _, v := range percentages {
+		sum += float64(v)
+	}
+	stats.Mean = sum / float64(n)
+
+	var squares float64
+	for _, v := range percentages {
+		squares += (float64(v) - stats.Mean) * (float64(v) - stats.Mean)
+	}
+	stats.StdDev = math.Sqrt(squares / float64(n))
+
+	for i, p := range percentiles {
+		stats.Percentiles[i] = csb.Percentile{P: p, Value: percentile(percentages, p)}
+	}
+
+	// the last bucket also holds 100.
+	buckets := (100 + bucketSize - 1) / bucketSize
+	stats.Histogram = make([]csb.HistogramBucket, buckets)
+	for i := range stats.Histogram {
+		stats.Histogram[i] = csb.HistogramBucket{
+			Min: i * bucketSize,
+			Max: min(i*bucketSize+bucketSize, 100),
+		}
+	}
+	for _, v := range percentages {
+		stats.Histogram[min(v/bucketSize, buckets-1)].Count++
+	}
+
+	return stats
+}
+
+// percentile calculates the p-th percentile of the sorted values interpolating linearly
+// between the closest ranks.
+func percentile(sorted []int, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lo, hi := int(math.Floor(rank)), int(math.Ceil(rank))
+
+	return float64(sorted[lo]) + (rank-float64(lo))*float64(sorted[hi]-sorted[lo])
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	csb "github.com/Lambels/CSB-Open-API"
@@ -38,8 +39,15 @@ func NewStudentService(db *DB, client *engage.Client, fallback bool) *StudentSer
 //
 // If the user is found in engage and not in the db and saveNew is true the
 // user is saved before returned.
-func (s *StudentService) FindStudentByPID(ctx context.Context, pid int) (*csb.Student, error) {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+//
+// Only the included associations are loaded, the subjects of students from engage are
+// dropped if they arent included.
+func (s *StudentService) FindStudentByPID(ctx context.Context, pid int, include csb.Include) (*csb.Student, error) {
+	if err := include.Validate(csb.StudentAssociations...); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -48,57 +56,71 @@ func (s *StudentService) FindStudentByPID(ctx context.Context, pid int) (*csb.St
 	student, err := findStudentByPID(ctx, tx, pid)
 	switch csb.ErrorCode(err) {
 	case "":
-		if err := attachStudentMarks(ctx, tx, student); err != nil {
+		if err := attachStudentAssociations(ctx, tx, student, include); err != nil {
 			return nil, err
 		}
 
 		return student, nil
 
 	case csb.ENOTFOUND:
-		student, err := s.findStudentByPIDEngage(ctx, pid)
+		student, err := s.c.FindStudent(ctx, pid)
 		if err != nil {
 			return nil, err
 		}
-		if !s.fallback {
-			return student, nil
+		// the students whose data was erased arent saved again.
+		if erased, err := isErased(ctx, tx, pid); err != nil {
+			return nil, err
+		} else if s.fallback && !erased {
+			if err := createStudent(ctx, tx, student); err == nil {
+				if err := tx.Commit(); err != nil {
+					return nil, err
+				}
+			}
 		}
 
-		if err := createStudent(ctx, tx, student); err != nil {
-			return student, nil
+		// engage students come with their subjects, which are needed to save them.
+		if !include.Has("subjects") {
+			student.Subjects, student.SubjectHistory = nil, nil
 		}
-		return student, tx.Commit()
+		return student, nil
 
 	default:
 		return nil, err
 	}
 }
 
-// FindStudents returns a range of students based on the filter.
-func (s *StudentService) FindStudents(ctx context.Context, filter csb.StudentFilter) ([]*csb.Student, error) {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+// FindStudents returns a page of students based on the filter with the included
+// associations. Pages without a limit have csb.DefaultPageSize students and limits are
+// capped to csb.MaxPageSize.
+func (s *StudentService) FindStudents(ctx context.Context, filter csb.StudentFilter) ([]*csb.Student, csb.Page, error) {
+	if err := filter.Include.Validate(csb.StudentAssociations...); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, csb.Page{}, err
 	}
 	defer tx.Rollback()
 
-	students, err := findStudents(ctx, tx, filter)
+	students, page, err := findStudentsPage(ctx, tx, filter)
 	if err != nil {
-		return nil, err
+		return nil, csb.Page{}, err
 	}
 
 	for _, student := range students {
-		if err := attachStudentMarks(ctx, tx, student); err != nil {
-			return nil, err
+		if err := attachStudentAssociations(ctx, tx, student, filter.Include); err != nil {
+			return nil, csb.Page{}, err
 		}
 	}
 
-	return students, nil
+	return students, page, nil
 }
 
 // DeleteStudent permanently deletes a student specified by pid.
 // returns ENOTFOUND if student isnt found.
 func (s *StudentService) DeleteStudent(ctx context.Context, pid int) error {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil
 	}
@@ -119,12 +141,13 @@ func (s *StudentService) DeleteStudent(ctx context.Context, pid int) error {
 // attending school then the copy from engage to local storage wont be made.
 //
 // If refresh.Purge is set to true and a student in the local database is not attending the school
-// any more in engage, then the user is deleted.
+// any more in engage, then the user is deleted. The students with recorded erasures are never
+// added back.
 //
 // If the student is both in engage and local storage, an update will be so that your local
 // storage has the newest data.
 func (s *StudentService) RefreshStudents(ctx context.Context, refresh csb.RefreshStudents) error {
-	tx, err := s.db.db.BeginTx(ctx, nil)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -133,7 +156,7 @@ func (s *StudentService) RefreshStudents(ctx context.Context, refresh csb.Refres
 	PIDCount := refresh.StartPID
 	for i := 0; i < refresh.N; i++ {
 		// engage copy.
-		studentEngage, err := s.findStudentByPIDEngage(ctx, PIDCount)
+		studentEngage, err := s.c.FindStudent(ctx, PIDCount)
 		if err != nil && csb.ErrorCode(err) != csb.ENOTFOUND {
 			return err
 		}
@@ -154,6 +177,12 @@ func (s *StudentService) RefreshStudents(ctx context.Context, refresh csb.Refres
 			if !studentEngage.AttendsSchool && refresh.Purge {
 				break
 			}
+			// the students whose data was erased arent added back.
+			if erased, err := isErased(ctx, tx, PIDCount); err != nil {
+				return err
+			} else if erased {
+				break
+			}
 
 			if err := createStudent(ctx, tx, studentEngage); err != nil {
 				return err
@@ -183,10 +212,10 @@ func (s *StudentService) RefreshStudents(ctx context.Context, refresh csb.Refres
 		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func findStudentByPID(ctx context.Context, tx *sql.Tx, pid int) (*csb.Student, error) {
+func findStudentByPID(ctx context.Context, tx *Tx, pid int) (*csb.Student, error) {
 	s, err := findStudents(ctx, tx, csb.StudentFilter{PID: &pid})
 	if err != nil {
 		return nil, err
@@ -197,50 +226,123 @@ func findStudentByPID(ctx context.Context, tx *sql.Tx, pid int) (*csb.Student, e
 	return s[0], nil
 }
 
-func (s *StudentService) findStudentByPIDEngage(ctx context.Context, pid int) (*csb.Student, error) {
-	stud := new(csb.Student)
-	stud.PID = pid
-
-	academicYears, err := s.c.GetAcademicYears(ctx, pid)
+func findStudents(ctx context.Context, tx *Tx, filter csb.StudentFilter) ([]*csb.Student, error) {
+	students := make([]*csb.Student, 0)
+	err := iterStudents(ctx, tx, filter, func(student *csb.Student) error {
+		students = append(students, student)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	for _, academicYear := range academicYears {
-		if academicYear == csb.CurrentAcademicYear {
-			stud.AttendsSchool = true
-			break
-		}
-	}
+	return students, nil
+}
 
-	buf, err := s.c.GetMarksheetRender(ctx, pid, academicYears[len(academicYears)-1:], nil, nil, nil)
+// findStudentsPage returns the page of the students matching the filter requested by the
+// sort, limit, offset and cursor of the filter.
+func findStudentsPage(ctx context.Context, tx *Tx, filter csb.StudentFilter) ([]*csb.Student, csb.Page, error) {
+	p, err := newPage(studentSorts, "pid", filter.Sort, filter.Limit, filter.Offset, filter.Cursor)
 	if err != nil {
-		return nil, err
+		return nil, csb.Page{}, err
 	}
 
-	var x int
-	stud.Name, x, err = engage.NameFromRender(buf)
-	if err != nil {
-		return nil, err
+	where, args := studentsWhere(filter)
+
+	var page csb.Page
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM students WHERE `+where, args...).Scan(&page.Total); err != nil {
+		return nil, csb.Page{}, err
+	}
+
+	students := make([]*csb.Student, 0, p.Limit+1)
+	if err := queryStudents(ctx, tx, filter, p, func(student *csb.Student) error {
+		students = append(students, student)
+		return nil
+	}); err != nil {
+		return nil, csb.Page{}, err
 	}
-	// FASTPATH: doesent attend school -> no need to get current year.
-	if !stud.AttendsSchool {
-		return stud, nil
+
+	// the extra student only tells that there is a next page.
+	if len(students) > p.Limit {
+		students = students[:p.Limit]
+
+		page.NextCursor = p.Cursor(csb.StudentSortKeys[p.Field](students[len(students)-1]))
 	}
 
-	// reslice the buffer since we know that the current year wont be before the name so we
-	// dont need to search that area.
-	buf = buf[x:]
-	stud.CurrentYear, _, err = engage.CurrentYearFromRender(buf)
+	return students, page, nil
+}
+
+// iterStudents calls fn for each student matching the filter in pid order, straight from
+// the cursor. Iteration stops on the first error returned by fn.
+//
+// The sort, limit, offset and cursor of the filter are ignored, all the students are
+// iterated.
+func iterStudents(ctx context.Context, tx *Tx, filter csb.StudentFilter, fn func(*csb.Student) error) error {
+	return queryStudents(ctx, tx, filter, nil, fn)
+}
+
+// queryStudents calls fn for each student matching the filter in the page, all the students
+// in pid order if p is nil.
+func queryStudents(ctx context.Context, tx *Tx, filter csb.StudentFilter, p *page, fn func(*csb.Student) error) error {
+	where, args := studentsWhere(filter)
+
+	orderBy, limit := "pid ASC", ""
+	if p != nil {
+		cond, condArgs := p.where()
+		where += " AND " + cond
+		args = append(args, condArgs...)
+		orderBy = p.orderBy()
+
+		// query one more student to tell if there is a next page.
+		limit = "LIMIT ? OFFSET ?"
+		args = append(args, p.Limit+1, p.Offset)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			pid,
+			name,
+			current_year,
+			attends_school,
+			created_at,
+			updated_at
+		FROM students
+		WHERE `+where+`
+		ORDER BY `+orderBy+`
+		`+limit+`
+	`,
+		args...,
+	)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var student csb.Student
+		var currYear sql.NullInt64
+		if err := rows.Scan(
+			&student.PID,
+			&student.Name,
+			&currYear,
+			&student.AttendsSchool,
+			&student.CreatedAt,
+			&student.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		student.CurrentYear = int(currYear.Int64)
+
+		if err := fn(&student); err != nil {
+			return err
+		}
 	}
 
-	return stud, nil
+	return rows.Err()
 }
 
-func findStudents(ctx context.Context, tx *sql.Tx, filter csb.StudentFilter) ([]*csb.Student, error) {
-	// prepare where clause.
+// studentsWhere builds the where clause and its arguments for the student filter.
+func studentsWhere(filter csb.StudentFilter) (string, []interface{}) {
 	where, args := []string{"1=1"}, []interface{}{}
 	if v := filter.PID; v != nil {
 		where = append(where, "pid = ?")
@@ -259,18 +361,27 @@ func findStudents(ctx context.Context, tx *sql.Tx, filter csb.StudentFilter) ([]
 		args = append(args, *v)
 	}
 
-	// `SELECT * FROM students WHERE id IN (
-	// 	SELECT student_id FROM student_subjects WHERE subject_id = 1
+	// students must take all the subjects:
+	//
+	// pid IN (
+	// 	SELECT student_id FROM student_takes WHERE subject_id IN (...)
 	// 	INTERSECT
-	// 	SELECT student_id FROM student_subjects WHERE subject_id = 2
-	// 	INTERSECT
-	// 	SELECT student_id FROM student_subjects WHERE subject_id = 3
-	// )`
+	// 	SELECT student_id FROM student_takes WHERE subject_id IN (...)
+	// )
+	if len(filter.Subjects) > 0 {
+		takes := make([]string, 0, len(filter.Subjects))
+		for _, subject := range filter.Subjects {
+			cond, arg := subjectCondition(subject)
+			takes = append(takes, "SELECT student_id FROM student_takes WHERE subject_id IN (SELECT id FROM subjects WHERE "+cond+")")
+			args = append(args, arg)
+		}
+		where = append(where, "pid IN ("+strings.Join(takes, " INTERSECT ")+")")
+	}
 
-	return nil, nil
+	return strings.Join(where, " AND "), args
 }
 
-func createStudent(ctx context.Context, tx *sql.Tx, student *csb.Student) error {
+func createStudent(ctx context.Context, tx *Tx, student *csb.Student) error {
 	if err := student.Validate(); err != nil {
 		return err
 	}
@@ -278,6 +389,8 @@ func createStudent(ctx context.Context, tx *sql.Tx, student *csb.Student) error
 	student.CreatedAt = time.Now()
 	student.UpdatedAt = student.CreatedAt
 	currYear := sql.NullInt64{Int64: int64(student.CurrentYear), Valid: student.AttendsSchool}
+	// the students who already left are taken as leaving now.
+	leftAt := sql.NullTime{Time: student.CreatedAt, Valid: !student.AttendsSchool}
 
 	_, err := tx.ExecContext(ctx, `
 		INSERT INTO students (
@@ -285,14 +398,16 @@ func createStudent(ctx context.Context, tx *sql.Tx, student *csb.Student) error
 			name,
 			current_year,
 			attends_school,
+			left_at,
 			created_at,
-			updated_at,
-		) VALUES (?, ?, ?, ?, ?, ?)
+			updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?)
 	`,
 		student.PID,
 		student.Name,
 		currYear,
 		student.AttendsSchool,
+		leftAt,
 		student.CreatedAt,
 		student.UpdatedAt,
 	)
@@ -300,74 +415,119 @@ func createStudent(ctx context.Context, tx *sql.Tx, student *csb.Student) error
 		return err
 	}
 
-	for _, subject := range student.Subjects {
-		if err := attachSubjectCodeToStudent(ctx, tx, student.PID, subject.EngageCode); err != nil {
-			return err
-		}
+	if err := setStudentSubjects(ctx, tx, student.PID, student.SubjectHistory); err != nil {
+		return err
 	}
-	return nil
+
+	return createAuditEntry(ctx, tx, csb.AuditCreate, csb.AuditStudent, student.PID, nil, summarizeStudent(student))
 }
 
-func updateStudent(ctx context.Context, tx *sql.Tx, prev, next *csb.Student) error {
+func updateStudent(ctx context.Context, tx *Tx, prev, next *csb.Student) error {
 	if err := next.Validate(); err != nil {
 		return err
 	}
 
-	var addSubjects []csb.Subject
-	if len(next.Subjects) != len(prev.Subjects) {
-		diff := len(next.Subjects) - len(prev.Subjects)
-		addSubjects = make([]csb.Subject, diff)
-
-		diffMap := make(map[string]struct{}, len(prev.Subjects))
-		for _, v := range prev.Subjects {
-			diffMap[v.EngageCode] = struct{}{}
-		}
-
-		for _, v := range next.Subjects {
-			if _, ok := diffMap[v.EngageCode]; !ok {
-				addSubjects = append(addSubjects, v)
-			}
-		}
-	}
-
-	// add new subjects.
-	for _, v := range addSubjects {
-		if err := attachSubjectCodeToStudent(ctx, tx, prev.PID, v.EngageCode); err != nil {
-			return err
-		}
+	// replace the subjects of the academic years reported by engage.
+	if err := setStudentSubjects(ctx, tx, prev.PID, next.SubjectHistory); err != nil {
+		return err
 	}
-	// change in current year and current school atendance.
+	// change in name, current year and current school atendance.
 	currYear := sql.NullInt64{Int64: int64(next.CurrentYear), Valid: next.AttendsSchool}
 	// change updated at.
 	next.UpdatedAt = time.Now()
 
+	// the student left at the first update which doesnt see them attending.
 	_, err := tx.ExecContext(ctx, `
 		UPDATE students SET
+			name = ?,
 			current_year = ?,
 			attends_school = ?,
+			left_at = CASE WHEN ? THEN NULL ELSE COALESCE(left_at, ?) END,
 			updated_at = ?
 		WHERE pid = ?
 	`,
+		next.Name,
 		currYear,
 		next.AttendsSchool,
+		next.AttendsSchool,
+		next.UpdatedAt,
 		next.UpdatedAt,
 		prev.PID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	return createAuditEntry(ctx, tx, csb.AuditUpdate, csb.AuditStudent, prev.PID, summarizeStudent(prev), summarizeStudent(next))
+}
+
+func deleteStudent(ctx context.Context, tx *Tx, pid int) error {
+	student, err := findStudentByPID(ctx, tx, pid)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM students WHERE pid = ?`, pid); err != nil {
+		return err
+	}
+
+	return createAuditEntry(ctx, tx, csb.AuditDelete, csb.AuditStudent, pid, summarizeStudent(student), nil)
+}
+
+// setStudentSubjects replaces the subjects the student took in each academic year of the
+// history, the academic years missing from the history are left untouched.
+func setStudentSubjects(ctx context.Context, tx *Tx, pid int, history []csb.SubjectsTaken) error {
+	for _, taken := range history {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM student_takes
+			WHERE student_id = ? AND academic_year = ?
+		`,
+			pid,
+			taken.AcademicYear,
+		); err != nil {
+			return err
+		}
+
+		for _, subject := range taken.Subjects {
+			if err := attachSubjectToStudent(ctx, tx, pid, taken.AcademicYear, subject); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// attachStudentAssociations attaches the included associations of the student.
+func attachStudentAssociations(ctx context.Context, tx *Tx, student *csb.Student, include csb.Include) error {
+	if include.Has("subjects") {
+		if err := attachStudentSubjects(ctx, tx, student); err != nil {
+			return err
+		}
+	}
+	if include.Has("marks") {
+		if err := attachStudentMarks(ctx, tx, student); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func deleteStudent(ctx context.Context, tx *sql.Tx, pid int) error {
-	if _, err := findStudentByPID(ctx, tx, pid); err != nil {
+// attachStudentSubjects attaches all the subjects the student took and the subjects taken
+// in each academic year.
+func attachStudentSubjects(ctx context.Context, tx *Tx, student *csb.Student) (err error) {
+	if student.Subjects, err = findSubjectsByPID(ctx, tx, student.PID); err != nil {
 		return err
 	}
 
-	_, err := tx.ExecContext(ctx, `DELETE FROM students WHERE pid = ?`, pid)
+	student.SubjectHistory, err = findSubjectHistoryByPID(ctx, tx, student.PID)
 	return err
 }
 
-func attachStudentMarks(ctx context.Context, tx *sql.Tx, student *csb.Student) (err error) {
+func attachStudentMarks(ctx context.Context, tx *Tx, student *csb.Student) (err error) {
 	if student.Marks, err = findMarksByPID(ctx, tx, student.PID); err != nil {
-		fmt.Errorf("attach student marks: %w", err)
+		return fmt.Errorf("attach student marks: %w", err)
 	}
 	return nil
 }
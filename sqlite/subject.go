@@ -3,16 +3,244 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
+	"time"
 
 	csb "github.com/Lambels/CSB-Open-API"
+	"github.com/Lambels/CSB-Open-API/engage"
 )
 
-func findSubjectByName(ctx context.Context, tx *sql.Tx, name string) (csb.Subject, error) {
+var _ csb.SubjectService = (*SubjectService)(nil)
+
+// SubjectService manages the subject catalog and keeps it in sync with engage.
+type SubjectService struct {
+	// db for persistance.
+	db *DB
+	// client for syncs.
+	c *engage.Client
+}
+
+// NewSubjectService creates a new subject service with the provided database and engage client.
+func NewSubjectService(db *DB, client *engage.Client) *SubjectService {
+	return &SubjectService{
+		db: db,
+		c:  client,
+	}
+}
+
+// FindSubjectByID returns a subject based on the passed id.
+//
+// returns ENOTFOUND if the subject isnt found.
+func (s *SubjectService) FindSubjectByID(ctx context.Context, id int) (*csb.Subject, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	subject, err := findSubjectByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &subject, nil
+}
+
+// FindSubjects returns a range of subjects based on filter, ordered by name.
+func (s *SubjectService) FindSubjects(ctx context.Context, filter csb.SubjectFilter) ([]*csb.Subject, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findSubjects(ctx, tx, filter)
+}
+
+// CreateSubject creates a new subject.
+//
+// returns ECONFLICT if the engage code or name is taken.
+func (s *SubjectService) CreateSubject(ctx context.Context, subject *csb.Subject) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createSubject(ctx, tx, subject); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateSubject updates the engage code and name of a subject.
+//
+// returns ENOTFOUND if the subject isnt found and ECONFLICT if the engage code or name is
+// taken.
+func (s *SubjectService) UpdateSubject(ctx context.Context, id int, upd csb.SubjectUpdate) (*csb.Subject, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	subject, err := updateSubject(ctx, tx, id, upd)
+	if err != nil {
+		return nil, err
+	}
+
+	return subject, tx.Commit()
+}
+
+// DeleteSubject permanently deletes a subject, the marks and student links of the subject
+// are deleted with it.
+//
+// returns ENOTFOUND if the subject isnt found.
+func (s *SubjectService) DeleteSubject(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := findSubjectByID(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM subjects WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SyncSubjects reads the reporting subjects of every student over all their academic years
+// and reporting periods. The engage requests are made before touching the database and
+// spaced out to not spam engage.
+func (s *SubjectService) SyncSubjects(ctx context.Context, sync csb.SyncSubjects) (*csb.SubjectSyncReport, error) {
+	pids := sync.PIDs
+	if len(pids) == 0 {
+		attends := true
+		students, err := s.findStudents(ctx, csb.StudentFilter{AttendsSchool: &attends})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, student := range students {
+			pids = append(pids, student.PID)
+		}
+	}
+
+	// the engage display names by engage code.
+	names := make(map[string]string)
+	codes := make([]string, 0)
+	for i, pid := range pids {
+		if i > 0 {
+			// dont spam engage.
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("sync subjects: %w", ctx.Err())
+			case <-time.After(engage.RequestTimeout):
+			}
+		}
+
+		subjects, err := s.findSubjectsEngage(ctx, pid)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, subject := range subjects {
+			if _, ok := names[subject.EngageCode]; !ok {
+				codes = append(codes, subject.EngageCode)
+			}
+			names[subject.EngageCode] = subject.Name
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &csb.SubjectSyncReport{
+		Added:     make([]csb.Subject, 0),
+		Renamed:   make([]csb.SubjectRename, 0),
+		Conflicts: make([]csb.SubjectRename, 0),
+	}
+	for _, code := range codes {
+		name := names[code]
+
+		subject, err := findSubjectByEngageCode(ctx, tx, code)
+		switch {
+		case csb.ErrorCode(err) == csb.ENOTFOUND:
+			subject, err := createEngageSubject(ctx, tx, code, name)
+			if err != nil {
+				return nil, err
+			}
+
+			report.Added = append(report.Added, subject)
+			continue
+		case err != nil:
+			return nil, err
+		// subjects without a display name keep their current one.
+		case name == "" || subject.Name == name:
+			continue
+		}
+
+		rename := csb.SubjectRename{Subject: subject, From: subject.Name, To: name}
+		if !sync.ApplyRenames {
+			report.Renamed = append(report.Renamed, rename)
+			continue
+		}
+
+		renamed, err := updateSubject(ctx, tx, subject.ID, csb.SubjectUpdate{Name: &name})
+		if csb.ErrorCode(err) == csb.ECONFLICT {
+			report.Conflicts = append(report.Conflicts, rename)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		rename.Subject = *renamed
+		report.Renamed = append(report.Renamed, rename)
+	}
+
+	return report, tx.Commit()
+}
+
+func (s *SubjectService) findStudents(ctx context.Context, filter csb.StudentFilter) ([]*csb.Student, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findStudents(ctx, tx, filter)
+}
+
+func (s *SubjectService) findSubjectsEngage(ctx context.Context, pid int) ([]csb.Subject, error) {
+	academicYears, err := s.c.GetAcademicYears(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	periods, err := s.c.GetReportingPeriods(ctx, pid, academicYears)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.c.GetReportingSubjects(ctx, pid, academicYears, periods)
+}
+
+func findSubjectByName(ctx context.Context, tx *Tx, name string) (csb.Subject, error) {
 	row := tx.QueryRowContext(ctx, `
 		SELECT
 			id,
 			engage_code,
-			name,
+			name
 		FROM subjects
 		WHERE name = ?
 	`,
@@ -32,12 +260,12 @@ func findSubjectByName(ctx context.Context, tx *sql.Tx, name string) (csb.Subjec
 	return subject, err
 }
 
-func findSubjectByID(ctx context.Context, tx *sql.Tx, id int) (csb.Subject, error) {
+func findSubjectByID(ctx context.Context, tx *Tx, id int) (csb.Subject, error) {
 	row := tx.QueryRowContext(ctx, `
 		SELECT
 			id,
 			engage_code,
-			name,
+			name
 		FROM subjects
 		WHERE id = ?
 	`,
@@ -57,12 +285,12 @@ func findSubjectByID(ctx context.Context, tx *sql.Tx, id int) (csb.Subject, erro
 	return subject, err
 }
 
-func findSubjectByEngageCode(ctx context.Context, tx *sql.Tx, code string) (csb.Subject, error) {
+func findSubjectByEngageCode(ctx context.Context, tx *Tx, code string) (csb.Subject, error) {
 	row := tx.QueryRowContext(ctx, `
 		SELECT
 			id,
 			engage_code,
-			name,
+			name
 		FROM subjects
 		WHERE engage_code = ?
 	`,
@@ -82,21 +310,267 @@ func findSubjectByEngageCode(ctx context.Context, tx *sql.Tx, code string) (csb.
 	return subject, err
 }
 
-func attachSubjectCodeToStudent(ctx context.Context, tx *sql.Tx, pid int, code string) error {
-	subject, err := findSubjectByEngageCode(ctx, tx, code)
+func findSubjects(ctx context.Context, tx *Tx, filter csb.SubjectFilter) ([]*csb.Subject, error) {
+	// prepare where clause.
+	where, args := []string{"1=1"}, []interface{}{}
+	if v := filter.EngageCode; v != nil {
+		where = append(where, "engage_code = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Name; v != nil {
+		where = append(where, "name LIKE ?")
+		args = append(args, "%"+*v+"%")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			engage_code,
+			name
+		FROM subjects
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY name ASC
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := make([]*csb.Subject, 0)
+	for rows.Next() {
+		var subject csb.Subject
+		if err := rows.Scan(
+			&subject.ID,
+			&subject.EngageCode,
+			&subject.Name,
+		); err != nil {
+			return nil, err
+		}
+
+		subjects = append(subjects, &subject)
+	}
+
+	return subjects, rows.Err()
+}
+
+func createSubject(ctx context.Context, tx *Tx, subject *csb.Subject) error {
+	if err := subject.Validate(); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO subjects (
+			engage_code,
+			name
+		) VALUES (?, ?)
+	`,
+		subject.EngageCode,
+		subject.Name,
+	)
+	if isUniqueConstraint(err) {
+		return csb.Errorf(csb.ECONFLICT, "subject engage code or name already taken")
+	} else if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	subject.ID = int(id)
+
+	return nil
+}
+
+func updateSubject(ctx context.Context, tx *Tx, id int, upd csb.SubjectUpdate) (*csb.Subject, error) {
+	subject, err := findSubjectByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if v := upd.EngageCode; v != nil {
+		subject.EngageCode = *v
+	}
+	if v := upd.Name; v != nil {
+		subject.Name = *v
+	}
+	if err := subject.Validate(); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE subjects SET
+			engage_code = ?,
+			name = ?
+		WHERE id = ?
+	`,
+		subject.EngageCode,
+		subject.Name,
+		id,
+	)
+	if isUniqueConstraint(err) {
+		return nil, csb.Errorf(csb.ECONFLICT, "subject engage code or name already taken")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &subject, nil
+}
+
+// createEngageSubject adds the subject with the engage code to the catalog. The subject is
+// named after its engage code if it has no name or another subject already has the name.
+func createEngageSubject(ctx context.Context, tx *Tx, code, name string) (csb.Subject, error) {
+	subject := csb.Subject{EngageCode: code, Name: name}
+	if _, err := findSubjectByName(ctx, tx, name); name == "" || err == nil {
+		subject.Name = code
+	} else if csb.ErrorCode(err) != csb.ENOTFOUND {
+		return subject, err
+	}
+
+	return subject, createSubject(ctx, tx, &subject)
+}
+
+// attachSubjectToStudent links the subject to the student in the academic year by its engage
+// code. Subjects with unknown engage codes are added to the catalog.
+func attachSubjectToStudent(ctx context.Context, tx *Tx, pid, academicYear int, subject csb.Subject) error {
+	found, err := findSubjectByEngageCode(ctx, tx, subject.EngageCode)
+	if csb.ErrorCode(err) == csb.ENOTFOUND {
+		found, err = createEngageSubject(ctx, tx, subject.EngageCode, subject.Name)
+	}
 	if err != nil {
 		return err
 	}
 
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO student_takes (
+		INSERT OR IGNORE INTO student_takes (
 			student_id,
 			subject_id,
-		) VALUES (?, ?)
+			academic_year
+		) VALUES (?, ?, ?)
 	`,
 		pid,
-		subject.ID,
+		found.ID,
+		academicYear,
 	)
 
 	return err
 }
+
+// placeSubjectsTaken places the subjects taken without marks, left unplaced by the migration
+// adding the academic year to student_takes, in the current academic year.
+func placeSubjectsTaken(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO student_takes (student_id, subject_id, academic_year)
+		SELECT student_id, subject_id, ? FROM student_takes_unplaced
+	`,
+		csb.CurrentAcademicYear,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM student_takes_unplaced`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func findSubjectsByPID(ctx context.Context, tx *Tx, pid int) ([]csb.Subject, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT
+			subjects.id,
+			subjects.engage_code,
+			subjects.name
+		FROM subjects
+		JOIN student_takes ON student_takes.subject_id = subjects.id
+		WHERE student_takes.student_id = ?
+		ORDER BY subjects.name ASC
+	`,
+		pid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := make([]csb.Subject, 0)
+	for rows.Next() {
+		var subject csb.Subject
+		if err := rows.Scan(
+			&subject.ID,
+			&subject.EngageCode,
+			&subject.Name,
+		); err != nil {
+			return nil, err
+		}
+
+		subjects = append(subjects, subject)
+	}
+
+	return subjects, rows.Err()
+}
+
+// findSubjectHistoryByPID returns the subjects the student took in each academic year, ordered
+// by academic year.
+func findSubjectHistoryByPID(ctx context.Context, tx *Tx, pid int) ([]csb.SubjectsTaken, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			student_takes.academic_year,
+			subjects.id,
+			subjects.engage_code,
+			subjects.name
+		FROM subjects
+		JOIN student_takes ON student_takes.subject_id = subjects.id
+		WHERE student_takes.student_id = ?
+		ORDER BY student_takes.academic_year ASC, subjects.name ASC
+	`,
+		pid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]csb.SubjectsTaken, 0)
+	for rows.Next() {
+		var academicYear int
+		var subject csb.Subject
+		if err := rows.Scan(
+			&academicYear,
+			&subject.ID,
+			&subject.EngageCode,
+			&subject.Name,
+		); err != nil {
+			return nil, err
+		}
+
+		if n := len(history); n == 0 || history[n-1].AcademicYear != academicYear {
+			history = append(history, csb.SubjectsTaken{AcademicYear: academicYear})
+		}
+		last := &history[len(history)-1]
+		last.Subjects = append(last.Subjects, subject)
+	}
+
+	return history, rows.Err()
+}
+
+// subjectCondition returns a condition on the subjects table matching the subject by the
+// most specific populated field: id, engage code and then name.
+func subjectCondition(subject csb.Subject) (string, interface{}) {
+	switch {
+	case subject.ID != 0:
+		return "id = ?", subject.ID
+	case subject.EngageCode != "":
+		return "engage_code = ?", subject.EngageCode
+	default:
+		return "name = ?", subject.Name
+	}
+}
@@ -0,0 +1,377 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.TeacherService = (*TeacherService)(nil)
+
+// TeacherService stores the teachers issuing the marks and the spellings of their names.
+type TeacherService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewTeacherService creates a new teacher service with the provided database.
+func NewTeacherService(db *DB) *TeacherService {
+	return &TeacherService{
+		db: db,
+	}
+}
+
+// FindTeacherByID returns a teacher based on the passed id.
+//
+// returns ENOTFOUND if the teacher isnt found.
+func (s *TeacherService) FindTeacherByID(ctx context.Context, id int) (*csb.Teacher, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	teacher, err := findTeacherByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachTeacherAssociations(ctx, tx, teacher); err != nil {
+		return nil, err
+	}
+
+	return teacher, nil
+}
+
+// FindTeachers returns a range of teachers based on filter, ordered by name.
+func (s *TeacherService) FindTeachers(ctx context.Context, filter csb.TeacherFilter) ([]*csb.Teacher, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	teachers, err := findTeachers(ctx, tx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, teacher := range teachers {
+		if err := attachTeacherAssociations(ctx, tx, teacher); err != nil {
+			return nil, err
+		}
+	}
+
+	return teachers, nil
+}
+
+// FindTeacherMarks returns the marks issued by the teacher.
+//
+// returns ENOTFOUND if the teacher isnt found.
+func (s *TeacherService) FindTeacherMarks(ctx context.Context, id int) ([]*csb.Mark, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := findTeacherByID(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	return findMarks(ctx, tx, csb.MarksFilter{TeacherID: &id})
+}
+
+// AddTeacherAlias links the alias to the teacher. Existing marks with the alias which are
+// linked to a teacher without any other alias are moved to the teacher.
+//
+// returns ENOTFOUND if the teacher isnt found and ECONFLICT if the alias belongs to another
+// teacher with other aliases.
+func (s *TeacherService) AddTeacherAlias(ctx context.Context, id int, alias string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := findTeacherByID(ctx, tx, id); err != nil {
+		return err
+	}
+
+	alias = csb.NormalizeTeacherName(alias)
+	if alias == "" {
+		return csb.Errorf(csb.EINVALID, "alias cannot be empty")
+	}
+
+	// a teacher created only for the alias is merged, since the alias was a misspelling.
+	var owner, aliases int
+	err = tx.QueryRowContext(ctx, `
+		SELECT teacher_id, (SELECT COUNT(*) FROM teacher_aliases AS other WHERE other.teacher_id = teacher_aliases.teacher_id)
+		FROM teacher_aliases
+		WHERE alias = ?
+	`,
+		alias,
+	).Scan(&owner, &aliases)
+	switch {
+	case err == sql.ErrNoRows:
+		if err := createTeacherAlias(ctx, tx, id, alias); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case owner == id:
+		return nil
+	case aliases > 1:
+		return csb.Errorf(csb.ECONFLICT, "alias %v belongs to another teacher", alias)
+	default:
+		if err := mergeTeachers(ctx, tx, owner, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MergeTeachers moves all the aliases and marks of the teacher from to the teacher into and
+// deletes the teacher from.
+//
+// returns ENOTFOUND if any of the teachers isnt found.
+func (s *TeacherService) MergeTeachers(ctx context.Context, from, into int) error {
+	if from == into {
+		return csb.Errorf(csb.EINVALID, "cannot merge a teacher into itself")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := findTeacherByID(ctx, tx, from); err != nil {
+		return err
+	}
+	if _, err := findTeacherByID(ctx, tx, into); err != nil {
+		return err
+	}
+
+	if err := mergeTeachers(ctx, tx, from, into); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func findTeacherByID(ctx context.Context, tx *Tx, id int) (*csb.Teacher, error) {
+	t, err := findTeachers(ctx, tx, csb.TeacherFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(t) == 0 {
+		return nil, csb.Errorf(csb.ENOTFOUND, "teacher not found")
+	}
+
+	return t[0], nil
+}
+
+func findTeachers(ctx context.Context, tx *Tx, filter csb.TeacherFilter) ([]*csb.Teacher, error) {
+	// prepare where clause.
+	where, args := []string{"1=1"}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where = append(where, "teachers.id = ?")
+		args = append(args, *v)
+	}
+	if v := filter.Name; v != nil {
+		where = append(where, "teachers.id IN (SELECT teacher_id FROM teacher_aliases WHERE alias LIKE ?)")
+		args = append(args, "%"+*v+"%")
+	}
+	if v := filter.SubjectID; v != nil {
+		where = append(where, "teachers.id IN (SELECT teacher_id FROM marks WHERE subject_id = ?)")
+		args = append(args, *v)
+	}
+	if v := filter.AcademicYear; v != nil {
+		where = append(where, "teachers.id IN (SELECT teacher_id FROM marks WHERE academic_year = ?)")
+		args = append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			name,
+			created_at
+		FROM teachers
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY name ASC, id ASC
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teachers := make([]*csb.Teacher, 0)
+	for rows.Next() {
+		var teacher csb.Teacher
+		if err := rows.Scan(
+			&teacher.ID,
+			&teacher.Name,
+			&teacher.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		teachers = append(teachers, &teacher)
+	}
+
+	return teachers, rows.Err()
+}
+
+// resolveTeacher returns the id of the teacher the spelling of the name is an alias of,
+// creating a new teacher if the spelling is unknown.
+func resolveTeacher(ctx context.Context, tx *Tx, name string) (int, error) {
+	name = csb.NormalizeTeacherName(name)
+
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT teacher_id FROM teacher_aliases WHERE alias = ?`, name).Scan(&id)
+	if err == nil {
+		return id, nil
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO teachers (
+			name,
+			created_at
+		) VALUES (?, ?)
+	`,
+		name,
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	id = int(lastID)
+
+	return id, createTeacherAlias(ctx, tx, id, name)
+}
+
+func createTeacherAlias(ctx context.Context, tx *Tx, id int, alias string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO teacher_aliases (
+			teacher_id,
+			alias
+		) VALUES (?, ?)
+	`,
+		id,
+		alias,
+	)
+	return err
+}
+
+func mergeTeachers(ctx context.Context, tx *Tx, from, into int) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE teacher_aliases SET teacher_id = ? WHERE teacher_id = ?`, into, from); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE marks SET teacher_id = ? WHERE teacher_id = ?`, into, from); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM teachers WHERE id = ?`, from)
+	return err
+}
+
+// attachTeacherAssociations attaches the aliases of the teacher and the subjects and academic
+// years the teacher issued marks on.
+func attachTeacherAssociations(ctx context.Context, tx *Tx, teacher *csb.Teacher) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT alias
+		FROM teacher_aliases
+		WHERE teacher_id = ?
+		ORDER BY alias ASC
+	`,
+		teacher.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	teacher.Aliases = make([]string, 0)
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return err
+		}
+
+		teacher.Aliases = append(teacher.Aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+		SELECT DISTINCT
+			subjects.id,
+			subjects.engage_code,
+			subjects.name
+		FROM subjects
+		JOIN marks ON marks.subject_id = subjects.id
+		WHERE marks.teacher_id = ?
+		ORDER BY subjects.name ASC
+	`,
+		teacher.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	teacher.Subjects = make([]csb.Subject, 0)
+	for rows.Next() {
+		var subject csb.Subject
+		if err := rows.Scan(
+			&subject.ID,
+			&subject.EngageCode,
+			&subject.Name,
+		); err != nil {
+			return err
+		}
+
+		teacher.Subjects = append(teacher.Subjects, subject)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+		SELECT DISTINCT academic_year
+		FROM marks
+		WHERE teacher_id = ?
+		ORDER BY academic_year ASC
+	`,
+		teacher.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	teacher.AcademicYears = make([]int, 0)
+	for rows.Next() {
+		var academicYear int
+		if err := rows.Scan(&academicYear); err != nil {
+			return err
+		}
+
+		teacher.AcademicYears = append(teacher.AcademicYears, academicYear)
+	}
+
+	return rows.Err()
+}
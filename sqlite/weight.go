@@ -0,0 +1,341 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	csb "github.com/Lambels/CSB-Open-API"
+)
+
+var _ csb.WeightService = (*WeightService)(nil)
+
+// WeightService stores the versioned weight profiles used to score ranks.
+type WeightService struct {
+	// db for persistance.
+	db *DB
+}
+
+// NewWeightService creates a new weight service with the provided database.
+func NewWeightService(db *DB) *WeightService {
+	return &WeightService{
+		db: db,
+	}
+}
+
+// FindWeightProfile returns the referenced version of a weight profile, the latest version if
+// the reference has no version.
+//
+// returns ENOTFOUND if the profile or version isnt found.
+func (s *WeightService) FindWeightProfile(ctx context.Context, ref csb.WeightProfileRef) (*csb.WeightProfile, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findWeightProfile(ctx, tx, ref)
+}
+
+// FindWeightProfiles returns a range of weight profiles based on filter.
+func (s *WeightService) FindWeightProfiles(ctx context.Context, filter csb.WeightProfileFilter) ([]*csb.WeightProfile, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findWeightProfiles(ctx, tx, filter)
+}
+
+// CreateWeightProfile creates the next version of the profile.
+func (s *WeightService) CreateWeightProfile(ctx context.Context, profile *csb.WeightProfile) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createWeightProfile(ctx, tx, profile); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteWeightProfile archives the referenced version of a weight profile, all its versions
+// if the reference has no version. The versions arent deleted since the ranks reference them.
+//
+// returns ENOTFOUND if the profile or version isnt found or is already archived.
+func (s *WeightService) DeleteWeightProfile(ctx context.Context, ref csb.WeightProfileRef) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if profile, err := findWeightProfile(ctx, tx, ref); err != nil {
+		return err
+	} else if profile.ArchivedAt != nil {
+		return csb.Errorf(csb.ENOTFOUND, "weight profile not found")
+	}
+
+	if ref.Version != nil {
+		_, err = tx.ExecContext(ctx, `UPDATE weight_profiles SET archived_at = ? WHERE name = ? AND version = ?`, time.Now(), ref.Name, *ref.Version)
+	} else {
+		_, err = tx.ExecContext(ctx, `UPDATE weight_profiles SET archived_at = ? WHERE name = ? AND archived_at IS NULL`, time.Now(), ref.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func findWeightProfile(ctx context.Context, tx *Tx, ref csb.WeightProfileRef) (*csb.WeightProfile, error) {
+	// the latest version is the highest one which isnt archived, archived versions are only
+	// found by their version.
+	where, args := "name = ?", []interface{}{ref.Name}
+	if v := ref.Version; v != nil {
+		where += " AND version = ?"
+		args = append(args, *v)
+	} else {
+		where += " AND archived_at IS NULL"
+	}
+
+	row := tx.QueryRowContext(ctx, `
+		SELECT
+			id,
+			name,
+			version,
+			created_at,
+			archived_at
+		FROM weight_profiles
+		WHERE `+where+`
+		ORDER BY version DESC
+		LIMIT 1
+	`,
+		args...,
+	)
+
+	var profile csb.WeightProfile
+	var archivedAt sql.NullTime
+	if err := row.Scan(
+		&profile.ID,
+		&profile.Name,
+		&profile.Version,
+		&profile.CreatedAt,
+		&archivedAt,
+	); err == sql.ErrNoRows {
+		return nil, csb.Errorf(csb.ENOTFOUND, "weight profile not found")
+	} else if err != nil {
+		return nil, err
+	}
+	if archivedAt.Valid {
+		profile.ArchivedAt = &archivedAt.Time
+	}
+
+	if err := attachWeights(ctx, tx, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func findWeightProfiles(ctx context.Context, tx *Tx, filter csb.WeightProfileFilter) ([]*csb.WeightProfile, error) {
+	// prepare where clause.
+	where, args := []string{"1=1"}, []interface{}{}
+	if v := filter.Name; v != nil {
+		where = append(where, "name = ?")
+		args = append(args, *v)
+	}
+	if !filter.Archived {
+		where = append(where, "archived_at IS NULL")
+	}
+	if filter.Latest {
+		where = append(where, "version = (SELECT MAX(version) FROM weight_profiles AS latest WHERE latest.name = weight_profiles.name AND latest.archived_at IS NULL)")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			id,
+			name,
+			version,
+			created_at,
+			archived_at
+		FROM weight_profiles
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY name ASC, version DESC
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]*csb.WeightProfile, 0)
+	for rows.Next() {
+		var profile csb.WeightProfile
+		var archivedAt sql.NullTime
+		if err := rows.Scan(
+			&profile.ID,
+			&profile.Name,
+			&profile.Version,
+			&profile.CreatedAt,
+			&archivedAt,
+		); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if archivedAt.Valid {
+			profile.ArchivedAt = &archivedAt.Time
+		}
+
+		profiles = append(profiles, &profile)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, profile := range profiles {
+		if err := attachWeights(ctx, tx, profile); err != nil {
+			return nil, err
+		}
+	}
+
+	return profiles, nil
+}
+
+func createWeightProfile(ctx context.Context, tx *Tx, profile *csb.WeightProfile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	for subjectID := range profile.Subjects {
+		if _, err := findSubjectByID(ctx, tx, subjectID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+		SELECT IFNULL(MAX(version), 0) + 1 FROM weight_profiles WHERE name = ?
+	`,
+		profile.Name,
+	).Scan(&profile.Version); err != nil {
+		return err
+	}
+	profile.CreatedAt = time.Now()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO weight_profiles (
+			name,
+			version,
+			created_at
+		) VALUES (?, ?, ?)
+	`,
+		profile.Name,
+		profile.Version,
+		profile.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	profile.ID = int(id)
+
+	for importance, w := range profile.Importances {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO weight_profile_importances (
+				profile_id,
+				importance,
+				weight
+			) VALUES (?, ?, ?)
+		`,
+			profile.ID,
+			importance,
+			w,
+		); err != nil {
+			return err
+		}
+	}
+
+	for subjectID, w := range profile.Subjects {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO weight_profile_subjects (
+				profile_id,
+				subject_id,
+				weight
+			) VALUES (?, ?, ?)
+		`,
+			profile.ID,
+			subjectID,
+			w,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func attachWeights(ctx context.Context, tx *Tx, profile *csb.WeightProfile) error {
+	profile.Importances = make(map[string]float64)
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			importance,
+			weight
+		FROM weight_profile_importances
+		WHERE profile_id = ?
+	`,
+		profile.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var importance string
+		var w float64
+		if err := rows.Scan(&importance, &w); err != nil {
+			return err
+		}
+
+		profile.Importances[importance] = w
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	profile.Subjects = make(map[int]float64)
+	rows, err = tx.QueryContext(ctx, `
+		SELECT
+			subject_id,
+			weight
+		FROM weight_profile_subjects
+		WHERE profile_id = ?
+	`,
+		profile.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var subjectID int
+		var w float64
+		if err := rows.Scan(&subjectID, &w); err != nil {
+			return err
+		}
+
+		profile.Subjects[subjectID] = w
+	}
+
+	return rows.Err()
+}
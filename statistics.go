@@ -0,0 +1,87 @@
+package csb
+
+import "context"
+
+// Fields the marks statistics can be grouped by.
+const (
+	// GroupBySubject groups the marks by their subject.
+	GroupBySubject = "subject"
+	// GroupByTeacher groups the marks by their teacher.
+	GroupByTeacher = "teacher"
+	// GroupByYear groups the marks by the year group their students were in when the marks
+	// were recieved.
+	GroupByYear = "year"
+	// GroupByTerm groups the marks by their academic year and term.
+	GroupByTerm = "term"
+	// GroupByImportance groups the marks by their exam importance.
+	GroupByImportance = "importance"
+)
+
+// StatisticsFilter represents a request to the MarkStatistics service.
+type StatisticsFilter struct {
+	// Marks scopes the marks the statistics are calculated on.
+	Marks MarksFilter `json:"marks"`
+	// Students scopes the students whose marks are used.
+	Students StudentFilter `json:"students"`
+
+	// GroupBy are the fields the marks are grouped by, each group gets its own statistics.
+	// If empty all the marks are in one group.
+	GroupBy []string `json:"group_by"`
+	// Percentiles are the percentiles calculated for each group, between 0 and 100.
+	// Defaults to 25, 50 and 75.
+	Percentiles []float64 `json:"percentiles"`
+	// BucketSize is the width of the histogram buckets in percentage points. Defaults to 10.
+	BucketSize int `json:"bucket_size"`
+}
+
+// StatisticsGroup identifies a group of marks, only the fields the marks were grouped by
+// are populated.
+type StatisticsGroup struct {
+	Subject      *Subject `json:"subject,omitempty"`
+	Teacher      *string  `json:"teacher,omitempty"`
+	Year         *int     `json:"year,omitempty"`
+	AcademicYear *int     `json:"academic_year,omitempty"`
+	Term         *int     `json:"term,omitempty"`
+	Importance   *string  `json:"importance,omitempty"`
+}
+
+// Statistics represents the statistics of the percentages of a group of marks.
+type Statistics struct {
+	// Group identifies the group the statistics were calculated on.
+	Group StatisticsGroup `json:"group"`
+
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	// StdDev is the population standard deviation.
+	StdDev float64 `json:"std_dev"`
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+
+	Percentiles []Percentile      `json:"percentiles"`
+	Histogram   []HistogramBucket `json:"histogram"`
+}
+
+// Percentile represents the value under which P percent of the percentages fall.
+type Percentile struct {
+	P     float64 `json:"p"`
+	Value float64 `json:"value"`
+}
+
+// HistogramBucket represents the amount of percentages in [Min, Max). The last bucket of a
+// histogram also includes Max.
+type HistogramBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// StatisticsService represents a statistics service.
+type StatisticsService interface {
+	// MarkStatistics calculates the statistics of the marks scoped by the filter for each
+	// group of marks.
+	//
+	// returns EINVALID if the filter has unknown group by fields, percentiles outside of
+	// [0, 100] or a bucket size outside of [1, 100].
+	MarkStatistics(ctx context.Context, filter StatisticsFilter) ([]*Statistics, error)
+}
@@ -17,14 +17,26 @@ type Student struct {
 	// Indicates if this student still attends the school, if false, current year will empty.
 	AttendsSchool bool `json:"attends_school"`
 	// Subjects are all the subjects the student ever took.
-	Subjects []Subject `json:"subjects"`
+	Subjects []Subject `json:"subjects,omitempty"`
+	// SubjectHistory are the subjects the student took in each academic year, ordered by
+	// academic year.
+	SubjectHistory []SubjectsTaken `json:"subject_history,omitempty"`
 	// Marks are all the marks the student ever took.
-	Marks []*Mark `json:"marks"`
+	Marks []*Mark `json:"marks,omitempty"`
 	// Timestamps.
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// YearIn returns the year group of the student in the academic year, derived from the current
+// year. Returns 0 if the student no longer attends the school.
+func (s *Student) YearIn(academicYear int) int {
+	if !s.AttendsSchool || s.CurrentYear == 0 {
+		return 0
+	}
+	return s.CurrentYear - (CurrentAcademicYear - academicYear)
+}
+
 func (s *Student) Validate() error {
 	if s.PID == 0 {
 		return Errorf(EINVALID, "validate: student missing pid field")
@@ -39,15 +51,30 @@ func (s *Student) Validate() error {
 	return nil
 }
 
+// StudentAssociations are the associations which can be included with a student. The
+// subjects association loads both the subjects and the subject history of the student, the
+// subject of a mark is read with the mark so marks.subject is the same as marks.
+var StudentAssociations = []string{"subjects", "marks", "marks.subject"}
+
+// SubjectsTaken represents the subjects a student took in an academic year.
+type SubjectsTaken struct {
+	AcademicYear int       `json:"academic_year"`
+	Subjects     []Subject `json:"subjects"`
+}
+
 // StudentService represents a student service.
 type StudentService interface {
-	// FindStudentByPID returns the student with pid = pid.
+	// FindStudentByPID returns the student with pid = pid along side the included
+	// associations.
 	//
-	// returns ENOTFOUND if the student doesent exist.
-	FindStudentByPID(ctx context.Context, pid int) (*Student, error)
+	// returns ENOTFOUND if the student doesent exist and EINVALID if an association isnt
+	// valid.
+	FindStudentByPID(ctx context.Context, pid int, include Include) (*Student, error)
 
-	// FindStudents finds the marks with the appropiate filter.
-	FindStudents(ctx context.Context, filter StudentFilter) ([]*Student, error)
+	// FindStudents finds a page of the students with the appropiate filter.
+	//
+	// returns EINVALID if the sort field, cursor or an association is invalid.
+	FindStudents(ctx context.Context, filter StudentFilter) ([]*Student, Page, error)
 
 	// DeleteStudent permanently deletes the student with pid = pid.
 	//
@@ -77,6 +104,19 @@ type StudentFilter struct {
 
 	// Subjects filters on the subjects each student takes.
 	Subjects []Subject `json:"subjects"`
+
+	// Include lists the associations loaded with each student, none if empty.
+	Include Include `json:"include"`
+
+	// Sort orders the page by pid, name or current_year, prefixed with - for descending
+	// order. Defaults to pid.
+	Sort string `json:"sort"`
+	// Limit caps the size of the page.
+	Limit int `json:"limit"`
+	// Offset skips the first students, Cursor continues from the next cursor of a previous
+	// page in place of an offset.
+	Offset int    `json:"offset"`
+	Cursor string `json:"cursor"`
 }
 
 // RefreshStudents represents an request to the RefreshStudents serivce.
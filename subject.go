@@ -1,8 +1,102 @@
 package csb
 
+import "context"
+
 // Subject is the engage identifier of a subject.
 type Subject struct {
 	ID         int    `json:"id"`
 	EngageCode string `json:"engage_code"`
 	Name       string `json:"name"`
 }
+
+func (s *Subject) Validate() error {
+	if s.EngageCode == "" {
+		return Errorf(EINVALID, "validate: subject missing engage code field")
+	}
+	if s.Name == "" {
+		return Errorf(EINVALID, "validate: subject missing name field")
+	}
+	return nil
+}
+
+// SubjectService represents a service managing the subject catalog.
+type SubjectService interface {
+	// FindSubjectByID returns the subject with id = id.
+	//
+	// returns ENOTFOUND if the subject doesnt exist.
+	FindSubjectByID(ctx context.Context, id int) (*Subject, error)
+
+	// FindSubjects finds the subjects with the appropiate filter.
+	FindSubjects(ctx context.Context, filter SubjectFilter) ([]*Subject, error)
+
+	// CreateSubject creates a new subject.
+	//
+	// returns ECONFLICT if a subject with the same engage code or name already exists.
+	CreateSubject(ctx context.Context, subject *Subject) error
+
+	// UpdateSubject updates the subject with id = id.
+	//
+	// returns ENOTFOUND if the subject doesnt exist and ECONFLICT if the update clashes with
+	// the engage code or name of another subject.
+	UpdateSubject(ctx context.Context, id int, upd SubjectUpdate) (*Subject, error)
+
+	// DeleteSubject permanently deletes the subject with id = id along with its marks.
+	//
+	// returns ENOTFOUND if the subject doesnt exist.
+	DeleteSubject(ctx context.Context, id int) error
+
+	// SyncSubjects reads the reporting subjects of the students from engage, adds the
+	// unknown subjects and reports the subjects whose display name changed. The subjects
+	// are only renamed if the sync applies the renames, the names in the catalog may be
+	// curated.
+	//
+	// syncing all the students takes long, it is meant to run as a transaction on the work
+	// queue.
+	//
+	// returns any error in the exchange.
+	SyncSubjects(ctx context.Context, sync SyncSubjects) (*SubjectSyncReport, error)
+}
+
+// SubjectFilter represents a filter to bulk get subjects.
+type SubjectFilter struct {
+	// EngageCode filters on the subject engage code.
+	EngageCode *string `json:"engage_code"`
+	// Name filters on the subject names.
+	Name *string `json:"name"`
+}
+
+// SubjectUpdate represents the updatable fields of a subject.
+type SubjectUpdate struct {
+	EngageCode *string `json:"engage_code"`
+	Name       *string `json:"name"`
+}
+
+// SyncSubjects represents a request to the SyncSubjects service.
+type SyncSubjects struct {
+	// PIDs are the students whose reporting subjects are read, all the students attending
+	// school if empty.
+	PIDs []int `json:"pids"`
+	// ApplyRenames renames the subjects whose display name changed in engage, the renames
+	// are only reported if false.
+	ApplyRenames bool `json:"apply_renames"`
+}
+
+// SubjectSyncReport represents the changes made to the subject catalog by a sync.
+type SubjectSyncReport struct {
+	// Added are the subjects engage reported with unknown codes, named after their engage
+	// code if their display name is taken by another subject.
+	Added []Subject `json:"added"`
+	// Renamed are the subjects whose display name changed in engage, only renamed if the
+	// sync applies the renames.
+	Renamed []SubjectRename `json:"renamed"`
+	// Conflicts are the renames which werent applied since another subject already has
+	// the name.
+	Conflicts []SubjectRename `json:"conflicts"`
+}
+
+// SubjectRename represents a change of the display name of a subject.
+type SubjectRename struct {
+	Subject Subject `json:"subject"`
+	From    string  `json:"from"`
+	To      string  `json:"to"`
+}
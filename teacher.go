@@ -0,0 +1,72 @@
+package csb
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Teacher represents a teacher issuing marks. The name of a teacher in the renders isnt
+// consistent, every spelling of the name is an alias of the teacher.
+type Teacher struct {
+	// ID of the teacher.
+	ID int `json:"id"`
+	// Name is the display name of the teacher.
+	Name string `json:"name"`
+	// Aliases are all the spellings of the name linked to the teacher, including the name.
+	Aliases []string `json:"aliases"`
+
+	// Subjects and AcademicYears the teacher issued marks on.
+	Subjects      []Subject `json:"subjects"`
+	AcademicYears []int     `json:"academic_years"`
+
+	// Timestamp.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NormalizeTeacherName trims the spaces around the name of a teacher. Aliases are matched on
+// their normalized form regardless of case.
+func NormalizeTeacherName(name string) string {
+	return strings.TrimSpace(name)
+}
+
+// TeacherFilter represents a filter to bulk get teachers.
+type TeacherFilter struct {
+	// ID filters on the teacher id.
+	ID *int `json:"id"`
+	// Name filters on the names and aliases of the teachers.
+	Name *string `json:"name"`
+	// SubjectID filters on the subjects the teachers issued marks on.
+	SubjectID *int `json:"subject_id"`
+	// AcademicYear filters on the academic years the teachers issued marks in.
+	AcademicYear *int `json:"academic_year"`
+}
+
+// TeacherService represents a teacher service.
+type TeacherService interface {
+	// FindTeacherByID returns the teacher with id = id.
+	//
+	// returns ENOTFOUND if the teacher doesnt exist.
+	FindTeacherByID(ctx context.Context, id int) (*Teacher, error)
+
+	// FindTeachers finds the teachers with the appropiate filter.
+	FindTeachers(ctx context.Context, filter TeacherFilter) ([]*Teacher, error)
+
+	// FindTeacherMarks returns the marks issued by the teacher with id = id.
+	//
+	// returns ENOTFOUND if the teacher doesnt exist.
+	FindTeacherMarks(ctx context.Context, id int) ([]*Mark, error)
+
+	// AddTeacherAlias links a spelling of a name to the teacher with id = id, future marks
+	// with the spelling are linked to the teacher.
+	//
+	// returns ENOTFOUND if the teacher doesnt exist and ECONFLICT if the alias is linked to
+	// another teacher.
+	AddTeacherAlias(ctx context.Context, id int, alias string) error
+
+	// MergeTeachers merges the teacher with id = from into the teacher with id = into,
+	// moving all the aliases and marks of the merged teacher.
+	//
+	// returns ENOTFOUND if any of the teachers doesnt exist.
+	MergeTeachers(ctx context.Context, from, into int) error
+}
@@ -0,0 +1,57 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Fields are the extra fields of a log line.
+type Fields map[string]interface{}
+
+var (
+	logMu  sync.Mutex
+	logOut io.Writer = os.Stderr
+)
+
+// SetLogOutput sets the destination of the log lines, stderr by default.
+func SetLogOutput(w io.Writer) {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	logOut = w
+}
+
+// LogError writes the error as a json log line with the trace id and span id of the context,
+// the fields can't override the fields set by LogError.
+func LogError(ctx context.Context, msg string, err error, fields Fields) {
+	line := make(Fields, len(fields)+5)
+	for k, v := range fields {
+		line[k] = v
+	}
+	line["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["level"] = "error"
+	line["msg"] = msg
+	if err != nil {
+		line["error"] = err.Error()
+	}
+	if id := TraceID(ctx); id != "" {
+		line["trace_id"] = id
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		line["span_id"] = span.SpanID
+	}
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	logOut.Write(append(b, '\n'))
+}
@@ -0,0 +1,35 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+var _ Exporter = (*StdoutExporter)(nil)
+
+// StdoutExporter exports the spans as json lines, to stdout by default.
+type StdoutExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewStdoutExporter creates a new exporter writing to w, stdout if nil.
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	if w == nil {
+		w = os.Stdout
+	}
+
+	return &StdoutExporter{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// ExportSpan writes the span as a json line.
+func (e *StdoutExporter) ExportSpan(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.enc.Encode(span)
+}
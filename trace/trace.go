@@ -0,0 +1,154 @@
+// Package trace ties the work done for a request together, from the HTTP edge through the
+// work queue transactions to the sqlite services and the engage calls.
+//
+// A trace id is created at the HTTP edge and carried in the context, each unit of work opens a
+// span under it. The spans are handed to the exporter set with SetExporter once they end,
+// they are dropped if no exporter is set.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Span represents a unit of work in a trace.
+type Span struct {
+	// TraceID is shared by all the spans of the trace.
+	TraceID string `json:"trace_id"`
+	// SpanID identifies the span, ParentID is the id of the enclosing span, empty for the
+	// root span.
+	SpanID   string `json:"span_id"`
+	ParentID string `json:"parent_id,omitempty"`
+
+	// Name of the unit of work, for example engage.GetAcademicYears.
+	Name string `json:"name"`
+	// Attributes describe the unit of work.
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Error of the unit of work, empty if it succeeded.
+	Error string `json:"error,omitempty"`
+
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// SetAttribute sets the attribute of the span with the provided key.
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]string)
+	}
+	s.Attributes[key] = value
+}
+
+// Finish ends the span with the error of the unit of work and exports it.
+//
+// no-op if the span already ended.
+func (s *Span) Finish(err error) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.mu.Unlock()
+
+	if e := getExporter(); e != nil {
+		e.ExportSpan(s)
+	}
+}
+
+// Exporter represents a destination of the ended spans.
+type Exporter interface {
+	// ExportSpan exports the ended span, it is called synchronously so it shouldnt block.
+	ExportSpan(span *Span)
+}
+
+var (
+	exporterMu sync.RWMutex
+	exporter   Exporter
+)
+
+// SetExporter sets the exporter of the ended spans, nil drops the spans.
+func SetExporter(e Exporter) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+
+	exporter = e
+}
+
+func getExporter() Exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+
+	return exporter
+}
+
+type (
+	traceIDKey struct{}
+	spanKey    struct{}
+)
+
+// NewContextWithTraceID returns a copy of the context carrying the trace id, the spans started
+// from it belong to the trace.
+func NewContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceID returns the trace id carried by the context, empty if none.
+func TraceID(ctx context.Context) string {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.TraceID
+	}
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// SpanFromContext returns the current span of the context, nil if none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start starts a span with the provided name under the current span of the context. A new
+// trace is started if the context doesnt carry a trace id.
+//
+// The returned context carries the span, which has to be finished by the caller.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{
+		TraceID: TraceID(ctx),
+		SpanID:  newID(8),
+		Name:    name,
+		Start:   time.Now(),
+	}
+	if span.TraceID == "" {
+		span.TraceID = NewTraceID()
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.ParentID = parent.SpanID
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// NewTraceID returns a new random trace id.
+func NewTraceID() string {
+	return newID(16)
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,105 @@
+package csb
+
+import (
+	"context"
+	"time"
+)
+
+// WeightProfile weighs the marks which make up the score of a rank by the importance of their
+// period and by their subject. The weight of a mark is its importance weight multiplied by its
+// subject weight, importances and subjects missing from the profile weigh 1.
+//
+// Profiles are versioned by name: creating a profile with the name of an existing one creates
+// its next version, versions are never updated so old ranks can always be traced back to the
+// weights which produced them. Deleted versions are archived for the same reason.
+type WeightProfile struct {
+	// ID of the profile.
+	ID int `json:"id"`
+	// Name of the profile: "end of year".
+	Name string `json:"name"`
+	// Version of the profile, starting at 1.
+	Version int `json:"version"`
+
+	// Importances maps a period importance to its weight: {"Mock": 0.5}.
+	Importances map[string]float64 `json:"importances"`
+	// Subjects maps a subject id to its weight.
+	Subjects map[int]float64 `json:"subjects"`
+
+	// Timestamp.
+	CreatedAt time.Time `json:"created_at"`
+	// ArchivedAt is the time the version was deleted, nil if it wasnt.
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+func (p *WeightProfile) Validate() error {
+	if p.Name == "" {
+		return Errorf(EINVALID, "validate: weight profile missing name field")
+	}
+
+	for importance, w := range p.Importances {
+		if w < 0 {
+			return Errorf(EINVALID, "validate: weight of %v must be positive, but got: %v", importance, w)
+		}
+	}
+	for subjectID, w := range p.Subjects {
+		if w < 0 {
+			return Errorf(EINVALID, "validate: weight of subject %v must be positive, but got: %v", subjectID, w)
+		}
+	}
+	return nil
+}
+
+// Weight returns the weight of the mark on the profile.
+func (p *WeightProfile) Weight(mark *Mark) float64 {
+	w := 1.0
+	if mark.Period.Importance != nil {
+		if v, ok := p.Importances[*mark.Period.Importance]; ok {
+			w *= v
+		}
+	}
+	if v, ok := p.Subjects[mark.SubjectID]; ok {
+		w *= v
+	}
+
+	return w
+}
+
+// WeightProfileRef references a version of a weight profile.
+type WeightProfileRef struct {
+	Name string `json:"name"`
+	// Version of the profile, the latest version which isnt archived is used if nil.
+	Version *int `json:"version"`
+}
+
+// WeightProfileFilter represents a filter used to find weight profiles.
+type WeightProfileFilter struct {
+	// Name filters on the name of the profiles.
+	Name *string `json:"name"`
+	// Latest only lets through the latest version of each profile.
+	Latest bool `json:"latest"`
+	// Archived lets through the archived versions too.
+	Archived bool `json:"archived"`
+}
+
+// WeightService represents a service managing weight profiles.
+type WeightService interface {
+	// FindWeightProfile returns the referenced version of a weight profile.
+	//
+	// returns ENOTFOUND if the profile or version doesnt exist.
+	FindWeightProfile(ctx context.Context, ref WeightProfileRef) (*WeightProfile, error)
+
+	// FindWeightProfiles finds the weight profiles with the appropiate filter.
+	FindWeightProfiles(ctx context.Context, filter WeightProfileFilter) ([]*WeightProfile, error)
+
+	// CreateWeightProfile creates the next version of the profile with the provided name,
+	// populating its ID and Version fields.
+	CreateWeightProfile(ctx context.Context, profile *WeightProfile) error
+
+	// DeleteWeightProfile archives the referenced version of a weight profile, all its
+	// versions if the version isnt provided. Archived versions are left out of the profiles
+	// and arent the latest version of the profile, they are still found by their version so
+	// the ranks already scored with them can be traced back.
+	//
+	// returns ENOTFOUND if the profile or version doesnt exist or is already archived.
+	DeleteWeightProfile(ctx context.Context, ref WeightProfileRef) error
+}
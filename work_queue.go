@@ -1,6 +1,10 @@
 package csb
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"strings"
+)
 
 // Status represents the status of a transaction in the work queue.
 type Status struct {
@@ -36,6 +40,11 @@ type Transaction struct {
 	Ctx context.Context
 }
 
+// Type returns the name of the type of the data of the transaction.
+func (t *Transaction) Type() string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", t.Data), "*")
+}
+
 // Subscription represents a closable one way flow of updates from the work queue service to the
 // consumer.
 type Subscription interface {